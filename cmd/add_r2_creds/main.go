@@ -15,7 +15,7 @@ func main() {
 	}
 
 	// Initialize Secrets Manager
-	secretsService, err := secrets.NewService(cfg.UserSecretsDB, cfg.SecretKey)
+	secretsService, err := secrets.NewServiceFromConfig(toSecretsBackendConfig(cfg.SecretsBackend), cfg.SecretsDB, cfg.SecretKey)
 	if err != nil {
 		log.Fatalf("Failed to initialize secrets service: %v", err)
 	}
@@ -50,3 +50,25 @@ func main() {
 
 	log.Printf("Successfully added credentials for alias: %s", alias)
 }
+
+// toSecretsBackendConfig copies the HCL-decoded secrets backend block into
+// secrets.BackendConfig, which deliberately doesn't import the config
+// package to avoid a cycle. Returns nil when cfg is nil, matching
+// secrets.NewServiceFromConfig's SQLite-default behavior.
+func toSecretsBackendConfig(cfg *config.SecretsBackendConfig) *secrets.BackendConfig {
+	if cfg == nil {
+		return nil
+	}
+	return &secrets.BackendConfig{
+		Type:                cfg.Type,
+		VaultAddress:        cfg.VaultAddress,
+		VaultToken:          cfg.VaultToken,
+		VaultMount:          cfg.VaultMount,
+		VaultPrefix:         cfg.VaultPrefix,
+		DockerConfigPath:    cfg.DockerConfigPath,
+		Fallback:            cfg.Fallback,
+		PostgresDSN:         cfg.PostgresDSN,
+		FirestoreProjectID:  cfg.FirestoreProjectID,
+		FirestoreCollection: cfg.FirestoreCollection,
+	}
+}
@@ -375,7 +375,8 @@ func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request)
 		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 		defer cancel()
 		log.Printf("🔍 [AUTH TEST] Verifying remote '%s'...", alias)
-		_, err := source.ListEntries(ctx, "", creds)
+		source.RegisterProfile(alias, creds)
+		_, err := source.ListEntries(ctx, source.SourceRef{Path: "", ProfileID: alias})
 		if err != nil {
 			log.Printf("❌ [AUTH TEST] Remote '%s' FAILED: %v", alias, err)
 		} else {
@@ -413,7 +414,8 @@ func (s *Server) handleBrowse(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) listingLogic(w http.ResponseWriter, r *http.Request, alias string, relPath string, creds map[string]interface{}) {
-	entries, err := source.ListEntries(r.Context(), relPath, creds)
+	source.RegisterProfile(alias, creds)
+	entries, err := source.ListEntries(r.Context(), source.SourceRef{Path: relPath, ProfileID: alias})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to list entries: %v", err), http.StatusInternalServerError)
 		return
@@ -499,7 +501,8 @@ func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rc, err := source.GetFileStream(r.Context(), relPath, creds)
+	source.RegisterProfile(alias, creds)
+	rc, err := source.GetFileStream(r.Context(), source.SourceRef{Path: relPath, ProfileID: alias})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
 		return
@@ -1,27 +1,45 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"flight2/internal/config"
+	"flight2/internal/cors"
 	"flight2/internal/dataset"
 	"flight2/internal/secrets"
 	"flight2/internal/server"
 	"flight2/internal/source"
 
-	"io"
-
 	"github.com/darianmavgo/banquet"
 )
 
 func main() {
 	// Parse flags
 	configPath := flag.String("config", "config.hcl", "Path to configuration file")
+	secretsBackend := flag.String("secrets-backend", "", "Override the configured secrets backend (sqlite, vault, env, docker, postgres, firestore)")
+	authMode := flag.String("auth", "", "Override the configured /app/* auth mode (\"\" or \"oidc\")")
+	oidcIssuer := flag.String("oidc-issuer", "", "OIDC issuer URL (required when --auth=oidc)")
+	oidcClientID := flag.String("oidc-client-id", "", "OIDC client ID")
+	oidcClientSecret := flag.String("oidc-client-secret", "", "OIDC client secret")
+	oidcRedirectURL := flag.String("oidc-redirect-url", "", "OIDC redirect URL, e.g. https://host/app/auth/callback")
+	authAllowDomain := flag.String("auth-allow-domain", "", "Comma-separated email domains allowed to log in (empty allows any)")
+	safeMode := flag.Bool("safe-mode", false, "Protect sqlite_master from the catch-all handler")
+	enableDebugEndpoints := flag.Bool("enable-debug-endpoints", false, "Enable GET /app/debug/env (off by default; dumps process env vars)")
+	debugUnredacted := flag.Bool("debug-unredacted", false, "Disable SECRET/TOKEN/PASSWORD/KEY redaction on /app/debug/env")
 	flag.Parse()
 
 	// Load Config
@@ -30,20 +48,29 @@ func main() {
 		log.Fatalf("Fatal Error: Could not load %s: %v", *configPath, err)
 	}
 
-	// Setup logging
+	// Setup logging: JSON records to logs/app.log for machines, plain text
+	// to stderr for whoever is watching the terminal. Internal packages
+	// still log through the stdlib "log" package, so point that at the
+	// same file (as plain text) rather than rewriting every call site.
 	os.MkdirAll("logs", 0755)
 	logFile, err := os.OpenFile("logs/app.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	var logger *slog.Logger
 	if err == nil {
-		mw := io.MultiWriter(os.Stderr, logFile)
-		log.SetOutput(mw)
-		log.Printf("Logging to logs/app.log")
+		log.SetOutput(logFile)
+		logger = slog.New(newFanoutHandler(
+			slog.NewJSONHandler(logFile, nil),
+			slog.NewTextHandler(os.Stderr, nil),
+		))
 	} else {
-		log.Printf("Warning: Failed to open log file: %v", err)
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+		logger.Warn("failed to open log file, logging to stderr only", "error", err)
 	}
+	slog.SetDefault(logger)
+	slog.Info("logging to logs/app.log")
 
 	if cfg.Verbose {
 		banquet.SetVerbose(true)
-		log.Printf("Verbose mode enabled across repositories.")
+		slog.Info("verbose mode enabled across repositories")
 	}
 
 	// Env vars override
@@ -54,20 +81,132 @@ func main() {
 		cfg.ServeFolder = sf
 	}
 
+	// --secrets-backend overrides config.hcl's secrets_backend.type, for
+	// swapping vaults (e.g. moving to the shared Postgres/Firestore
+	// backends for an HA deployment) without editing the config file.
+	if *secretsBackend != "" {
+		if cfg.SecretsBackend == nil {
+			cfg.SecretsBackend = &config.SecretsBackendConfig{}
+		}
+		cfg.SecretsBackend.Type = *secretsBackend
+	}
+
+	// --auth and friends override config.hcl's top-level auth fields the
+	// same way --secrets-backend overrides secrets_backend.type.
+	if *authMode != "" {
+		cfg.Auth = *authMode
+	}
+	if *oidcIssuer != "" {
+		cfg.OIDCIssuer = *oidcIssuer
+	}
+	if *oidcClientID != "" {
+		cfg.OIDCClientID = *oidcClientID
+	}
+	if *oidcClientSecret != "" {
+		cfg.OIDCClientSecret = *oidcClientSecret
+	}
+	if *oidcRedirectURL != "" {
+		cfg.OIDCRedirectURL = *oidcRedirectURL
+	}
+	if *authAllowDomain != "" {
+		cfg.AuthAllowDomains = *authAllowDomain
+	}
+	if *safeMode {
+		cfg.SafeMode = true
+	}
+	if *enableDebugEndpoints {
+		cfg.EnableDebugEndpoints = true
+	}
+	if *debugUnredacted {
+		cfg.DebugUnredacted = true
+	}
+
+	var oidcCfg *server.OIDCConfig
+	if cfg.Auth == "oidc" {
+		if cfg.LocalOnly {
+			slog.Error("--auth=oidc and local_only are mutually exclusive; set local_only = false in config.hcl")
+			os.Exit(1)
+		}
+
+		var allowDomains []string
+		if cfg.AuthAllowDomains != "" {
+			allowDomains = strings.Split(cfg.AuthAllowDomains, ",")
+		}
+
+		cookieSecret := cfg.AuthCookieKey
+		if cookieSecret == "" {
+			buf := make([]byte, 32)
+			rand.Read(buf)
+			cookieSecret = hex.EncodeToString(buf)
+			slog.Warn("auth_cookie_key not set; generated an ephemeral one, existing sessions won't survive a restart")
+		}
+
+		oidcCfg = &server.OIDCConfig{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			AllowDomains: allowDomains,
+			CookieSecret: cookieSecret,
+		}
+	}
+
 	// Initialize Secrets Manager
-	secretsService, err := secrets.NewService(cfg.UserSecretsDB, cfg.SecretKey)
+	secretsService, err := secrets.NewServiceFromConfig(toSecretsBackendConfig(cfg.SecretsBackend), cfg.SecretsDB, cfg.SecretKey)
 	if err != nil {
-		log.Fatalf("Failed to initialize secrets service: %v", err)
+		slog.Error("failed to initialize secrets service", "error", err)
+		os.Exit(1)
 	}
 	defer secretsService.Close()
 
+	// ctx governs background work tied to the process's own lifetime (the
+	// VFS janitor here; the HTTP server's shutdown select below), canceled
+	// on SIGINT/SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Initialize Source/Rclone VFS Cache
-	source.Init(cfg.CacheDir)
+	source.Init(ctx, cfg.CacheDir)
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := source.Shutdown(shutdownCtx); err != nil {
+			slog.Error("error shutting down source cache", "error", err)
+		}
+	}()
 
 	// Initialize Data Manager (BigCache + MkSQLite)
-	dataManager, err := dataset.NewManager(cfg.Verbose, cfg.CacheDir)
+	dataManager, err := dataset.NewManager(cfg.Verbose, cfg.CacheDir, dataset.WithJobWorkers(cfg.JobWorkers))
 	if err != nil {
-		log.Fatalf("Failed to initialize data manager: %v", err)
+		slog.Error("failed to initialize data manager", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := dataManager.Close(closeCtx); err != nil {
+			slog.Error("error closing data manager", "error", err)
+		}
+	}()
+
+	// Watch config.hcl for dynamic-field changes (cache_dir moves, secrets
+	// backend endpoint swaps, etc) so operators don't need to restart the
+	// server for those; static fields like port still require one.
+	if cfgWatcher, err := config.NewWatcher(*configPath, cfg); err != nil {
+		slog.Warn("config hot-reload disabled", "error", err)
+	} else {
+		cfgWatcher.Subscribe(dataManager.HandleConfigChange)
+		cfgWatcher.Subscribe(func(event config.ChangeEvent) {
+			e, ok := event.(config.SecretsBackendChanged)
+			if !ok {
+				return
+			}
+			if err := secretsService.ReloadBackend(toSecretsBackendConfig(e.New), cfg.SecretsDB); err != nil {
+				slog.Error("secrets backend reload failed", "error", err)
+			}
+		})
+		go cfgWatcher.Run()
+		defer cfgWatcher.Close()
 	}
 
 	// Check if templates exist, if not create them.
@@ -76,34 +215,141 @@ func main() {
 	}
 
 	// Initialize Server
-	srv := server.NewServer(dataManager, secretsService, cfg.TemplateDir, cfg.ServeFolder, cfg.Verbose, cfg.AutoSelectTb0, cfg.LocalOnly, cfg.DefaultDB)
+	srv := server.NewServer(dataManager, secretsService, cfg.ServeFolder, cfg.Verbose, cfg.AutoSelectTb0, cfg.LocalOnly, cfg.DefaultDB, oidcCfg, cfg.SecretsDB, cfg.SafeMode, cfg.EnableDebugEndpoints, cfg.DebugUnredacted, cfg.IndexIntervalSeconds, toCORSConfig(cfg.CORS))
 
 	startPort, _ := strconv.Atoi(cfg.Port)
 	if startPort == 0 {
 		startPort = 8080
 	}
 
+	var ln net.Listener
 	var finalErr error
 	for i := 0; i < 3; i++ {
 		currentPort := strconv.Itoa(startPort + i)
-		ln, err := net.Listen("tcp", ":"+currentPort)
+		l, err := net.Listen("tcp", ":"+currentPort)
 		if err != nil {
-			log.Printf("Port %s is busy, trying next...", currentPort)
+			slog.Warn("port busy, trying next", "port", currentPort)
 			finalErr = err
 			continue
 		}
+		slog.Info("starting server", "port", currentPort)
+		ln = l
+		finalErr = nil
+		break
+	}
+	if ln == nil {
+		slog.Error("failed to start server after 3 attempts", "error", finalErr)
+		os.Exit(1)
+	}
 
-		log.Printf("Starting server on port %s", currentPort)
-		// We use http.Serve with the listener
-		finalErr = http.Serve(ln, srv.Router())
-		if finalErr != nil {
-			log.Fatalf("Server failed: %v", finalErr)
+	httpSrv := &http.Server{Handler: srv.Router()}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpSrv.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("shutdown signal received, draining in-flight requests")
+
+		shutdownTimeout := time.Duration(cfg.ShutdownTimeoutSeconds) * time.Second
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("error during graceful shutdown, forcing close", "error", err)
+			httpSrv.Close()
+		}
+	}
+
+	slog.Info("server stopped")
+}
+
+// fanoutHandler fans out every slog record to multiple handlers, so e.g.
+// JSON-to-file and text-to-stderr can run off a single logger.
+type fanoutHandler struct {
+	handlers []slog.Handler
+}
+
+func newFanoutHandler(handlers ...slog.Handler) *fanoutHandler {
+	return &fanoutHandler{handlers: handlers}
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, hh := range h.handlers {
+		if hh.Enabled(ctx, record.Level) {
+			if err := hh.Handle(ctx, record.Clone()); err != nil {
+				errs = append(errs, err)
+			}
 		}
-		return
 	}
+	return errors.Join(errs...)
+}
+
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := make([]slog.Handler, len(h.handlers))
+	for i, hh := range h.handlers {
+		next[i] = hh.WithAttrs(attrs)
+	}
+	return newFanoutHandler(next...)
+}
 
-	if finalErr != nil {
-		log.Fatalf("Failed to start server after 3 attempts: %v", finalErr)
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	next := make([]slog.Handler, len(h.handlers))
+	for i, hh := range h.handlers {
+		next[i] = hh.WithGroup(name)
+	}
+	return newFanoutHandler(next...)
+}
+
+// toCORSConfig copies the HCL-decoded cors block into cors.Config, which
+// deliberately doesn't import the config package to avoid a cycle. Returns
+// nil when cfg is nil, matching cors middleware's no-CORS-enabled default.
+func toCORSConfig(cfg *config.CORSConfig) *cors.Config {
+	if cfg == nil {
+		return nil
+	}
+	return &cors.Config{
+		AllowedOrigins:   cfg.AllowedOrigins,
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		MaxAge:           time.Duration(cfg.MaxAgeSeconds) * time.Second,
+		AllowCredentials: cfg.AllowCredentials,
+	}
+}
+
+func toSecretsBackendConfig(cfg *config.SecretsBackendConfig) *secrets.BackendConfig {
+	if cfg == nil {
+		return nil
+	}
+	return &secrets.BackendConfig{
+		Type:                cfg.Type,
+		VaultAddress:        cfg.VaultAddress,
+		VaultToken:          cfg.VaultToken,
+		VaultMount:          cfg.VaultMount,
+		VaultPrefix:         cfg.VaultPrefix,
+		DockerConfigPath:    cfg.DockerConfigPath,
+		Fallback:            cfg.Fallback,
+		PostgresDSN:         cfg.PostgresDSN,
+		FirestoreProjectID:  cfg.FirestoreProjectID,
+		FirestoreCollection: cfg.FirestoreCollection,
 	}
 }
 
@@ -0,0 +1,201 @@
+// Package apitoken issues and verifies scoped bearer tokens for the JSON
+// API, alongside internal/secrets rather than inside it: secrets stores the
+// rclone-style credential maps a token is scoped against, while this
+// package only ever needs to know the opaque token string and which
+// (alias, permission) pairs it's allowed to touch.
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Permission is one of the four actions a Scope can grant. Permissions
+// rank list < read < write < admin: a scope grants its own permission and
+// everything below it, so a write-scoped alias can also be listed and
+// read without a second scope entry.
+type Permission string
+
+const (
+	PermissionList  Permission = "list"
+	PermissionRead  Permission = "read"
+	PermissionWrite Permission = "write"
+	PermissionAdmin Permission = "admin"
+)
+
+var permissionRank = map[Permission]int{
+	PermissionList:  1,
+	PermissionRead:  2,
+	PermissionWrite: 3,
+	PermissionAdmin: 4,
+}
+
+func (p Permission) valid() bool {
+	_, ok := permissionRank[p]
+	return ok
+}
+
+// Scope grants Permission on Alias. A token's full access is the union of
+// its Scopes; there's no wildcard alias, so a token that should reach
+// every remote needs one Scope per alias.
+type Scope struct {
+	Alias      string     `json:"alias"`
+	Permission Permission `json:"permission"`
+}
+
+// TokenInfo is everything about an issued token except the token itself -
+// safe to log, list, and hand back from Authenticate.
+type TokenInfo struct {
+	ID        string    `json:"id"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Allows reports whether this token's scopes cover at least `required` on
+// alias.
+func (t TokenInfo) Allows(alias string, required Permission) bool {
+	for _, sc := range t.Scopes {
+		if sc.Alias == alias && permissionRank[sc.Permission] >= permissionRank[required] {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidToken is returned by Authenticate for a token that was never
+// issued, was revoked, or was typed in wrong. It deliberately doesn't
+// distinguish those cases, the same way a bad password doesn't say whether
+// the username existed.
+var ErrInvalidToken = errors.New("apitoken: invalid or revoked token")
+
+// Store persists issued tokens as a SHA-256 hash plus their scope set in a
+// SQLite database, mirroring how internal/secrets' sqliteBackend keeps its
+// own schema self-contained. Only the hash is ever stored; Issue is the
+// only place the raw token exists, and it isn't retrievable afterward.
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore opens (creating if needed) the token database at dbPath.
+func NewStore(dbPath string) (*Store, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("apitoken: failed to open token db: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS tokens (
+		id         TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		scopes     TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apitoken: failed to create tokens table: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Issue mints a random 32-byte token scoped to scopes and returns it
+// alongside its id. The raw token is the caller's only chance to see it -
+// only its SHA-256 hash is persisted - so it must be shown to the operator
+// immediately and isn't recoverable afterward; id is safe to log and is
+// what Revoke/List use to refer to the token without the secret.
+func (s *Store) Issue(scopes []Scope) (token, id string, err error) {
+	for _, sc := range scopes {
+		if !sc.Permission.valid() {
+			return "", "", fmt.Errorf("apitoken: invalid permission %q", sc.Permission)
+		}
+	}
+
+	var tokenBuf [32]byte
+	if _, err := rand.Read(tokenBuf[:]); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(tokenBuf[:])
+
+	var idBuf [8]byte
+	if _, err := rand.Read(idBuf[:]); err != nil {
+		return "", "", err
+	}
+	id = hex.EncodeToString(idBuf[:])
+
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := s.db.Exec(`INSERT INTO tokens (id, token_hash, scopes, created_at) VALUES (?, ?, ?, ?)`,
+		id, hashToken(token), string(scopesJSON), time.Now()); err != nil {
+		return "", "", fmt.Errorf("apitoken: failed to store token: %w", err)
+	}
+	return token, id, nil
+}
+
+// Revoke deletes the token with the given id. Revoking an id that doesn't
+// exist is not an error, matching secrets.Backend.Delete's semantics.
+func (s *Store) Revoke(id string) error {
+	_, err := s.db.Exec(`DELETE FROM tokens WHERE id = ?`, id)
+	return err
+}
+
+// List returns every issued token's metadata (not the secret), ordered
+// newest first, for the /tokens/manage UI.
+func (s *Store) List() ([]TokenInfo, error) {
+	rows, err := s.db.Query(`SELECT id, scopes, created_at FROM tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []TokenInfo
+	for rows.Next() {
+		var info TokenInfo
+		var scopesJSON string
+		if err := rows.Scan(&info.ID, &scopesJSON, &info.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(scopesJSON), &info.Scopes); err != nil {
+			return nil, fmt.Errorf("apitoken: corrupt scopes for token %s: %w", info.ID, err)
+		}
+		tokens = append(tokens, info)
+	}
+	return tokens, rows.Err()
+}
+
+// Authenticate looks up the token presented by a caller and returns its
+// scope set, or ErrInvalidToken if it doesn't match any issued, unrevoked
+// token.
+func (s *Store) Authenticate(token string) (TokenInfo, error) {
+	var info TokenInfo
+	var scopesJSON string
+	err := s.db.QueryRow(`SELECT id, scopes, created_at FROM tokens WHERE token_hash = ?`, hashToken(token)).
+		Scan(&info.ID, &scopesJSON, &info.CreatedAt)
+	if err == sql.ErrNoRows {
+		return TokenInfo{}, ErrInvalidToken
+	}
+	if err != nil {
+		return TokenInfo{}, err
+	}
+	if err := json.Unmarshal([]byte(scopesJSON), &info.Scopes); err != nil {
+		return TokenInfo{}, fmt.Errorf("apitoken: corrupt scopes for token %s: %w", info.ID, err)
+	}
+	return info, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package apitoken
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	store, err := NewStore(filepath.Join(t.TempDir(), "tokens.db"))
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStore_IssueAndAuthenticate(t *testing.T) {
+	store := newTestStore(t)
+
+	token, id, err := store.Issue([]Scope{{Alias: "s3-bucket", Permission: PermissionRead}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if token == "" || id == "" {
+		t.Fatalf("Issue returned empty token or id")
+	}
+
+	info, err := store.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if info.ID != id {
+		t.Errorf("Authenticate id = %q, want %q", info.ID, id)
+	}
+	if !info.Allows("s3-bucket", PermissionRead) {
+		t.Error("expected token to allow read on s3-bucket")
+	}
+	if !info.Allows("s3-bucket", PermissionList) {
+		t.Error("expected read scope to also allow list (lower-ranked permission)")
+	}
+	if info.Allows("s3-bucket", PermissionWrite) {
+		t.Error("expected read scope to not allow write")
+	}
+	if info.Allows("other-alias", PermissionList) {
+		t.Error("expected token to not cover an unscoped alias")
+	}
+}
+
+func TestStore_Authenticate_InvalidToken(t *testing.T) {
+	store := newTestStore(t)
+
+	if _, err := store.Authenticate("not-a-real-token"); err != ErrInvalidToken {
+		t.Errorf("Authenticate(bogus) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestStore_Revoke(t *testing.T) {
+	store := newTestStore(t)
+
+	token, id, err := store.Issue([]Scope{{Alias: "a", Permission: PermissionAdmin}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	if err := store.Revoke(id); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+
+	if _, err := store.Authenticate(token); err != ErrInvalidToken {
+		t.Errorf("Authenticate(revoked) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	store := newTestStore(t)
+
+	_, id1, err := store.Issue([]Scope{{Alias: "a", Permission: PermissionList}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	_, id2, err := store.Issue([]Scope{{Alias: "b", Permission: PermissionWrite}})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	tokens, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(tokens) != 2 {
+		t.Fatalf("List returned %d tokens, want 2", len(tokens))
+	}
+
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		seen[tok.ID] = true
+	}
+	if !seen[id1] || !seen[id2] {
+		t.Errorf("List = %+v, missing one of %q, %q", tokens, id1, id2)
+	}
+}
+
+func TestStore_Issue_RejectsInvalidPermission(t *testing.T) {
+	store := newTestStore(t)
+
+	if _, _, err := store.Issue([]Scope{{Alias: "a", Permission: "delete-everything"}}); err == nil {
+		t.Error("expected Issue to reject an unrecognized permission")
+	}
+}
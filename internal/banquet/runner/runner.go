@@ -0,0 +1,424 @@
+// Package runner drives banquet.ParseNested over a batch of URLs sourced
+// from a SQLite file, a newline-delimited list (local or remote via
+// source.GetFileStream), or an HTTP sitemap, and writes the parsed results
+// either to a local SQLite file or to a remote via source.PutFileStream.
+// It exists so the one-off logic in TestURLParsing - sequentially reading
+// and writing a single local SQLite file - can run against any backend
+// the source package knows about, and at worker-pool concurrency instead
+// of one URL at a time.
+package runner
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"flight2/internal/source"
+
+	"github.com/darianmavgo/banquet"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// LinkSource identifies where JobSpec.Links loads its URLs from.
+type LinkSource string
+
+const (
+	// LinkSourceSQLite reads links from a local SQLite file's test_links
+	// table, matching TestURLParsing's schema (id, url columns).
+	LinkSourceSQLite LinkSource = "sqlite"
+	// LinkSourceList reads newline-delimited URLs via source.GetFileStream,
+	// so e.g. "s3:bucket/urls.txt" works as well as a local path.
+	LinkSourceList LinkSource = "list"
+	// LinkSourceSitemap reads <loc> entries from an HTTP sitemap XML.
+	LinkSourceSitemap LinkSource = "sitemap"
+)
+
+// ResultSink identifies where RunParseJob writes its parsed results.
+type ResultSink string
+
+const (
+	// ResultSinkSource streams newline-delimited JSON results through
+	// source.PutFileStream to Dest.
+	ResultSinkSource ResultSink = "source"
+	// ResultSinkSQLite appends results to a local SQLite file's
+	// test_run_timestamp table via a prepared-statement batcher, matching
+	// TestURLParsing's schema.
+	ResultSinkSQLite ResultSink = "sqlite"
+)
+
+// JobSpec configures one RunParseJob run.
+type JobSpec struct {
+	// LinksFrom selects how Links below is interpreted.
+	LinksFrom LinkSource
+	// Links is the SQLite path (LinkSourceSQLite), the list spec or
+	// SourceRef path (LinkSourceList), or the sitemap URL (LinkSourceSitemap).
+	Links string
+	// LinksRef resolves Links through the source package for
+	// LinkSourceList instead of treating it as a bare local path. Zero
+	// value means Links is a local filesystem path.
+	LinksRef *source.SourceRef
+
+	// WriteTo selects how results below are persisted.
+	WriteTo ResultSink
+	// Dest is the destination SourceRef for ResultSinkSource; SQLitePath
+	// is the local SQLite path for ResultSinkSQLite.
+	Dest       source.SourceRef
+	SQLitePath string
+
+	// Concurrency bounds how many URLs banquet.ParseNested processes at
+	// once. <= 0 defaults to 4, matching dataset.WithJobWorkers' default.
+	Concurrency int
+	// PerURLTimeout bounds how long a single ParseNested call may run.
+	// <= 0 means no per-URL timeout.
+	PerURLTimeout time.Duration
+}
+
+// result is one URL's parse outcome, serialized as a line of
+// ResultSinkSource's output and as a row of ResultSinkSQLite's.
+type result struct {
+	LinkID    int64            `json:"link_id,omitempty"`
+	URL       string           `json:"url"`
+	Banquet   *banquet.Banquet `json:"banquet,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Latency   time.Duration    `json:"latency_ns"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// Report summarizes a RunParseJob run: how many URLs succeeded or failed,
+// how long parsing took, and what kinds of errors showed up.
+type Report struct {
+	Total       int            `json:"total"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	P50Latency  time.Duration  `json:"p50_latency_ns"`
+	P95Latency  time.Duration  `json:"p95_latency_ns"`
+	ErrorCounts map[string]int `json:"error_counts,omitempty"`
+}
+
+// link is one URL pulled from a JobSpec's LinksFrom, carrying its source
+// row ID when one exists (LinkSourceSQLite) so ResultSinkSQLite can
+// reference it back.
+type link struct {
+	ID  int64
+	URL string
+}
+
+// RunParseJob loads spec's links, runs banquet.ParseNested over them on a
+// worker pool sized by spec.Concurrency, and writes the results to
+// spec.WriteTo. It returns a Report and the first error encountered
+// loading links or writing results - per-URL parse errors are recorded in
+// the results themselves and in Report.ErrorCounts, not returned here.
+func RunParseJob(ctx context.Context, spec JobSpec) (Report, error) {
+	links, err := loadLinks(ctx, spec)
+	if err != nil {
+		return Report{}, fmt.Errorf("runner: loading links: %w", err)
+	}
+
+	results := parseAll(ctx, spec, links)
+
+	if err := writeResults(ctx, spec, results); err != nil {
+		return buildReport(results), fmt.Errorf("runner: writing results: %w", err)
+	}
+	return buildReport(results), nil
+}
+
+func loadLinks(ctx context.Context, spec JobSpec) ([]link, error) {
+	switch spec.LinksFrom {
+	case LinkSourceSQLite:
+		return loadLinksSQLite(spec.Links)
+	case LinkSourceList:
+		return loadLinksList(ctx, spec)
+	case LinkSourceSitemap:
+		return loadLinksSitemap(ctx, spec.Links)
+	default:
+		return nil, fmt.Errorf("runner: unknown LinksFrom %q", spec.LinksFrom)
+	}
+}
+
+func loadLinksSQLite(dbPath string) ([]link, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", dbPath, err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT id, url FROM test_links")
+	if err != nil {
+		return nil, fmt.Errorf("query test_links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.ID, &l.URL); err != nil {
+			return nil, fmt.Errorf("scan test_links row: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+func loadLinksList(ctx context.Context, spec JobSpec) ([]link, error) {
+	var rc io.ReadCloser
+	if spec.LinksRef != nil {
+		ref := *spec.LinksRef
+		ref.Path = spec.Links
+		r, err := source.GetFileStream(ctx, ref)
+		if err != nil {
+			return nil, fmt.Errorf("source.GetFileStream %s: %w", spec.Links, err)
+		}
+		rc = r
+	} else {
+		f, err := os.Open(spec.Links)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", spec.Links, err)
+		}
+		rc = f
+	}
+	defer rc.Close()
+
+	var links []link
+	sc := bufio.NewScanner(rc)
+	for sc.Scan() {
+		url := sc.Text()
+		if url == "" {
+			continue
+		}
+		links = append(links, link{URL: url})
+	}
+	return links, sc.Err()
+}
+
+// sitemapXML mirrors the subset of the sitemap protocol RunParseJob needs
+// - a flat list of <loc> entries - without pulling in a full sitemap
+// library for one field.
+type sitemapXML struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+func loadLinksSitemap(ctx context.Context, sitemapURL string) ([]link, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build sitemap request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch sitemap %s: %w", sitemapURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch sitemap %s: status %d", sitemapURL, resp.StatusCode)
+	}
+
+	var sm sitemapXML
+	if err := xml.NewDecoder(resp.Body).Decode(&sm); err != nil {
+		return nil, fmt.Errorf("decode sitemap %s: %w", sitemapURL, err)
+	}
+
+	links := make([]link, 0, len(sm.URLs))
+	for _, u := range sm.URLs {
+		if u.Loc == "" {
+			continue
+		}
+		links = append(links, link{URL: u.Loc})
+	}
+	return links, nil
+}
+
+// parseAll runs banquet.ParseNested over links on a worker pool bounded by
+// spec.Concurrency, matching dataset's jobQueue/index's crawl sem shape.
+// Each link carries its slice index so workers write results without a
+// mutex.
+func parseAll(ctx context.Context, spec JobSpec, links []link) []result {
+	workers := spec.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+
+	type indexed struct {
+		idx int
+		l   link
+	}
+	queue := make(chan indexed)
+	results := make([]result, len(links))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range queue {
+				results[it.idx] = parseOne(ctx, spec.PerURLTimeout, it.l)
+			}
+		}()
+	}
+	for i, l := range links {
+		queue <- indexed{idx: i, l: l}
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+func parseOne(ctx context.Context, perURLTimeout time.Duration, l link) result {
+	if perURLTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, perURLTimeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	bq, err := parseNestedCtx(ctx, l.URL)
+	r := result{
+		LinkID:    l.ID,
+		URL:       l.URL,
+		Latency:   time.Since(start),
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Banquet = bq
+	}
+	return r
+}
+
+// parseNestedCtx calls banquet.ParseNested on a goroutine so a
+// PerURLTimeout can abandon it rather than block a worker forever on a
+// pathological URL; ParseNested itself takes no context.
+func parseNestedCtx(ctx context.Context, url string) (*banquet.Banquet, error) {
+	type out struct {
+		bq  *banquet.Banquet
+		err error
+	}
+	ch := make(chan out, 1)
+	go func() {
+		bq, err := banquet.ParseNested(url)
+		ch <- out{bq, err}
+	}()
+	select {
+	case o := <-ch:
+		return o.bq, o.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func writeResults(ctx context.Context, spec JobSpec, results []result) error {
+	switch spec.WriteTo {
+	case ResultSinkSource:
+		return writeResultsSource(ctx, spec.Dest, results)
+	case ResultSinkSQLite:
+		return writeResultsSQLite(spec.SQLitePath, results)
+	default:
+		return fmt.Errorf("runner: unknown WriteTo %q", spec.WriteTo)
+	}
+}
+
+// writeResultsSource streams results as newline-delimited JSON through
+// source.PutFileStream via an io.Pipe, so the encoder writes directly into
+// the upload instead of buffering the whole batch in memory first.
+func writeResultsSource(ctx context.Context, dest source.SourceRef, results []result) error {
+	pr, pw := io.Pipe()
+
+	encErr := make(chan error, 1)
+	go func() {
+		enc := json.NewEncoder(pw)
+		for _, r := range results {
+			if err := enc.Encode(r); err != nil {
+				pw.CloseWithError(err)
+				encErr <- err
+				return
+			}
+		}
+		pw.Close()
+		encErr <- nil
+	}()
+
+	if err := source.PutFileStream(ctx, dest, pr); err != nil {
+		return err
+	}
+	return <-encErr
+}
+
+// writeResultsSQLite appends results to dbPath's test_run_timestamp table
+// via a single prepared statement, matching TestURLParsing's schema and
+// insert shape.
+func writeResultsSQLite(dbPath string, results []result) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dbPath, err)
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("INSERT INTO test_run_timestamp (test_link_id, parsed_result, error, timestamp) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("prepare insert statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range results {
+		var resultJSON sql.NullString
+		if r.Banquet != nil {
+			b, err := json.Marshal(r.Banquet)
+			if err == nil {
+				resultJSON = sql.NullString{String: string(b), Valid: true}
+			} else {
+				r.Error = fmt.Sprintf("JSON marshal error: %v", err)
+			}
+		}
+		var errorStr sql.NullString
+		if r.Error != "" {
+			errorStr = sql.NullString{String: r.Error, Valid: true}
+		}
+		if _, err := stmt.Exec(r.LinkID, resultJSON, errorStr, r.Timestamp); err != nil {
+			return fmt.Errorf("insert result for %s: %w", r.URL, err)
+		}
+	}
+	return nil
+}
+
+func buildReport(results []result) Report {
+	rep := Report{Total: len(results), ErrorCounts: make(map[string]int)}
+	latencies := make([]time.Duration, 0, len(results))
+	for _, r := range results {
+		latencies = append(latencies, r.Latency)
+		if r.Error != "" {
+			rep.Failed++
+			rep.ErrorCounts[r.Error]++
+		} else {
+			rep.Succeeded++
+		}
+	}
+	if len(rep.ErrorCounts) == 0 {
+		rep.ErrorCounts = nil
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	rep.P50Latency = percentile(latencies, 0.50)
+	rep.P95Latency = percentile(latencies, 0.95)
+	return rep
+}
+
+// percentile returns the p-th percentile of sorted (ascending), or 0 if
+// sorted is empty.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p * float64(len(sorted)-1))
+	return sorted[idx]
+}
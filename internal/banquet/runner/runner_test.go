@@ -0,0 +1,89 @@
+package runner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flight2/internal/source"
+)
+
+// Type: Integration Test
+//
+// TestRunParseJob_SourceRoundTrip exercises the LinkSourceList/
+// ResultSinkSource paths end to end through the source package, reading
+// URLs from and writing results to a "local" backend profile - the same
+// stand-in the source package's own registry_test.go uses in place of a
+// real cloud profile (s3, gdrive, ...), since LinkSourceList/
+// ResultSinkSource go through source.GetFileStream/PutFileStream either
+// way and don't care which backend resolves them.
+func TestRunParseJob_SourceRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	linksPath := filepath.Join(dir, "urls.txt")
+	outPath := filepath.Join(dir, "results.ndjson")
+
+	urls := []string{
+		"https://example.com/bucket/dataset.sqlite/table",
+		"https://example.com/other/dataset.sqlite/other_table",
+	}
+	content := ""
+	for _, u := range urls {
+		content += u + "\n"
+	}
+	if err := os.WriteFile(linksPath, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	const profileID = "runner-test-local"
+	source.RegisterProfile(profileID, map[string]interface{}{"type": "local"})
+	t.Cleanup(func() { source.InvalidateProfile(profileID) })
+
+	ctx := context.Background()
+	spec := JobSpec{
+		LinksFrom:   LinkSourceList,
+		Links:       linksPath,
+		LinksRef:    &source.SourceRef{ProfileID: profileID},
+		WriteTo:     ResultSinkSource,
+		Dest:        source.SourceRef{ProfileID: profileID, Path: outPath},
+		Concurrency: 2,
+	}
+
+	rep, err := RunParseJob(ctx, spec)
+	if err != nil {
+		t.Fatalf("RunParseJob: %v", err)
+	}
+	if rep.Total != len(urls) {
+		t.Fatalf("Total = %d, want %d", rep.Total, len(urls))
+	}
+	if rep.Succeeded != len(urls) {
+		t.Errorf("Succeeded = %d, want %d (errors: %v)", rep.Succeeded, len(urls), rep.ErrorCounts)
+	}
+
+	f, err := os.Open(outPath)
+	if err != nil {
+		t.Fatalf("Open results: %v", err)
+	}
+	defer f.Close()
+
+	var got int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var r result
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("unmarshal result line: %v", err)
+		}
+		if r.URL == "" {
+			t.Error("result line missing url")
+		}
+		got++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("scan results: %v", err)
+	}
+	if got != len(urls) {
+		t.Errorf("result lines = %d, want %d", got, len(urls))
+	}
+}
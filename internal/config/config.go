@@ -20,6 +20,87 @@ type Config struct {
 	LocalOnly     bool   `json:"local_only" hcl:"local_only,optional"`
 	DefaultDB     string `json:"default_db" hcl:"default_db,optional"`
 	CacheDir      string `json:"cache_dir" hcl:"cache_dir,optional"`
+	JobWorkers    int    `json:"job_workers" hcl:"job_workers,optional"`
+
+	// ShutdownTimeoutSeconds bounds how long the server waits for in-flight
+	// requests to drain during a graceful shutdown before forcing close.
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds" hcl:"shutdown_timeout_seconds,optional"`
+
+	// IndexIntervalSeconds sets how often the server background-crawls
+	// every configured alias to refresh the search index (see
+	// internal/index). 0 (the default) disables periodic reindexing;
+	// aliases can still be reindexed on demand via the "Reindex" button.
+	IndexIntervalSeconds int `json:"index_interval_seconds" hcl:"index_interval_seconds,optional"`
+
+	SecretsBackend *SecretsBackendConfig `json:"secrets_backend" hcl:"secrets_backend,block"`
+
+	// Auth selects the /app/* access-control mode: "" (the default) relies
+	// solely on LocalOnly, "oidc" requires an OIDC login instead and can't
+	// be combined with LocalOnly (the server refuses to start with both).
+	Auth             string `json:"auth" hcl:"auth,optional"`
+	OIDCIssuer       string `json:"oidc_issuer" hcl:"oidc_issuer,optional"`
+	OIDCClientID     string `json:"oidc_client_id" hcl:"oidc_client_id,optional"`
+	OIDCClientSecret string `json:"oidc_client_secret" hcl:"oidc_client_secret,optional"`
+	OIDCRedirectURL  string `json:"oidc_redirect_url" hcl:"oidc_redirect_url,optional"`
+	AuthAllowDomains string `json:"auth_allow_domains" hcl:"auth_allow_domains,optional"`
+	AuthCookieKey    string `json:"auth_cookie_key" hcl:"auth_cookie_key,optional"`
+
+	// SafeMode additionally protects sqlite_master from the catch-all
+	// handler, so a request can't enumerate table names it has no alias
+	// for. See server.Server.protectedPaths.
+	SafeMode bool `json:"safe_mode" hcl:"safe_mode,optional"`
+
+	// EnableDebugEndpoints turns on GET /app/debug/env, off by default
+	// because it dumps the process's environment variables.
+	EnableDebugEndpoints bool `json:"enable_debug_endpoints" hcl:"enable_debug_endpoints,optional"`
+
+	// DebugUnredacted disables the SECRET/TOKEN/PASSWORD/KEY substring
+	// redaction pass over /app/debug/env. Only takes effect alongside
+	// EnableDebugEndpoints.
+	DebugUnredacted bool `json:"debug_unredacted" hcl:"debug_unredacted,optional"`
+
+	// CORS configures cross-origin access to /api/v1/* and /dav/* for
+	// browser SPAs. Absent (the default), no origin is allowed and those
+	// endpoints behave exactly as same-origin-only as the rest of the app.
+	CORS *CORSConfig `json:"cors" hcl:"cors,block"`
+}
+
+// CORSConfig is decoded straight into cors.Config by the caller building
+// the server (see cmd/server/main.go) - it stays a plain config DTO here
+// rather than importing internal/cors, the same way SecretsBackendConfig
+// doesn't import internal/secrets.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins" hcl:"allowed_origins,optional"`
+	AllowedMethods   []string `json:"allowed_methods" hcl:"allowed_methods,optional"`
+	AllowedHeaders   []string `json:"allowed_headers" hcl:"allowed_headers,optional"`
+	MaxAgeSeconds    int      `json:"max_age_seconds" hcl:"max_age_seconds,optional"`
+	AllowCredentials bool     `json:"allow_credentials" hcl:"allow_credentials,optional"`
+}
+
+// SecretsBackendConfig selects and configures the secrets.Backend used by
+// secrets.NewServiceFromConfig. When absent, the SQLite backend at
+// Config.SecretsDB is used.
+type SecretsBackendConfig struct {
+	Type string `json:"type" hcl:"type,optional"`
+
+	// Vault fields
+	VaultAddress string `json:"vault_address" hcl:"vault_address,optional"`
+	VaultToken   string `json:"vault_token" hcl:"vault_token,optional"`
+	VaultMount   string `json:"vault_mount" hcl:"vault_mount,optional"`
+	VaultPrefix  string `json:"vault_prefix" hcl:"vault_prefix,optional"`
+
+	// DockerConfigPath overrides ~/.docker/config.json for the "docker"
+	// credential-helper source, and Fallback lists additional backend types
+	// ("docker", "env") to try, in order, when the primary type misses.
+	DockerConfigPath string   `json:"docker_config_path" hcl:"docker_config_path,optional"`
+	Fallback         []string `json:"fallback" hcl:"fallback,optional"`
+
+	// Postgres fields
+	PostgresDSN string `json:"postgres_dsn" hcl:"postgres_dsn,optional"`
+
+	// Firestore fields
+	FirestoreProjectID  string `json:"firestore_project_id" hcl:"firestore_project_id,optional"`
+	FirestoreCollection string `json:"firestore_collection" hcl:"firestore_collection,optional"`
 }
 
 func LoadConfig(filename string) (*Config, error) {
@@ -32,6 +113,9 @@ func LoadConfig(filename string) (*Config, error) {
 		LocalOnly:     true,
 		DefaultDB:     "app.sqlite",
 		CacheDir:      "cache",
+		JobWorkers:    4,
+
+		ShutdownTimeoutSeconds: 15,
 	}
 
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -70,6 +154,9 @@ func LoadConfig(filename string) (*Config, error) {
 	if config.SecretKey == "" {
 		config.SecretKey = ".secret.key"
 	}
+	if config.ShutdownTimeoutSeconds <= 0 {
+		config.ShutdownTimeoutSeconds = 15
+	}
 
 	return &config, nil
 }
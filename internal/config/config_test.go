@@ -54,4 +54,7 @@ func TestLoadConfigMissing(t *testing.T) {
 	if cfg.Port != "8080" {
 		t.Errorf("Expected default Port 8080, got %s", cfg.Port)
 	}
+	if cfg.ShutdownTimeoutSeconds != 15 {
+		t.Errorf("Expected default ShutdownTimeoutSeconds 15, got %d", cfg.ShutdownTimeoutSeconds)
+	}
 }
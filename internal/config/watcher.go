@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ChangeEvent is dispatched to Watcher subscribers when a reload detects a
+// field change. Each concrete event names the field it came from so a
+// subscriber can type-switch on the ones it cares about and ignore the
+// rest.
+type ChangeEvent interface {
+	configChangeEvent()
+}
+
+type CacheDirChanged struct{ Old, New string }
+type SecretsDBChanged struct{ Old, New string }
+type SecretKeyChanged struct{ Old, New string }
+type ServeFolderChanged struct{ Old, New string }
+type TemplateDirChanged struct{ Old, New string }
+type DefaultDBChanged struct{ Old, New string }
+type SecretsBackendChanged struct{ Old, New *SecretsBackendConfig }
+
+func (CacheDirChanged) configChangeEvent()       {}
+func (SecretsDBChanged) configChangeEvent()      {}
+func (SecretKeyChanged) configChangeEvent()      {}
+func (ServeFolderChanged) configChangeEvent()    {}
+func (TemplateDirChanged) configChangeEvent()    {}
+func (DefaultDBChanged) configChangeEvent()      {}
+func (SecretsBackendChanged) configChangeEvent() {}
+
+// staticFieldWarnings names Config fields that cannot be applied without a
+// restart (the listener is already bound, the cache's in-process index was
+// already sized, etc). A reload that changes one of these logs the warning
+// below instead of dispatching an event, mirroring how MinIO distinguishes
+// dynamic from static settings in its server config.
+var staticFieldWarnings = map[string]string{
+	"port": "port changed in config but the listener is already bound; restart the server to pick it up",
+}
+
+// Handler receives config change events from a Watcher. Handlers run
+// synchronously on the watcher's goroutine, so anything that can block
+// (draining in-flight work, reopening a connection) should hand off to a
+// goroutine of its own rather than doing it inline.
+type Handler func(ChangeEvent)
+
+// Watcher fsnotifies a config file, re-parses it on change, diffs the
+// result field-by-field against the previously loaded Config, and
+// dispatches typed change events to subscribers.
+type Watcher struct {
+	path string
+
+	mu      sync.Mutex
+	current *Config
+	subs    []Handler
+
+	fsw *fsnotify.Watcher
+}
+
+// NewWatcher creates a Watcher for path, seeded with the already-loaded
+// initial config (typically the result of the startup LoadConfig call).
+func NewWatcher(path string, initial *Config) (*Watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	if err := fsw.Add(path); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", path, err)
+	}
+	return &Watcher{
+		path:    path,
+		current: initial,
+		fsw:     fsw,
+	}, nil
+}
+
+// Subscribe registers fn to receive every change event dispatched by
+// future reloads.
+func (w *Watcher) Subscribe(fn Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subs = append(w.subs, fn)
+}
+
+// Run blocks processing fsnotify events until the watcher is closed. Call
+// it from its own goroutine.
+func (w *Watcher) Run() {
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			// Editors frequently replace the file (write to a temp name,
+			// then rename over it) instead of writing in place, so react
+			// to anything that could mean new content landed on disk.
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			w.reload()
+		case err, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("config watcher error: %v", err)
+		}
+	}
+}
+
+// Close stops watching the config file.
+func (w *Watcher) Close() error {
+	return w.fsw.Close()
+}
+
+func (w *Watcher) reload() {
+	next, err := LoadConfig(w.path)
+	if err != nil {
+		log.Printf("config reload failed, keeping previous config: %v", err)
+		return
+	}
+
+	w.mu.Lock()
+	prev := w.current
+	w.current = next
+	subs := append([]Handler(nil), w.subs...)
+	w.mu.Unlock()
+
+	for _, event := range diffConfig(prev, next) {
+		for _, sub := range subs {
+			sub(event)
+		}
+	}
+}
+
+// diffConfig compares two Configs field-by-field and returns the change
+// events for fields that may be changed dynamically. Fields listed in
+// staticFieldWarnings are logged instead of dispatched.
+func diffConfig(old, next *Config) []ChangeEvent {
+	var events []ChangeEvent
+
+	if old.Port != next.Port {
+		log.Printf("config: %s", staticFieldWarnings["port"])
+	}
+	if old.CacheDir != next.CacheDir {
+		events = append(events, CacheDirChanged{Old: old.CacheDir, New: next.CacheDir})
+	}
+	if old.SecretsDB != next.SecretsDB {
+		events = append(events, SecretsDBChanged{Old: old.SecretsDB, New: next.SecretsDB})
+	}
+	if old.SecretKey != next.SecretKey {
+		events = append(events, SecretKeyChanged{Old: old.SecretKey, New: next.SecretKey})
+	}
+	if old.ServeFolder != next.ServeFolder {
+		events = append(events, ServeFolderChanged{Old: old.ServeFolder, New: next.ServeFolder})
+	}
+	if old.TemplateDir != next.TemplateDir {
+		events = append(events, TemplateDirChanged{Old: old.TemplateDir, New: next.TemplateDir})
+	}
+	if old.DefaultDB != next.DefaultDB {
+		events = append(events, DefaultDBChanged{Old: old.DefaultDB, New: next.DefaultDB})
+	}
+	if !secretsBackendEqual(old.SecretsBackend, next.SecretsBackend) {
+		events = append(events, SecretsBackendChanged{Old: old.SecretsBackend, New: next.SecretsBackend})
+	}
+
+	return events
+}
+
+func secretsBackendEqual(a, b *SecretsBackendConfig) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type != b.Type || a.VaultAddress != b.VaultAddress || a.VaultToken != b.VaultToken ||
+		a.VaultMount != b.VaultMount || a.VaultPrefix != b.VaultPrefix ||
+		a.DockerConfigPath != b.DockerConfigPath || a.PostgresDSN != b.PostgresDSN ||
+		a.FirestoreProjectID != b.FirestoreProjectID || a.FirestoreCollection != b.FirestoreCollection ||
+		len(a.Fallback) != len(b.Fallback) {
+		return false
+	}
+	for i := range a.Fallback {
+		if a.Fallback[i] != b.Fallback[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+// Package cors wraps an http.Handler with browser CORS preflight and
+// simple-request handling, for the token-based JSON API (internal/server's
+// /api/v1/*) and WebDAV endpoint (/dav/*) to be callable from a browser
+// SPA on a different origin.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config controls which origins, methods and headers a CORS-wrapped
+// handler accepts. The zero Config allows no origins - every request
+// passes through unmodified and the browser enforces same-origin as usual.
+type Config struct {
+	// AllowedOrigins lists origins allowed to make cross-origin requests.
+	// An entry of "*" matches any origin; an entry starting with "*."
+	// matches any subdomain of the rest (e.g. "*.example.com" matches
+	// "https://app.example.com" but not "https://example.com" itself).
+	AllowedOrigins []string
+
+	// AllowedMethods and AllowedHeaders are echoed back verbatim on a
+	// preflight response's Access-Control-Allow-Methods/-Headers.
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// MaxAge sets how long a browser may cache a preflight response
+	// before issuing another OPTIONS request.
+	MaxAge time.Duration
+
+	// AllowCredentials sets Access-Control-Allow-Credentials: true on
+	// every allowed response. Per the CORS spec a credentialed request
+	// can never be satisfied by a wildcard Access-Control-Allow-Origin,
+	// so Middleware always echoes the exact matched origin - never "*" -
+	// regardless of this flag.
+	AllowCredentials bool
+}
+
+// originAllowed reports whether origin matches one of c.AllowedOrigins.
+func (c *Config) originAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+		if allowed == origin {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(origin, "."+suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Middleware wraps next with CORS handling: a preflight OPTIONS request
+// (one carrying Access-Control-Request-Method) from an allowed origin is
+// answered directly with the configured Allow-* headers and never reaches
+// next; any other request from an allowed origin gets
+// Access-Control-Allow-Origin plus Vary: Origin added before falling
+// through to next. A request from a disallowed origin - or with no Origin
+// header at all, i.e. not a cross-origin request - passes through
+// untouched, leaving the browser to enforce same-origin as it would
+// without this middleware.
+func (c *Config) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !c.originAllowed(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if c.AllowCredentials {
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if len(c.AllowedMethods) > 0 {
+				w.Header().Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+			}
+			if len(c.AllowedHeaders) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+			}
+			if c.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Type: Unit Test
+func TestMiddleware_SimpleRequest(t *testing.T) {
+	tests := []struct {
+		name             string
+		allowedOrigins   []string
+		allowCredentials bool
+		origin           string
+		wantAllowOrigin  string
+		wantAllowCreds   string
+		wantVary         bool
+	}{
+		{"exact match", []string{"https://app.example.com"}, false, "https://app.example.com", "https://app.example.com", "", true},
+		{"wildcard star", []string{"*"}, false, "https://anything.test", "https://anything.test", "", true},
+		{"suffix wildcard match", []string{"*.example.com"}, false, "https://app.example.com", "https://app.example.com", "", true},
+		{"suffix wildcard no match", []string{"*.example.com"}, false, "https://evil.test", "", "", false},
+		{"no match", []string{"https://app.example.com"}, false, "https://evil.test", "", "", false},
+		{"no origin header", []string{"*"}, false, "", "", "", false},
+		{"credentialed request with wildcard echoes exact origin", []string{"*"}, true, "https://app.example.com", "https://app.example.com", "true", true},
+		{"credentialed request with exact match", []string{"https://app.example.com"}, true, "https://app.example.com", "https://app.example.com", "true", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{AllowedOrigins: tt.allowedOrigins, AllowCredentials: tt.allowCredentials}
+			handler := cfg.Middleware(okHandler())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/remotes", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantAllowOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantAllowCreds {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantAllowCreds)
+			}
+			hasVary := false
+			for _, v := range rec.Header().Values("Vary") {
+				if v == "Origin" {
+					hasVary = true
+				}
+			}
+			if hasVary != tt.wantVary {
+				t.Errorf("Vary: Origin present = %v, want %v", hasVary, tt.wantVary)
+			}
+		})
+	}
+}
+
+// Type: Unit Test
+func TestMiddleware_Preflight(t *testing.T) {
+	cfg := &Config{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		MaxAge:           10 * time.Minute,
+		AllowCredentials: true,
+	}
+	var reachedNext bool
+	handler := cfg.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reachedNext = true
+	}))
+
+	req := httptest.NewRequest(http.MethodOptions, "/dav/myremote/file.txt", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if reachedNext {
+		t.Fatal("preflight request reached the wrapped handler")
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, "https://app.example.com")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, PUT, DELETE" {
+		t.Errorf("Access-Control-Allow-Methods = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Authorization, Content-Type" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+	}
+}
+
+// Type: Unit Test
+func TestMiddleware_DisallowedOriginGetsNoHeaders(t *testing.T) {
+	cfg := &Config{AllowedOrigins: []string{"https://app.example.com"}}
+	handler := cfg.Middleware(okHandler())
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/v1/remotes", nil)
+	req.Header.Set("Origin", "https://evil.test")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin got Access-Control-Allow-Origin = %q", rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+	// Falls through to next (okHandler, which always answers 200), so just
+	// confirm it wasn't short-circuited with a 204 preflight response.
+	if rec.Code == http.StatusNoContent {
+		t.Error("disallowed origin's preflight was answered directly")
+	}
+}
@@ -128,7 +128,9 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 		}
 	} else {
 		// Fetch source stream
-		rc, err := source.GetFileStream(ctx, sourcePath, creds)
+		backend, _ := creds["type"].(string)
+		source.RegisterProfile(alias, creds)
+		rc, err := source.GetFileStream(ctx, source.SourceRef{Backend: backend, Path: sourcePath, ProfileID: alias})
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
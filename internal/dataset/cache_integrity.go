@@ -0,0 +1,143 @@
+package dataset
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"flight2/internal/source"
+)
+
+// sourceMeta records the source validators observed when a particular
+// content digest was produced, persisted as a "<diskHash>.meta" sidecar
+// next to the cached SQLite artifact.
+type sourceMeta struct {
+	SourceSize  int64     `json:"source_size"`
+	SourceMTime time.Time `json:"source_mtime"`
+	SourceETag  string    `json:"source_etag,omitempty"`
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// diskKeyHash hashes the content-addressed disk cache key
+// "alias:sourcePath:sha256" the same way the legacy alias:sourcePath key is
+// hashed, so cache file naming stays consistent.
+func diskKeyHash(alias, sourcePath, sha256Hex string) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", alias, sourcePath, sha256Hex)))
+	return hex.EncodeToString(sum[:])
+}
+
+// shardedCachePath builds cacheDir/<h[0:2]>/<h[2:4]>/<h><suffix>, creating
+// the two shard directories if needed, so a cache holding millions of
+// artifacts never puts them all in one directory. h is expected to already
+// be hex-encoded (an md5 or sha256 digest), so its first four characters
+// are always present and filesystem-safe.
+func shardedCachePath(cacheDir, h, suffix string) string {
+	dir := filepath.Join(cacheDir, h[0:2], h[2:4])
+	os.MkdirAll(dir, 0755)
+	return filepath.Join(dir, h+suffix)
+}
+
+func (m *Manager) hashedDiskPaths(alias, sourcePath, sha256Hex string) (dbPath, metaPath string) {
+	h := diskKeyHash(alias, sourcePath, sha256Hex)
+	return shardedCachePath(m.dir(), h, ".sqlite"), shardedCachePath(m.dir(), h, ".meta")
+}
+
+// pointerFilePath remembers the most recent content digest produced for
+// alias:sourcePath, so a future call can probe freshness without first
+// knowing the hash (which, by definition, requires having fetched the
+// content at least once).
+func (m *Manager) pointerFilePath(alias, sourcePath string) string {
+	sum := md5.Sum([]byte(fmt.Sprintf("%s:%s:pointer", alias, sourcePath)))
+	return shardedCachePath(m.dir(), hex.EncodeToString(sum[:]), ".ptr")
+}
+
+func readJSONFile(path string, out interface{}) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, out) == nil
+}
+
+func writeJSONFile(path string, in interface{}) error {
+	data, err := json.MarshalIndent(in, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// freshCachedPath probes the source's current metadata and, if it matches
+// the validators recorded for the last digest seen under alias:sourcePath,
+// returns that still-valid cached DB path without re-downloading the
+// source body. ok is false whenever the probe is inconclusive (unsupported
+// backend, no prior conversion, or a validator mismatch).
+func (m *Manager) freshCachedPath(ctx context.Context, ref source.SourceRef, alias string) (path string, ok bool) {
+	sourcePath := ref.Path
+
+	var ptr struct {
+		Sha256 string `json:"sha256"`
+	}
+	if !readJSONFile(m.pointerFilePath(alias, sourcePath), &ptr) || ptr.Sha256 == "" {
+		return "", false
+	}
+
+	info, err := source.Stat(ctx, ref)
+	if err != nil {
+		// Backend can't report metadata cheaply; fall back to a full fetch.
+		return "", false
+	}
+
+	dbPath, metaPath := m.hashedDiskPaths(alias, sourcePath, ptr.Sha256)
+
+	var meta sourceMeta
+	if !readJSONFile(metaPath, &meta) {
+		return "", false
+	}
+	if info.Size != meta.SourceSize || !info.ModTime.Equal(meta.SourceMTime) {
+		return "", false
+	}
+	if meta.SourceETag != "" && info.ETag != "" && info.ETag != meta.SourceETag {
+		return "", false
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return "", false
+	}
+	return dbPath, true
+}
+
+// recordSourceMeta persists the validators observed for a freshly converted
+// artifact and updates the alias:sourcePath -> sha256 pointer.
+func (m *Manager) recordSourceMeta(alias, sourcePath, sha256Hex string, info source.ObjectInfo, haveInfo bool) {
+	_, metaPath := m.hashedDiskPaths(alias, sourcePath, sha256Hex)
+	meta := sourceMeta{ConvertedAt: time.Now()}
+	if haveInfo {
+		meta.SourceSize = info.Size
+		meta.SourceMTime = info.ModTime
+		meta.SourceETag = info.ETag
+	}
+	if err := writeJSONFile(metaPath, &meta); err != nil && m.verbose {
+		fmt.Printf("Warning: failed to write cache meta %s: %v\n", metaPath, err)
+	}
+
+	ptr := struct {
+		Sha256 string `json:"sha256"`
+	}{Sha256: sha256Hex}
+	if err := writeJSONFile(m.pointerFilePath(alias, sourcePath), &ptr); err != nil && m.verbose {
+		fmt.Printf("Warning: failed to write cache pointer for %s:%s: %v\n", alias, sourcePath, err)
+	}
+}
+
+// hashingTee wraps r so the SHA-256 digest of everything read through it is
+// available by calling the returned function once the copy is done.
+func hashingTee(r io.Reader) (io.Reader, func() string) {
+	h := sha256.New()
+	return io.TeeReader(r, h), func() string { return hex.EncodeToString(h.Sum(nil)) }
+}
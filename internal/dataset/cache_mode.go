@@ -0,0 +1,129 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CacheMode controls how a converted SQLite artifact is committed to the
+// memory/disk cache tiers after conversion.
+type CacheMode string
+
+const (
+	// CacheModeWritethrough fills memory+disk synchronously before GetSQLiteDB
+	// returns. This is the historical behavior.
+	CacheModeWritethrough CacheMode = "writethrough"
+	// CacheModeWriteback returns the converted temp file immediately and
+	// populates the caches on a background goroutine.
+	CacheModeWriteback CacheMode = "writeback"
+	// CacheModeOff bypasses both cache tiers entirely; every call re-fetches
+	// and re-converts.
+	CacheModeOff CacheMode = "off"
+)
+
+// CacheMetrics tracks cache effectiveness for a Manager.
+type CacheMetrics struct {
+	Hits     int64
+	Misses   int64
+	Inflight int64
+}
+
+// cacheCommitPool runs writeback jobs on a small bounded worker pool and
+// coalesces concurrent commits for the same key so only one writeback is
+// ever in flight per key.
+type cacheCommitPool struct {
+	mu      sync.Mutex
+	inflght map[string]chan struct{}
+	jobs    chan func()
+	wg      sync.WaitGroup
+}
+
+func newCacheCommitPool(workers int) *cacheCommitPool {
+	if workers <= 0 {
+		workers = 4
+	}
+	p := &cacheCommitPool{
+		inflght: make(map[string]chan struct{}),
+		jobs:    make(chan func(), workers*4),
+	}
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+func (p *cacheCommitPool) worker() {
+	for job := range p.jobs {
+		job()
+	}
+}
+
+// submit dispatches a writeback for key, coalescing with any commit already
+// in flight for the same key. A caller that finds a commit already in flight
+// for its key blocks until that commit's done channel is closed rather than
+// re-running fn.
+func (p *cacheCommitPool) submit(key string, fn func()) {
+	p.mu.Lock()
+	if done, ok := p.inflght[key]; ok {
+		p.mu.Unlock()
+		<-done
+		return
+	}
+	done := make(chan struct{})
+	p.inflght[key] = done
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	p.jobs <- func() {
+		defer p.wg.Done()
+		defer func() {
+			p.mu.Lock()
+			delete(p.inflght, key)
+			p.mu.Unlock()
+			close(done)
+		}()
+		fn()
+	}
+}
+
+// WaitIdle blocks until all pending writeback commits have completed, or ctx
+// is cancelled. Intended for callers/tests that need a deterministic flush
+// point after using CacheModeWriteback.
+func (m *Manager) WaitIdle(ctx context.Context) error {
+	if m.commitPool == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		m.commitPool.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("WaitIdle: %w", ctx.Err())
+	}
+}
+
+// Metrics returns a snapshot of the Manager's cache hit/miss/inflight counters.
+func (m *Manager) Metrics() CacheMetrics {
+	return CacheMetrics{
+		Hits:     m.metrics.hits.Load(),
+		Misses:   m.metrics.misses.Load(),
+		Inflight: m.metrics.inflight.Load(),
+	}
+}
+
+// resolveCacheMode returns the effective mode for a call, letting a per-call
+// override win over the Manager's default.
+func (m *Manager) resolveCacheMode(override CacheMode) CacheMode {
+	if override != "" {
+		return override
+	}
+	if m.cacheMode != "" {
+		return m.cacheMode
+	}
+	return CacheModeWritethrough
+}
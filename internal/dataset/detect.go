@@ -0,0 +1,198 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+)
+
+// detectSampleSize is how much of a fetched object's content Detector
+// looks at before giving up and falling back to extension-based dispatch.
+const detectSampleSize = 32 * 1024
+
+// DetectedFormat is the data format Detector identifies from a sample of a
+// source's bytes, independent of its file extension.
+type DetectedFormat string
+
+const (
+	FormatUnknown DetectedFormat = ""
+	FormatCSV     DetectedFormat = "csv"
+	FormatTSV     DetectedFormat = "tsv"
+	FormatJSON    DetectedFormat = "json"
+	FormatNDJSON  DetectedFormat = "ndjson"
+	FormatParquet DetectedFormat = "parquet"
+	FormatSQLite  DetectedFormat = "sqlite"
+	FormatZip     DetectedFormat = "zip"
+	FormatXLSX    DetectedFormat = "xlsx"
+	FormatHTML    DetectedFormat = "html"
+)
+
+// Detector sniffs a sample of a fetched object's bytes and reports its
+// format, so ingestion doesn't have to trust a possibly wrong or missing
+// file extension.
+type Detector interface {
+	Detect(sample []byte) DetectedFormat
+}
+
+// defaultDetector implements Detector using SQLite's header string, zip's
+// local-file-header magic (disambiguated from xlsx by the presence of
+// xlsx's own top-level entry), a leading "<!doctype"/"<html" for HTML,
+// Parquet's "PAR1" magic, JSON's leading '{'/'[', a one-object-per-line
+// heuristic to tell NDJSON from a single pretty-printed document, and
+// comma/tab frequency to tell CSV from TSV.
+type defaultDetector struct{}
+
+// sqliteHeader is the fixed 16-byte magic string every SQLite database
+// file begins with (see the SQLite file format spec).
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// zipHeader is the local file header signature every zip (and so every
+// xlsx, which is a zip of XML parts) begins with.
+var zipHeader = []byte("PK\x03\x04")
+
+func (defaultDetector) Detect(sample []byte) DetectedFormat {
+	sample = bytes.TrimPrefix(sample, []byte{0xEF, 0xBB, 0xBF}) // UTF-8 BOM
+	trimmed := bytes.TrimSpace(sample)
+	if len(trimmed) == 0 {
+		return FormatUnknown
+	}
+
+	if bytes.HasPrefix(sample, sqliteHeader) {
+		return FormatSQLite
+	}
+
+	if bytes.HasPrefix(sample, zipHeader) {
+		// xlsx's central directory isn't necessarily within the sampled
+		// prefix, but its workbook part's local file header name almost
+		// always is for files small enough to matter here.
+		if bytes.Contains(sample, []byte("xl/workbook.xml")) {
+			return FormatXLSX
+		}
+		return FormatZip
+	}
+
+	if looksLikeHTML(trimmed) {
+		return FormatHTML
+	}
+
+	if bytes.HasPrefix(sample, []byte("PAR1")) || bytes.HasSuffix(trimmed, []byte("PAR1")) {
+		return FormatParquet
+	}
+
+	switch trimmed[0] {
+	case '[':
+		return FormatJSON
+	case '{':
+		if looksLikeNDJSON(trimmed) {
+			return FormatNDJSON
+		}
+		return FormatJSON
+	}
+
+	return detectDelimited(sample)
+}
+
+// looksLikeHTML reports whether trimmed opens with a doctype declaration
+// or html tag, case-insensitively - the common shape of an HTTP response
+// body served without a useful extension.
+func looksLikeHTML(trimmed []byte) bool {
+	lower := bytes.ToLower(trimmed)
+	return bytes.HasPrefix(lower, []byte("<!doctype")) || bytes.HasPrefix(lower, []byte("<html"))
+}
+
+// driverForFormat maps a sniffed format to the converters.Open driver name
+// that can ingest it, for callers that only have a DetectedFormat and need
+// a driver string. FormatSQLite has no such driver - the caller is
+// expected to treat it as "already a valid SQLite file" and copy it
+// through unconverted, the same as an extension-based .db/.sqlite match.
+func driverForFormat(format DetectedFormat) string {
+	switch format {
+	case FormatCSV, FormatTSV:
+		return "csv"
+	case FormatJSON, FormatNDJSON:
+		return "json"
+	case FormatXLSX:
+		return "excel"
+	case FormatZip:
+		return "zip"
+	case FormatHTML:
+		return "html"
+	}
+	return ""
+}
+
+// DetectDriver sniffs r's leading bytes to pick a converters.Open driver
+// name by magic bytes and content heuristics, falling back to "" (the
+// caller should use its extension hint instead) when the sample is
+// inconclusive. rest re-wraps the peeked bytes so the full stream is still
+// readable from the start. A returned driver of "sqlite" means the stream
+// is already a valid SQLite database and should be copied through rather
+// than passed to a converter.
+func DetectDriver(r io.Reader) (driver string, rest io.Reader, err error) {
+	br := bufio.NewReaderSize(r, detectSampleSize)
+	sample, err := br.Peek(detectSampleSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", br, err
+	}
+
+	format := defaultDetector{}.Detect(sample)
+	if format == FormatSQLite {
+		return "sqlite", br, nil
+	}
+	return driverForFormat(format), br, nil
+}
+
+// looksLikeNDJSON reports whether sample has more than one non-blank line
+// and every one of them starts its own top-level JSON object, as opposed
+// to a single JSON document that merely happens to span multiple lines.
+func looksLikeNDJSON(sample []byte) bool {
+	lines := bytes.Split(sample, []byte("\n"))
+	objLines := 0
+	for _, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '{' {
+			return false
+		}
+		objLines++
+	}
+	return objLines > 1
+}
+
+// detectDelimited counts comma vs tab occurrences across the sampled lines
+// to tell CSV from TSV. Ties, and samples with neither, default to CSV,
+// matching the importer's historical behavior.
+func detectDelimited(sample []byte) DetectedFormat {
+	scanner := bufio.NewScanner(bytes.NewReader(sample))
+	var commas, tabs int
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		commas += bytes.Count(line, []byte{','})
+		tabs += bytes.Count(line, []byte{'\t'})
+	}
+	if tabs > commas {
+		return FormatTSV
+	}
+	return FormatCSV
+}
+
+// readSample reads up to detectSampleSize bytes from the start of path, for
+// Detector.Detect to sniff. A short file (smaller than the sample size) is
+// not an error; io.ReadFull's io.ErrUnexpectedEOF just means "read it all".
+func readSample(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, detectSampleSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
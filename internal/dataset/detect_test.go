@@ -0,0 +1,184 @@
+package dataset
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultDetector_Detect(t *testing.T) {
+	cases := []struct {
+		name   string
+		sample string
+		want   DetectedFormat
+	}{
+		{"csv", "id,name\n1,Alice\n2,Bob\n", FormatCSV},
+		{"tsv", "id\tname\n1\tAlice\n2\tBob\n", FormatTSV},
+		{"json array", "[{\"id\":1},{\"id\":2}]", FormatJSON},
+		{"json object", "{\"id\":1,\"name\":\"Alice\"}", FormatJSON},
+		{"ndjson", "{\"id\":1}\n{\"id\":2}\n{\"id\":3}\n", FormatNDJSON},
+		{"parquet prefix", "PAR1rest-of-the-file", FormatParquet},
+		{"parquet suffix", "rest-of-the-file\nPAR1", FormatParquet},
+		{"empty", "", FormatUnknown},
+		{"bom csv", "\xEF\xBB\xBFid,name\n1,Alice\n", FormatCSV},
+		{"sqlite header", "SQLite format 3\x00rest-of-the-file", FormatSQLite},
+		{"xlsx zip", "PK\x03\x04...xl/workbook.xml...", FormatXLSX},
+		{"plain zip", "PK\x03\x04...README.txt...", FormatZip},
+		{"doctype html", "<!DOCTYPE html>\n<html><body>hi</body></html>", FormatHTML},
+		{"bare html tag", "<html><body>hi</body></html>", FormatHTML},
+	}
+
+	d := defaultDetector{}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := d.Detect([]byte(c.sample)); got != c.want {
+				t.Errorf("Detect(%q) = %q, want %q", c.sample, got, c.want)
+			}
+		})
+	}
+}
+
+func TestDetectDriver(t *testing.T) {
+	cases := []struct {
+		name   string
+		sample string
+		want   string
+	}{
+		{"sqlite", "SQLite format 3\x00rest", "sqlite"},
+		{"xlsx", "PK\x03\x04...xl/workbook.xml...", "excel"},
+		{"zip", "PK\x03\x04...README.txt...", "zip"},
+		{"html", "<!DOCTYPE html><html></html>", "html"},
+		{"json", "[{\"id\":1}]", "json"},
+		{"csv", "id,name\n1,Alice\n", "csv"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			driver, rest, err := DetectDriver(strings.NewReader(c.sample))
+			if err != nil {
+				t.Fatalf("DetectDriver failed: %v", err)
+			}
+			if driver != c.want {
+				t.Errorf("DetectDriver(%q) = %q, want %q", c.sample, driver, c.want)
+			}
+			full, err := io.ReadAll(rest)
+			if err != nil {
+				t.Fatalf("reading rest failed: %v", err)
+			}
+			if string(full) != c.sample {
+				t.Errorf("rest = %q, want full sample %q", full, c.sample)
+			}
+		})
+	}
+}
+
+func TestReadSample_ShortFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.txt")
+	if err := os.WriteFile(path, []byte("id,name\n1,Alice\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sample, err := readSample(path)
+	if err != nil {
+		t.Fatalf("readSample failed: %v", err)
+	}
+	if string(sample) != "id,name\n1,Alice\n" {
+		t.Errorf("readSample = %q, want full file contents", sample)
+	}
+}
+
+func TestTranscodeCSV_TabDelimitedNoHeader(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.tsv")
+	if err := os.WriteFile(srcPath, []byte("1\tAlice\n2\tBob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outPath, err := transcodeCSV(dir, srcPath, '\t', true)
+	if err != nil {
+		t.Fatalf("transcodeCSV failed: %v", err)
+	}
+	defer os.Remove(outPath)
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "col0,col1\n1,Alice\n2,Bob\n"
+	if string(out) != want {
+		t.Errorf("transcodeCSV output = %q, want %q", out, want)
+	}
+}
+
+func TestTranscodeNDJSON_WrapsIntoArray(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.ndjson")
+	if err := os.WriteFile(srcPath, []byte("{\"id\":1}\n{\"id\":2}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outPath, err := transcodeNDJSON(dir, srcPath)
+	if err != nil {
+		t.Fatalf("transcodeNDJSON failed: %v", err)
+	}
+	defer os.Remove(outPath)
+
+	out, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[{"id":1},{"id":2}]`
+	if string(out) != want {
+		t.Errorf("transcodeNDJSON output = %q, want %q", out, want)
+	}
+}
+
+func TestTranscodeNDJSON_InvalidLine(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "src.ndjson")
+	if err := os.WriteFile(srcPath, []byte("{\"id\":1}\nnot json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := transcodeNDJSON(dir, srcPath); err == nil {
+		t.Error("expected error for invalid NDJSON line, got nil")
+	}
+}
+
+func TestOverrideStore_SetGetDelete(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "overrides.db")
+	store, err := newOverrideStore(dbPath)
+	if err != nil {
+		t.Fatalf("newOverrideStore failed: %v", err)
+	}
+	defer store.close()
+
+	if _, ok, err := store.get("missing"); err != nil || ok {
+		t.Fatalf("get(missing) = ok=%v, err=%v, want ok=false, err=nil", ok, err)
+	}
+
+	header := true
+	want := ImportOverrides{Delimiter: "\t", Header: &header, Types: map[string]string{"id": "INTEGER"}}
+	if err := store.set("alias1", want); err != nil {
+		t.Fatalf("set failed: %v", err)
+	}
+
+	got, ok, err := store.get("alias1")
+	if err != nil || !ok {
+		t.Fatalf("get(alias1) = ok=%v, err=%v, want ok=true, err=nil", ok, err)
+	}
+	if got.Delimiter != want.Delimiter || *got.Header != *want.Header || got.Types["id"] != "INTEGER" {
+		t.Errorf("get(alias1) = %+v, want %+v", got, want)
+	}
+
+	if err := store.delete("alias1"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, ok, err := store.get("alias1"); err != nil || ok {
+		t.Fatalf("get after delete = ok=%v, err=%v, want ok=false, err=nil", ok, err)
+	}
+}
@@ -0,0 +1,133 @@
+package dataset
+
+import (
+	"container/list"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// diskEntry describes one artifact committed to the on-disk cache tier.
+type diskEntry struct {
+	path   string
+	size   int64
+	sha256 string
+	mtime  time.Time
+}
+
+// diskIndex is an in-memory LRU index over the disk cache tier, keyed by
+// the same alias:sourcePath key used for the memory tier. Entries beyond
+// maxSize are evicted oldest-first, deleting the corresponding file from
+// cacheDir. Unlike the memory tier, every committed artifact gets an
+// entry here regardless of size - that's the point of the disk tier.
+type diskIndex struct {
+	mu          sync.Mutex
+	maxSize     int64
+	currentSize int64
+	order       *list.List
+	items       map[string]*list.Element
+}
+
+type diskIndexItem struct {
+	key   string
+	entry diskEntry
+}
+
+func newDiskIndex(maxSize int64) *diskIndex {
+	return &diskIndex{
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+	}
+}
+
+// touch records (or refreshes) key's entry as most-recently-used, evicting
+// the least-recently-used entries until the index fits within maxSize.
+func (d *diskIndex) touch(key string, entry diskEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if el, ok := d.items[key]; ok {
+		d.currentSize -= el.Value.(*diskIndexItem).entry.size
+		d.order.Remove(el)
+		delete(d.items, key)
+	}
+
+	el := d.order.PushFront(&diskIndexItem{key: key, entry: entry})
+	d.items[key] = el
+	d.currentSize += entry.size
+
+	d.evictLocked()
+}
+
+// touchIfAbsent records entry under key only if it isn't already tracked,
+// leaving an existing entry's LRU position untouched. sweepDiskCache uses
+// this to fold already-on-disk artifacts (discovered after a restart,
+// where this index starts empty) into the LRU without promoting them
+// ahead of entries a request has actually served recently.
+func (d *diskIndex) touchIfAbsent(key string, entry diskEntry) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.items[key]; ok {
+		return
+	}
+	el := d.order.PushBack(&diskIndexItem{key: key, entry: entry})
+	d.items[key] = el
+	d.currentSize += entry.size
+
+	d.evictLocked()
+}
+
+// sweep re-runs eviction against the index's current contents, e.g. after
+// a batch of touchIfAbsent calls that may have pushed currentSize over
+// maxSize without tripping it (plain touch already evicts inline, so this
+// only matters for the sweep path).
+func (d *diskIndex) sweep() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.evictLocked()
+}
+
+// purgeAlias removes every entry whose key is "alias:..." (the convention
+// Manager.GetSQLiteDBWithMode uses for cache keys), returning the removed
+// entries so the caller can delete their backing files and any sibling
+// memory-cache/pointer state keyed the same way.
+func (d *diskIndex) purgeAlias(alias string) []diskIndexItem {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prefix := alias + ":"
+	var removed []diskIndexItem
+	for key, el := range d.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		item := el.Value.(*diskIndexItem)
+		removed = append(removed, *item)
+		d.order.Remove(el)
+		delete(d.items, key)
+		d.currentSize -= item.entry.size
+	}
+	return removed
+}
+
+func (d *diskIndex) evictLocked() {
+	if d.maxSize <= 0 {
+		return
+	}
+	for d.currentSize > d.maxSize {
+		oldest := d.order.Back()
+		if oldest == nil {
+			return
+		}
+		item := oldest.Value.(*diskIndexItem)
+		d.order.Remove(oldest)
+		delete(d.items, item.key)
+		d.currentSize -= item.entry.size
+		// Best-effort: a failed removal just leaves the file on disk
+		// until the next eviction pass or process restart reclaims it.
+		os.Remove(item.entry.path)
+	}
+}
@@ -0,0 +1,328 @@
+package dataset
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"flight2/internal/source"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// JobStatus is the lifecycle state of an ingestion Job.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job records the progress and outcome of a background GetSQLiteDB
+// conversion submitted via Manager.SubmitJob.
+type Job struct {
+	ID              string    `json:"id"`
+	Alias           string    `json:"alias"`
+	SourcePath      string    `json:"source_path"`
+	Status          JobStatus `json:"status"`
+	BytesDownloaded int64     `json:"bytes_downloaded"`
+	RowsImported    int64     `json:"rows_imported"`
+	Error           string    `json:"error,omitempty"`
+	DBPath          string    `json:"db_path,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// jobStore persists Job state in a small SQLite database so job status
+// survives process restarts and can be polled by the SSE endpoint without
+// holding everything in memory.
+type jobStore struct {
+	db *sql.DB
+}
+
+func newJobStore(dbPath string) (*jobStore, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open jobs db: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS jobs (
+		id               TEXT PRIMARY KEY,
+		alias            TEXT NOT NULL,
+		source_path      TEXT NOT NULL,
+		status           TEXT NOT NULL,
+		bytes_downloaded INTEGER NOT NULL DEFAULT 0,
+		rows_imported    INTEGER NOT NULL DEFAULT 0,
+		error            TEXT NOT NULL DEFAULT '',
+		db_path          TEXT NOT NULL DEFAULT '',
+		created_at       DATETIME NOT NULL,
+		updated_at       DATETIME NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create jobs table: %w", err)
+	}
+
+	return &jobStore{db: db}, nil
+}
+
+func (s *jobStore) upsert(j Job) error {
+	_, err := s.db.Exec(`INSERT INTO jobs (
+		id, alias, source_path, status, bytes_downloaded, rows_imported, error, db_path, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		status = excluded.status,
+		bytes_downloaded = excluded.bytes_downloaded,
+		rows_imported = excluded.rows_imported,
+		error = excluded.error,
+		db_path = excluded.db_path,
+		updated_at = excluded.updated_at`,
+		j.ID, j.Alias, j.SourcePath, j.Status, j.BytesDownloaded, j.RowsImported, j.Error, j.DBPath, j.CreatedAt, j.UpdatedAt)
+	return err
+}
+
+func (s *jobStore) get(id string) (Job, error) {
+	var j Job
+	var status string
+	err := s.db.QueryRow(`SELECT id, alias, source_path, status, bytes_downloaded, rows_imported, error, db_path, created_at, updated_at
+		FROM jobs WHERE id = ?`, id).Scan(
+		&j.ID, &j.Alias, &j.SourcePath, &status, &j.BytesDownloaded, &j.RowsImported, &j.Error, &j.DBPath, &j.CreatedAt, &j.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return Job{}, ErrJobNotFound
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	j.Status = JobStatus(status)
+	return j, nil
+}
+
+func (s *jobStore) close() error {
+	return s.db.Close()
+}
+
+// ErrJobNotFound is returned by Manager.GetJob when id doesn't match any
+// submitted job.
+var ErrJobNotFound = fmt.Errorf("job not found")
+
+// newJobID returns a random 16-hex-character job identifier, following the
+// same crypto/rand-derived convention secrets.Service uses for aliases.
+func newJobID() (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// jobQueue runs submitted jobs on a small bounded worker pool. Unlike
+// cacheCommitPool it doesn't coalesce by key: each SubmitJob call gets its
+// own row and its own run, even if two jobs target the same source.
+type jobQueue struct {
+	jobs chan func()
+}
+
+func newJobQueue(workers int) *jobQueue {
+	if workers <= 0 {
+		workers = 4
+	}
+	q := &jobQueue{jobs: make(chan func(), workers*4)}
+	for i := 0; i < workers; i++ {
+		go q.worker()
+	}
+	return q
+}
+
+func (q *jobQueue) worker() {
+	for job := range q.jobs {
+		job()
+	}
+}
+
+func (q *jobQueue) submit(fn func()) {
+	q.jobs <- fn
+}
+
+// WithJobWorkers sizes the worker pool backing Manager.SubmitJob. The
+// default is 4, matching WithCacheMode's writeback pool sizing.
+func WithJobWorkers(n int) ManagerOption {
+	return func(m *Manager) {
+		m.jobWorkers = n
+	}
+}
+
+// SubmitJob enqueues a background GetSQLiteDB conversion for ref and
+// returns immediately with a JobID to poll via GetJob or stream via the
+// server's SSE endpoint. If ref already resolves to a fresh cached
+// artifact (memory or revalidated disk tier), the job is recorded as
+// already Succeeded and the existing dbPath is returned without any
+// background work - submitting a job for a still-fresh dataset is free.
+func (m *Manager) SubmitJob(ctx context.Context, ref source.SourceRef, alias string) (string, error) {
+	id, err := newJobID()
+	if err != nil {
+		return "", fmt.Errorf("failed to allocate job id: %w", err)
+	}
+
+	ref = resolveLocalExtension(ref)
+	now := time.Now()
+	job := Job{ID: id, Alias: alias, SourcePath: ref.Path, Status: JobQueued, CreatedAt: now, UpdatedAt: now}
+
+	key := fmt.Sprintf("%s:%s", alias, ref.Path)
+	if entry, err := m.cache.Get(key); err == nil {
+		if dbPath, err := m.writeTempFile(entry); err == nil {
+			job.Status = JobSucceeded
+			job.DBPath = dbPath
+			job.UpdatedAt = time.Now()
+			if err := m.jobStore.upsert(job); err != nil {
+				return "", err
+			}
+			return id, nil
+		}
+	}
+	if diskPath, ok := m.freshCachedPath(ctx, ref, alias); ok {
+		if out, err := m.writeTempFileFromPath(diskPath); err == nil {
+			job.Status = JobSucceeded
+			job.DBPath = out
+			job.UpdatedAt = time.Now()
+			if err := m.jobStore.upsert(job); err != nil {
+				return "", err
+			}
+			return id, nil
+		}
+	}
+
+	if err := m.jobStore.upsert(job); err != nil {
+		return "", err
+	}
+	m.jobQueue.submit(func() {
+		m.runJob(id, ref, alias)
+	})
+	return id, nil
+}
+
+// GetJob returns the current state of a job submitted via SubmitJob.
+func (m *Manager) GetJob(id string) (Job, error) {
+	return m.jobStore.get(id)
+}
+
+// runJob performs the conversion for a queued job, reporting live
+// bytes-downloaded progress and persisting the final outcome. It reuses
+// GetSQLiteDBWithMode so a completed job populates the same memory/disk
+// cache tiers an ordinary synchronous call would.
+func (m *Manager) runJob(id string, ref source.SourceRef, alias string) {
+	existing, err := m.jobStore.get(id)
+	if err != nil {
+		log.Printf("job %s: failed to load queued row: %v", id, err)
+		return
+	}
+	existing.Status = JobRunning
+	existing.UpdatedAt = time.Now()
+	if err := m.jobStore.upsert(existing); err != nil {
+		log.Printf("job %s: failed to mark running: %v", id, err)
+	}
+
+	var reportMu sync.Mutex
+	var lastReport time.Time
+	report := func(bytes int64) {
+		reportMu.Lock()
+		defer reportMu.Unlock()
+		if time.Since(lastReport) < 250*time.Millisecond {
+			return
+		}
+		lastReport = time.Now()
+		existing.BytesDownloaded = bytes
+		existing.UpdatedAt = time.Now()
+		m.jobStore.upsert(existing)
+	}
+
+	ctx := withProgress(context.Background(), report)
+	dbPath, err := m.GetSQLiteDBWithMode(ctx, ref, alias, "")
+	existing.UpdatedAt = time.Now()
+	if err != nil {
+		existing.Status = JobFailed
+		existing.Error = err.Error()
+		m.jobStore.upsert(existing)
+		return
+	}
+
+	existing.Status = JobSucceeded
+	existing.DBPath = dbPath
+	existing.RowsImported = countRows(dbPath)
+	m.jobStore.upsert(existing)
+}
+
+// countRows returns a best-effort total row count across every table in
+// the converted database at dbPath. mksqlite's ImportOptions has no
+// progress-callback hook, so unlike BytesDownloaded this can only be
+// known after conversion finishes, not reported incrementally.
+func countRows(dbPath string) int64 {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return 0
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return 0
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			tables = append(tables, name)
+		}
+	}
+	rows.Close()
+
+	var total int64
+	for _, t := range tables {
+		var count int64
+		if err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(t, `"`, `""`))).Scan(&count); err == nil {
+			total += count
+		}
+	}
+	return total
+}
+
+type progressFunc func(bytesDownloaded int64)
+
+type progressCtxKey struct{}
+
+// withProgress attaches a progress callback to ctx for fetchAndConvertImpl
+// to report bytes-downloaded through as it streams the source body.
+// Ordinary GetSQLiteDB callers never set one, so the reporting tee in
+// fetchAndConvertImpl is a no-op for them.
+func withProgress(ctx context.Context, fn progressFunc) context.Context {
+	return context.WithValue(ctx, progressCtxKey{}, fn)
+}
+
+func progressFromContext(ctx context.Context) (progressFunc, bool) {
+	fn, ok := ctx.Value(progressCtxKey{}).(progressFunc)
+	return fn, ok
+}
+
+// progressTee wraps r so fn is invoked with the cumulative byte count
+// after every read that returns data, mirroring hashingTee's shape.
+func progressTee(r io.Reader, fn progressFunc) io.Reader {
+	var total int64
+	return io.TeeReader(r, writerFunc(func(p []byte) (int, error) {
+		total += int64(len(p))
+		fn(total)
+		return len(p), nil
+	}))
+}
+
+// writerFunc adapts a func(p []byte) (int, error) to io.Writer, the same
+// way http.HandlerFunc adapts a function to an interface.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
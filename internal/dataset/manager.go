@@ -1,39 +1,151 @@
 package dataset
 
 import (
+	"bytes"
 	"context"
-	"crypto/md5"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"flight2/internal/config"
 	"flight2/internal/source"
 
 	"github.com/darianmavgo/mksqlite/converters/common"
 
 	"github.com/allegro/bigcache/v3"
 	"github.com/darianmavgo/mksqlite/converters"
+	"golang.org/x/sync/singleflight"
 
 	_ "github.com/darianmavgo/mksqlite/converters/all"
 )
 
 var supportedExtensions = []string{
-	".csv", ".xlsx", ".xls", ".zip", ".html", ".htm", ".json", ".txt",
+	".csv", ".tsv", ".xlsx", ".xls", ".zip", ".html", ".htm", ".json", ".ndjson", ".txt",
 	".db", ".sqlite", ".sqlite3",
 }
 
+const (
+	// defaultMemCacheThreshold is the largest artifact size BigCache will
+	// hold. Larger conversions live solely on disk so a single multi-GB
+	// dataset can't blow through HardMaxCacheSize.
+	defaultMemCacheThreshold = 32 * 1024 * 1024 // 32MB
+
+	// defaultHardMaxDiskSize bounds the on-disk cache tier; the LRU
+	// index evicts the oldest artifacts once it's exceeded.
+	defaultHardMaxDiskSize = 20 * 1024 * 1024 * 1024 // 20GB
+
+	// defaultJobWorkers sizes the background pool SubmitJob dispatches
+	// conversions on, absent a WithJobWorkers override.
+	defaultJobWorkers = 4
+
+	// defaultDiskSweepInterval sets how often the background sweeper
+	// reconciles diskIdx against what's actually in cacheDir - the
+	// mechanism that rebuilds the (in-memory-only) LRU ordering after a
+	// restart, when diskIdx starts empty but the disk tier doesn't.
+	defaultDiskSweepInterval = 10 * time.Minute
+
+	// defaultSpillThresholdBytes bounds how large a source can be and
+	// still be held entirely in memory during fetch+convert. At or below
+	// this, fetchAndConvertImpl skips writing the fetched source to a disk
+	// temp file altogether; above it (or when the source's size can't be
+	// learned cheaply up front), it spills to disk as before.
+	defaultSpillThresholdBytes = 16 * 1024 * 1024 // 16MB
+)
+
+type cacheMetrics struct {
+	hits     atomic.Int64
+	misses   atomic.Int64
+	inflight atomic.Int64
+}
+
 type Manager struct {
-	cache    *bigcache.BigCache
-	verbose  bool
-	cacheDir string
+	cache   *bigcache.BigCache
+	verbose bool
+
+	cacheDirMu sync.RWMutex
+	cacheDir   string
+
+	cacheMode  CacheMode
+	commitPool *cacheCommitPool
+	metrics    cacheMetrics
+
+	memCacheThreshold   int64
+	spillThresholdBytes int64
+	diskIdx             *diskIndex
+	diskSweepInterval   time.Duration
+	sweepStop           chan struct{}
+
+	jobWorkers int
+	jobStore   *jobStore
+	jobQueue   *jobQueue
+
+	detector      Detector
+	overrideStore *overrideStore
+
+	// sfGroup coalesces concurrent cache misses for the same alias:sourcePath
+	// key so only one of them actually fetches and converts the source.
+	sfGroup singleflight.Group
+}
+
+// fetchResult is the value sfGroup.Do's miss-path function returns: the
+// temp file its fetchAndConvert call produced, owned by whichever caller's
+// Do invocation actually ran it.
+type fetchResult struct {
+	tmpOutName string
+}
+
+// ManagerOption customizes a Manager at construction time.
+type ManagerOption func(*Manager)
+
+// WithCacheMode sets the Manager's default CacheMode. Per-call overrides are
+// available via GetSQLiteDBWithMode.
+func WithCacheMode(mode CacheMode) ManagerOption {
+	return func(m *Manager) {
+		m.cacheMode = mode
+	}
+}
+
+// WithMemCacheThreshold caps the size of artifacts BigCache is allowed to
+// hold; anything larger lives solely on the disk tier.
+func WithMemCacheThreshold(bytes int64) ManagerOption {
+	return func(m *Manager) {
+		m.memCacheThreshold = bytes
+	}
+}
+
+// WithSpillThresholdBytes caps how large a fetched source can be and still
+// be converted entirely from memory, skipping the disk-spill temp file.
+func WithSpillThresholdBytes(bytes int64) ManagerOption {
+	return func(m *Manager) {
+		m.spillThresholdBytes = bytes
+	}
+}
+
+// WithHardMaxDiskSize bounds the on-disk cache tier. Once exceeded, the
+// least-recently-used artifacts are evicted from cacheDir.
+func WithHardMaxDiskSize(bytes int64) ManagerOption {
+	return func(m *Manager) {
+		m.diskIdx = newDiskIndex(bytes)
+	}
+}
+
+// WithDiskSweepInterval overrides how often the background sweeper
+// reconciles the on-disk cache tier against diskIdx. A value <= 0 disables
+// the sweeper entirely.
+func WithDiskSweepInterval(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.diskSweepInterval = d
+	}
 }
 
-func NewManager(verbose bool, cacheDir string) (*Manager, error) {
+func NewManager(verbose bool, cacheDir string, opts ...ManagerOption) (*Manager, error) {
 	// Ensure cache directory exists
 	if err := os.MkdirAll(cacheDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache dir: %w", err)
@@ -50,23 +162,282 @@ func NewManager(verbose bool, cacheDir string) (*Manager, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Manager{cache: cache, verbose: verbose, cacheDir: cacheDir}, nil
+	m := &Manager{
+		cache:               cache,
+		verbose:             verbose,
+		cacheDir:            cacheDir,
+		cacheMode:           CacheModeWritethrough,
+		commitPool:          newCacheCommitPool(4),
+		memCacheThreshold:   defaultMemCacheThreshold,
+		spillThresholdBytes: defaultSpillThresholdBytes,
+		diskIdx:             newDiskIndex(defaultHardMaxDiskSize),
+		diskSweepInterval:   defaultDiskSweepInterval,
+		sweepStop:           make(chan struct{}),
+		jobWorkers:          defaultJobWorkers,
+		detector:            defaultDetector{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.diskSweepInterval > 0 {
+		go m.runSweeper()
+	}
+
+	jobStore, err := newJobStore(filepath.Join(cacheDir, "jobs.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open jobs db: %w", err)
+	}
+	m.jobStore = jobStore
+	m.jobQueue = newJobQueue(m.jobWorkers)
+
+	overrideStore, err := newOverrideStore(filepath.Join(cacheDir, "import_overrides.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import overrides db: %w", err)
+	}
+	m.overrideStore = overrideStore
+
+	return m, nil
 }
 
-// GetSQLiteDB returns a path to a SQLite database for the given source.
-func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[string]interface{}, alias string) (string, error) {
-	// If type is local, try to resolve extension if file not found
-	if t, ok := creds["type"].(string); ok && t == "local" {
-		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-			for _, ext := range supportedExtensions {
-				p := sourcePath + ext
-				if info, err := os.Stat(p); err == nil && !info.IsDir() {
-					sourcePath = p
-					break
-				}
-			}
+// WithDetector overrides the Detector used to sniff a fetched object's
+// format when its extension is missing or unrecognized. Tests use this to
+// stub detection without writing real Parquet/CSV fixtures.
+func WithDetector(d Detector) ManagerOption {
+	return func(m *Manager) {
+		m.detector = d
+	}
+}
+
+// dir returns the Manager's current cache directory. It's read through a
+// lock because HandleConfigChange can move cacheDir to a new path while
+// requests are in flight.
+func (m *Manager) dir() string {
+	m.cacheDirMu.RLock()
+	defer m.cacheDirMu.RUnlock()
+	return m.cacheDir
+}
+
+// CacheDir returns the Manager's current cache directory, for callers
+// outside the package (e.g. a readiness check) that only need to look at
+// it, not hold it across a migration.
+func (m *Manager) CacheDir() string {
+	return m.dir()
+}
+
+// Close drains in-flight writeback commits and releases the Manager's
+// resources: the BigCache memory tier and the jobs SQLite database. ctx
+// bounds how long it waits for writebacks to settle; it still closes the
+// cache and job store even if ctx expires first. Safe to call once during
+// shutdown; GetSQLiteDB calls racing a Close are not supported.
+func (m *Manager) Close(ctx context.Context) error {
+	if err := m.WaitIdle(ctx); err != nil {
+		log.Printf("Close: timed out waiting for in-flight writebacks, closing anyway: %v", err)
+	}
+
+	if m.diskSweepInterval > 0 {
+		close(m.sweepStop)
+	}
+
+	var errs []error
+	if err := m.cache.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing memory cache: %w", err))
+	}
+	if err := m.jobStore.close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing job store: %w", err))
+	}
+	if err := m.overrideStore.close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing import overrides store: %w", err))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// HandleConfigChange lets a Manager be registered as a config.Watcher
+// subscriber so cache_dir can be moved without restarting the process.
+// Other event types are ignored.
+func (m *Manager) HandleConfigChange(event config.ChangeEvent) {
+	if e, ok := event.(config.CacheDirChanged); ok {
+		m.migrateCacheDir(e.New)
+	}
+}
+
+// migrateCacheDir drains in-flight writeback commits against the old
+// cache_dir, then points the Manager at newDir for everything that
+// follows. Already-written cache files are left behind in the old
+// directory; fresh conversions simply repopulate newDir under the same
+// content-addressed names.
+func (m *Manager) migrateCacheDir(newDir string) {
+	if newDir == "" || newDir == m.dir() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := m.WaitIdle(ctx); err != nil {
+		log.Printf("cache_dir migration: timed out waiting for in-flight writebacks, proceeding anyway: %v", err)
+	}
+
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		log.Printf("cache_dir migration: failed to create %s, keeping %s: %v", newDir, m.dir(), err)
+		return
+	}
+
+	m.cacheDirMu.Lock()
+	old := m.cacheDir
+	m.cacheDir = newDir
+	m.cacheDirMu.Unlock()
+
+	log.Printf("cache_dir migrated from %s to %s", old, newDir)
+}
+
+// runSweeper periodically reconciles diskIdx against what's actually on
+// disk until Close signals sweepStop. It's the mechanism that makes the
+// size-bounded disk tier survive a restart: diskIdx itself is rebuilt
+// purely from runtime touch() calls, so without a sweep, artifacts
+// written before the last restart would sit outside the LRU entirely and
+// never get evicted no matter how large the cache dir grew.
+func (m *Manager) runSweeper() {
+	ticker := time.NewTicker(m.diskSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.sweepDiskCache()
+		case <-m.sweepStop:
+			return
+		}
+	}
+}
+
+// sweepDiskCache walks the current cache dir for *.sqlite artifacts and
+// folds any diskIdx doesn't already know about in as least-recently-used,
+// keyed by their own path (sweep-discovered entries have no alias:
+// sourcePath key to recover), then re-runs eviction in case that pushed
+// the index over its size bound.
+func (m *Manager) sweepDiskCache() {
+	root := m.dir()
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".sqlite") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
 		}
+		m.diskIdx.touchIfAbsent(p, diskEntry{path: p, size: info.Size(), mtime: info.ModTime()})
+		return nil
+	})
+	m.diskIdx.sweep()
+}
+
+// Purge evicts every disk- and memory-tier cache entry belonging to
+// alias, along with their freshness pointers - e.g. for a credential
+// rotation or deletion, where cached artifacts fetched under the old
+// credentials shouldn't linger. Individual file-removal failures are
+// collected and returned together rather than aborting partway, so one
+// already-missing file doesn't stop the rest of alias's entries from
+// being purged.
+func (m *Manager) Purge(alias string) error {
+	removed := m.diskIdx.purgeAlias(alias)
+	prefix := alias + ":"
+
+	var errs []error
+	for _, item := range removed {
+		if err := os.Remove(item.entry.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("removing %s: %w", item.entry.path, err))
+		}
+		os.Remove(strings.TrimSuffix(item.entry.path, ".sqlite") + ".meta")
+
+		m.cache.Delete(item.key)
+
+		sourcePath := strings.TrimPrefix(item.key, prefix)
+		os.Remove(m.pointerFilePath(alias, sourcePath))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
 	}
+	return nil
+}
+
+// GetSQLiteDB returns a path to a SQLite database for the given source,
+// using the Manager's default CacheMode.
+func (m *Manager) GetSQLiteDB(ctx context.Context, ref source.SourceRef, alias string) (string, error) {
+	return m.GetSQLiteDBWithMode(ctx, ref, alias, "")
+}
+
+// Refresh forces a re-fetch and re-conversion of ref, bypassing both the
+// memory and disk cache tiers and freshCachedPath's validator check
+// entirely - the escape hatch for a caller that knows the cached entry is
+// stale (or doesn't trust the backend's mtime/size/ETag reporting) and
+// wants the current upstream content regardless of what's cached.
+func (m *Manager) Refresh(ctx context.Context, ref source.SourceRef, alias string) (string, error) {
+	mode := m.resolveCacheMode("")
+	ref = resolveLocalExtension(ref)
+	sourcePath := ref.Path
+	key := fmt.Sprintf("%s:%s", alias, sourcePath)
+
+	tmpOutName, sha256Hex, err := m.fetchAndConvert(ctx, ref, alias)
+	if err != nil {
+		return "", err
+	}
+
+	diskPath, _ := m.hashedDiskPaths(alias, sourcePath, sha256Hex)
+	if info, statErr := source.Stat(ctx, ref); statErr == nil {
+		m.recordSourceMeta(alias, sourcePath, sha256Hex, info, true)
+	} else {
+		m.recordSourceMeta(alias, sourcePath, sha256Hex, source.ObjectInfo{}, false)
+	}
+
+	if mode == CacheModeOff {
+		return tmpOutName, nil
+	}
+
+	if mode == CacheModeWriteback {
+		m.metrics.inflight.Add(1)
+		m.commitPool.submit(key, func() {
+			defer m.metrics.inflight.Add(-1)
+			m.commitToCaches(key, diskPath, tmpOutName, sha256Hex)
+		})
+		return tmpOutName, nil
+	}
+
+	// writethrough: commit synchronously before returning.
+	m.commitToCaches(key, diskPath, tmpOutName, sha256Hex)
+	return tmpOutName, nil
+}
+
+// resolveLocalExtension tries known dataset extensions against ref.Path when
+// it's a local backend reference that doesn't exist as given, so callers can
+// pass an extension-less path (e.g. an alias-derived name) and still hit the
+// right file.
+func resolveLocalExtension(ref source.SourceRef) source.SourceRef {
+	if ref.Backend != "local" {
+		return ref
+	}
+	if _, err := os.Stat(ref.Path); !os.IsNotExist(err) {
+		return ref
+	}
+	for _, ext := range supportedExtensions {
+		p := ref.Path + ext
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			ref.Path = p
+			return ref
+		}
+	}
+	return ref
+}
+
+// GetSQLiteDBWithMode behaves like GetSQLiteDB but lets the caller override
+// the Manager's default CacheMode for this call only. ref.ProfileID must
+// already be registered with source.RegisterProfile (or the registry the
+// caller wired up) so ref resolves to an isolated fs.Fs instance.
+func (m *Manager) GetSQLiteDBWithMode(ctx context.Context, ref source.SourceRef, alias string, mode CacheMode) (string, error) {
+	mode = m.resolveCacheMode(mode)
+	ref = resolveLocalExtension(ref)
+	sourcePath := ref.Path
 
 	// Include alias in cache key to prevent cross-user leaks
 	if m.verbose {
@@ -74,50 +445,200 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 	}
 	key := fmt.Sprintf("%s:%s", alias, sourcePath)
 
+	if mode == CacheModeOff {
+		dbPath, _, err := m.fetchAndConvert(ctx, ref, alias)
+		return dbPath, err
+	}
+
 	// 1. Check Memory Cache (BigCache)
 	entry, err := m.cache.Get(key)
 	if err == nil {
+		m.metrics.hits.Add(1)
 		if m.verbose {
 			fmt.Println("🟢 [CACHE HIT] (Memory) Serving from RAM")
 		}
 		return m.writeTempFile(entry)
 	}
 
-	// 2. Check Disk Cache
-	hash := md5.Sum([]byte(key))
-	hashStr := hex.EncodeToString(hash[:])
-	diskPath := filepath.Join(m.cacheDir, hashStr+".sqlite")
-
-	if info, err := os.Stat(diskPath); err == nil && !info.IsDir() {
-		data, err := os.ReadFile(diskPath)
-		if err == nil {
+	// 2. Check Disk Cache: probe the source's cheap metadata and, if it
+	// still matches what the last conversion observed, reuse that
+	// content-addressed artifact without re-downloading the source.
+	if diskPath, ok := m.freshCachedPath(ctx, ref, alias); ok {
+		if info, statErr := os.Stat(diskPath); statErr == nil {
+			m.metrics.hits.Add(1)
+			m.diskIdx.touch(key, diskEntry{path: diskPath, size: info.Size(), mtime: info.ModTime()})
 			if m.verbose {
-				fmt.Println("🟢 [CACHE HIT] (Disk) Loaded from " + diskPath)
+				fmt.Println("🟢 [CACHE HIT] (Disk, revalidated) Loaded from " + diskPath)
+			}
+			// Only pull the artifact through the process for small
+			// datasets; large ones are served via a hard link so the
+			// whole file never has to be buffered in memory.
+			if info.Size() <= m.memCacheThreshold {
+				if data, err := os.ReadFile(diskPath); err == nil {
+					m.cache.Set(key, data)
+					return m.writeTempFile(data)
+				}
+			} else if out, err := m.writeTempFileFromPath(diskPath); err == nil {
+				return out, nil
 			}
-			// Update memory cache
-			m.cache.Set(key, data)
-			return m.writeTempFile(data)
 		}
 	}
 
-	// 3. Cache Miss - Fetch and Convert
+	// 3. Cache Miss - Fetch and Convert. Concurrent misses for the same key
+	// are coalesced through sfGroup so N simultaneous requests for a cold
+	// alias:sourcePath share one fetch+convert instead of each hitting the
+	// source and racing to write the cache entry.
 	if m.verbose {
 		fmt.Println("🟠 [CACHE MISS] Fetching and converting...")
 	}
 
-	// Prepare output file
-	tmpOut, err := os.CreateTemp(m.cacheDir, "flight2_db_*.sqlite")
+	// sfGroup.Do's closure runs once for whichever caller happens to win
+	// the race to become the singleflight leader, but every other
+	// concurrent caller coalesced onto key shares its result - so the
+	// fetch must not be bound to that one caller's ctx, or a follower's
+	// fetch silently aborts whenever the leader's own request/connection
+	// is cancelled. context.WithoutCancel carries ctx's values forward
+	// (nothing here relies on its deadline) without propagating the
+	// leader's cancellation or deadline to the shared fetch.
+	detachedCtx := context.WithoutCancel(ctx)
+	v, err, shared := m.sfGroup.Do(key, func() (interface{}, error) {
+		tmpOutName, sha256Hex, ferr := m.fetchAndConvert(detachedCtx, ref, alias)
+		if ferr != nil {
+			return nil, ferr
+		}
+
+		diskPath, _ := m.hashedDiskPaths(alias, sourcePath, sha256Hex)
+		if info, statErr := source.Stat(detachedCtx, ref); statErr == nil {
+			m.recordSourceMeta(alias, sourcePath, sha256Hex, info, true)
+		} else {
+			m.recordSourceMeta(alias, sourcePath, sha256Hex, source.ObjectInfo{}, false)
+		}
+
+		if mode == CacheModeWriteback {
+			m.metrics.inflight.Add(1)
+			m.commitPool.submit(key, func() {
+				defer m.metrics.inflight.Add(-1)
+				m.commitToCaches(key, diskPath, tmpOutName, sha256Hex)
+			})
+		} else {
+			// writethrough: commit synchronously before returning.
+			m.commitToCaches(key, diskPath, tmpOutName, sha256Hex)
+		}
+
+		return fetchResult{tmpOutName: tmpOutName}, nil
+	})
+	m.metrics.misses.Add(1)
 	if err != nil {
 		return "", err
 	}
+
+	res := v.(fetchResult)
+	if !shared {
+		return res.tmpOutName, nil
+	}
+
+	// A coalesced follower: res.tmpOutName belongs to whichever caller's
+	// sfGroup.Do invocation actually ran fetchAndConvert, and will be
+	// removed by that caller once it's done with it, so hand back an
+	// independent copy rather than the same path.
+	return m.writeTempFileFromPath(res.tmpOutName)
+}
+
+// commitToCaches places tmpOutName into the disk cache tier at diskPath via
+// a hard link - a directory-entry operation, not a byte copy - so
+// multi-GB artifacts don't have to be buffered through the process just
+// to be cached. tmpOutName keeps working as the caller's own temp file
+// throughout; only artifacts at or under memCacheThreshold are also read
+// into the BigCache tier.
+func (m *Manager) commitToCaches(key, diskPath, tmpOutName, sha256Hex string) {
+	info, err := os.Stat(tmpOutName)
+	if err != nil {
+		fmt.Printf("Warning: failed to stat converted db %s: %v\n", tmpOutName, err)
+		return
+	}
+
+	if err := linkOrCopy(tmpOutName, diskPath); err != nil {
+		fmt.Printf("Warning: failed to write disk cache: %v\n", err)
+		return
+	}
+	m.diskIdx.touch(key, diskEntry{path: diskPath, size: info.Size(), sha256: sha256Hex, mtime: info.ModTime()})
+	if m.verbose {
+		fmt.Printf("💾 [CACHE SAVED] Written to disk: %s\n", diskPath)
+	}
+
+	if info.Size() > m.memCacheThreshold {
+		return
+	}
+	data, err := os.ReadFile(diskPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to read cached db for memory tier: %v\n", err)
+		return
+	}
+	if err := m.cache.Set(key, data); err != nil {
+		fmt.Printf("Warning: failed to set cache: %v\n", err)
+	}
+}
+
+// linkOrCopy places src's content at dst via a hard link when possible,
+// falling back to a full byte copy only if linking fails (e.g. src and
+// dst ended up on different filesystems).
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// fetchAndConvert fetches ref (or reads a local directory) and produces a
+// converted SQLite temp file, without touching either cache tier. The
+// caller owns committing the result to caches (itself a hard-link into
+// diskPath, not a copy - see commitToCaches). sha256Hex is the digest of
+// the fetched source bytes (empty for the local-directory case, which has
+// no single source stream to hash).
+//
+// Sources within spillThresholdBytes convert directly from an in-memory
+// buffer, skipping the disk-spill source temp file entirely; larger (or
+// size-unknown) sources, and anything needing delimiter/header transcoding,
+// still spill to disk first. Running ImportToSQLite itself inside a single
+// bulk-load transaction with relaxed journal/sync pragmas is the vendored
+// mksqlite converters' concern, not this package's.
+func (m *Manager) fetchAndConvert(ctx context.Context, ref source.SourceRef, alias string) (dbPath string, sha256Hex string, err error) {
+	return m.fetchAndConvertImpl(ctx, ref, alias)
+}
+
+func (m *Manager) fetchAndConvertImpl(ctx context.Context, ref source.SourceRef, alias string) (string, string, error) {
+	sourcePath := ref.Path
+
+	// Prepare output file
+	tmpOut, err := os.CreateTemp(m.dir(), "flight2_db_*.sqlite")
+	if err != nil {
+		return "", "", err
+	}
 	tmpOutName := tmpOut.Name()
+	var sha256Hex string
 
-	// Check if sourcePath is a local directory, but only if type is local
-	isLocal := false
-	if t, ok := creds["type"].(string); ok && t == "local" {
-		isLocal = true
+	overrides, _, err := m.overrideStore.get(alias)
+	if err != nil && m.verbose {
+		log.Printf("failed to load import overrides for alias %q, using defaults: %v", alias, err)
 	}
 
+	// Check if sourcePath is a local directory, but only if type is local
+	isLocal := ref.Backend == "local"
+
 	isDir := false
 	if isLocal {
 		if info, err := os.Stat(sourcePath); err == nil && info.IsDir() {
@@ -125,12 +646,18 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 		}
 	}
 
+	// converted tracks whether this call ran content through an importer
+	// (as opposed to copying an already-valid SQLite file through
+	// unchanged), since only converted output is all-TEXT and worth
+	// running schema inference over.
+	converted := false
+
 	if isDir {
 		f, err := os.Open(sourcePath)
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", err
+			return "", "", err
 		}
 
 		conv, err := converters.Open("filesystem", f, &common.ConversionConfig{Verbose: m.verbose})
@@ -138,7 +665,7 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 			f.Close()
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", fmt.Errorf("failed to open filesystem converter: %w", err)
+			return "", "", fmt.Errorf("failed to open filesystem converter: %w", err)
 		}
 
 		err = converters.ImportToSQLite(conv, tmpOut, &converters.ImportOptions{Verbose: m.verbose})
@@ -146,45 +673,140 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", fmt.Errorf("conversion failed: %w", err)
+			return "", "", fmt.Errorf("conversion failed: %w", err)
 		}
+		converted = true
 	} else {
 		// Fetch source stream
-		rc, err := source.GetFileStream(ctx, sourcePath, creds)
+		rc, err := source.GetFileStream(ctx, ref)
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", fmt.Errorf("fetch error: %w", err)
+			return "", "", fmt.Errorf("fetch error: %w", err)
 		}
 		defer rc.Close()
 
 		ext := strings.ToLower(filepath.Ext(sourcePath))
 
-		tmpSource, err := os.CreateTemp(m.cacheDir, "flight2_source_*"+ext)
+		// needsTranscode sources (a non-default delimiter, or a header
+		// override) go through transcodeCSV/transcodeNDJSON, which need a
+		// real path to open - the disk-spill path below always handles
+		// those. Everything else that's small enough per
+		// spillThresholdBytes converts directly out of memory, skipping
+		// the source temp file (and its extra disk round-trip) entirely.
+		needsTranscode := overrides.Delimiter != "" || overrides.Header != nil || ext == ".tsv" || ext == ".ndjson"
+		knownSmall := false
+		if info, statErr := source.Stat(ctx, ref); statErr == nil && info.Size > 0 && info.Size <= m.spillThresholdBytes {
+			knownSmall = true
+		}
+
+		if knownSmall && !needsTranscode {
+			var buf bytes.Buffer
+			teed, sum := hashingTee(rc)
+			var reader io.Reader = teed
+			if report, ok := progressFromContext(ctx); ok {
+				reader = progressTee(reader, report)
+			}
+			if _, err := io.Copy(&buf, reader); err != nil {
+				tmpOut.Close()
+				os.Remove(tmpOutName)
+				return "", "", fmt.Errorf("failed to read source: %w", err)
+			}
+			sha256Hex = sum()
+			data := buf.Bytes()
+
+			alreadySQLite := ext == ".db" || ext == ".sqlite" || ext == ".sqlite3" || m.detector.Detect(data) == FormatSQLite
+			if alreadySQLite {
+				if _, err := tmpOut.Write(data); err != nil {
+					tmpOut.Close()
+					os.Remove(tmpOutName)
+					return "", "", err
+				}
+			} else {
+				driver := getDriver(ext)
+				if driver == "" {
+					format := m.detector.Detect(data)
+					if format == FormatParquet {
+						tmpOut.Close()
+						os.Remove(tmpOutName)
+						return "", "", fmt.Errorf("parquet ingestion is not yet supported")
+					}
+					driver = driverForFormat(format)
+				}
+				if driver == "" {
+					tmpOut.Close()
+					os.Remove(tmpOutName)
+					return "", "", fmt.Errorf("unsupported file type: %s", ext)
+				}
+
+				conv, err := converters.Open(driver, bytes.NewReader(data), &common.ConversionConfig{Verbose: m.verbose})
+				if err != nil {
+					tmpOut.Close()
+					os.Remove(tmpOutName)
+					return "", "", fmt.Errorf("failed to open converter for %s: %w", driver, err)
+				}
+				if c, ok := conv.(io.Closer); ok {
+					defer c.Close()
+				}
+
+				err = converters.ImportToSQLite(conv, tmpOut, &converters.ImportOptions{Verbose: m.verbose})
+				if err != nil {
+					tmpOut.Close()
+					os.Remove(tmpOutName)
+					return "", "", fmt.Errorf("conversion failed: %w", err)
+				}
+				converted = true
+			}
+
+			tmpOut.Close()
+			if converted {
+				if err := inferAndRetype(tmpOutName, overrides.Types); err != nil {
+					log.Printf("schema inference failed for %s, leaving columns as TEXT: %v", sourcePath, err)
+				}
+			}
+			return tmpOutName, sha256Hex, nil
+		}
+
+		tmpSource, err := os.CreateTemp(m.dir(), "flight2_source_*"+ext)
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", err
+			return "", "", err
 		}
 		tmpSourceName := tmpSource.Name()
 		defer os.Remove(tmpSourceName)
 
-		_, err = io.Copy(tmpSource, rc)
+		teed, sum := hashingTee(rc)
+		var reader io.Reader = teed
+		if report, ok := progressFromContext(ctx); ok {
+			reader = progressTee(reader, report)
+		}
+		_, err = io.Copy(tmpSource, reader)
+		sha256Hex = sum()
 		tmpSource.Close() // Close source file so we can open it for read or it's flushed
 		if err != nil {
 			tmpOut.Close()
 			os.Remove(tmpOutName)
-			return "", fmt.Errorf("failed to write source temp file: %w", err)
+			return "", "", fmt.Errorf("failed to write source temp file: %w", err)
 		}
 
-		// Check if it's already sqlite
-		if ext == ".db" || ext == ".sqlite" || ext == ".sqlite3" {
+		// Check if it's already sqlite: the extension is the cheap first
+		// guess, but rclone sources frequently arrive with a missing,
+		// wrong, or generic extension, so fall back to sniffing the
+		// SQLite file header when the extension doesn't already say so.
+		alreadySQLite := ext == ".db" || ext == ".sqlite" || ext == ".sqlite3"
+		if !alreadySQLite {
+			if sample, serr := readSample(tmpSourceName); serr == nil && m.detector.Detect(sample) == FormatSQLite {
+				alreadySQLite = true
+			}
+		}
+		if alreadySQLite {
 			// Just copy source to output
 			srcF, err := os.Open(tmpSourceName)
 			if err != nil {
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", err
+				return "", "", err
 			}
 
 			_, err = io.Copy(tmpOut, srcF)
@@ -192,22 +814,26 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 			if err != nil {
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", err
+				return "", "", err
 			}
 		} else {
-			// Convert
-			driver := getDriver(ext)
-			if driver == "" {
+			// Convert. The extension picks the driver when it's recognized;
+			// otherwise (or when overrides ask for a different delimiter,
+			// header, or the content doesn't match the extension) fall
+			// back to sniffing the content itself.
+			driver, convSourceName, cleanupConv, err := m.prepareConversionInput(ext, tmpSourceName, overrides)
+			if err != nil {
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", fmt.Errorf("unsupported file type: %s", ext)
+				return "", "", err
 			}
+			defer cleanupConv()
 
-			srcF, err := os.Open(tmpSourceName)
+			srcF, err := os.Open(convSourceName)
 			if err != nil {
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", err
+				return "", "", err
 			}
 
 			conv, err := converters.Open(driver, srcF, &common.ConversionConfig{Verbose: m.verbose})
@@ -215,7 +841,7 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 				srcF.Close()
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", fmt.Errorf("failed to open converter for %s: %w", driver, err)
+				return "", "", fmt.Errorf("failed to open converter for %s: %w", driver, err)
 			}
 
 			// Handle Closer interface for converter
@@ -228,40 +854,90 @@ func (m *Manager) GetSQLiteDB(ctx context.Context, sourcePath string, creds map[
 			if err != nil {
 				tmpOut.Close()
 				os.Remove(tmpOutName)
-				return "", fmt.Errorf("conversion failed: %w", err)
+				return "", "", fmt.Errorf("conversion failed: %w", err)
 			}
+			converted = true
 		}
 	}
 
 	tmpOut.Close()
 
-	// Read the result back to memory to store in cache
-	data, err := os.ReadFile(tmpOutName)
-	if err != nil {
-		return "", fmt.Errorf("failed to read converted db: %w", err)
+	if converted {
+		if err := inferAndRetype(tmpOutName, overrides.Types); err != nil {
+			// A bad schema-inference pass shouldn't fail an otherwise
+			// successful ingestion: the all-TEXT columns it would have
+			// retyped are still usable, just less convenient to query.
+			log.Printf("schema inference failed for %s, leaving columns as TEXT: %v", sourcePath, err)
+		}
 	}
 
-	// Update Caches
-	// 1. Memory
-	err = m.cache.Set(key, data)
-	if err != nil {
-		fmt.Printf("Warning: failed to set cache: %v\n", err)
-	}
-	// 2. Disk
-	if err := os.WriteFile(diskPath, data, 0644); err != nil {
-		fmt.Printf("Warning: failed to write disk cache: %v\n", err)
-	} else {
+	return tmpOutName, sha256Hex, nil
+}
+
+// prepareConversionInput picks the importer driver for tmpSourceName and
+// returns the file converters.Open should actually read. Usually that's
+// tmpSourceName unchanged; content that needs transcoding before the
+// downstream driver can understand it - a non-comma delimiter, a missing
+// header, or NDJSON instead of a single JSON document - gets rewritten to a
+// sibling temp file first, and cleanup removes that file once the caller's
+// conversion is done with it.
+func (m *Manager) prepareConversionInput(ext, tmpSourceName string, overrides ImportOverrides) (driver, inputPath string, cleanup func(), err error) {
+	cleanup = func() {}
+	inputPath = tmpSourceName
+
+	driver = getDriver(ext)
+
+	var format DetectedFormat
+	if sample, serr := readSample(tmpSourceName); serr != nil {
 		if m.verbose {
-			fmt.Printf("💾 [CACHE SAVED] Written to disk: %s\n", diskPath)
+			log.Printf("format detection failed for %s: %v", tmpSourceName, serr)
 		}
+	} else {
+		format = m.detector.Detect(sample)
 	}
 
-	return tmpOutName, nil
+	if driver == "" {
+		if format == FormatParquet {
+			return "", "", cleanup, fmt.Errorf("parquet ingestion is not yet supported")
+		}
+		driver = driverForFormat(format)
+		if driver == "" {
+			return "", "", cleanup, fmt.Errorf("unsupported file type: %s", ext)
+		}
+	}
+
+	delim := byte(',')
+	switch {
+	case overrides.Delimiter != "":
+		delim = overrides.Delimiter[0]
+	case driver == "csv" && (ext == ".tsv" || format == FormatTSV):
+		delim = '\t'
+	}
+	noHeader := overrides.Header != nil && !*overrides.Header
+
+	switch {
+	case driver == "csv" && (delim != ',' || noHeader):
+		out, err := transcodeCSV(m.dir(), tmpSourceName, delim, noHeader)
+		if err != nil {
+			return "", "", cleanup, fmt.Errorf("normalizing delimited input: %w", err)
+		}
+		inputPath = out
+		cleanup = func() { os.Remove(out) }
+	case driver == "json" && format == FormatNDJSON:
+		out, err := transcodeNDJSON(m.dir(), tmpSourceName)
+		if err != nil {
+			return "", "", cleanup, fmt.Errorf("wrapping NDJSON input: %w", err)
+		}
+		inputPath = out
+		cleanup = func() { os.Remove(out) }
+	}
+
+	return driver, inputPath, cleanup, nil
 }
 
 func getDriver(ext string) string {
 	switch ext {
-	case ".csv":
+	case ".csv", ".tsv":
 		return "csv"
 	case ".xlsx", ".xls":
 		return "excel"
@@ -269,7 +945,7 @@ func getDriver(ext string) string {
 		return "zip"
 	case ".html", ".htm":
 		return "html"
-	case ".json":
+	case ".json", ".ndjson":
 		return "json"
 	case ".txt":
 		return "txt"
@@ -278,7 +954,7 @@ func getDriver(ext string) string {
 }
 
 func (m *Manager) writeTempFile(data []byte) (string, error) {
-	f, err := os.CreateTemp(m.cacheDir, "flight2_cache_*.sqlite")
+	f, err := os.CreateTemp(m.dir(), "flight2_cache_*.sqlite")
 	if err != nil {
 		return "", err
 	}
@@ -289,3 +965,22 @@ func (m *Manager) writeTempFile(data []byte) (string, error) {
 	}
 	return f.Name(), nil
 }
+
+// writeTempFileFromPath materializes a per-request temp file for an
+// already-cached artifact at srcPath, without reading its bytes through
+// the process. It reserves a unique name in cacheDir the same way
+// os.CreateTemp does, then hard-links srcPath onto it.
+func (m *Manager) writeTempFileFromPath(srcPath string) (string, error) {
+	f, err := os.CreateTemp(m.dir(), "flight2_cache_*.sqlite")
+	if err != nil {
+		return "", err
+	}
+	dst := f.Name()
+	f.Close()
+	os.Remove(dst)
+
+	if err := linkOrCopy(srcPath, dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"flight2/internal/source"
 )
 
 // Type: Integration Test
@@ -33,11 +35,12 @@ func TestManager_GetSQLiteDB_ExtensionResolution(t *testing.T) {
 	creds := map[string]interface{}{
 		"type": "local",
 	}
+	source.RegisterProfile("test-alias", creds)
 
 	// Request "testfile" (without extension)
 	reqPath := filepath.Join(tempDir, "testfile")
 
-	dbPath, err := mgr.GetSQLiteDB(context.Background(), reqPath, creds, "test-alias")
+	dbPath, err := mgr.GetSQLiteDB(context.Background(), source.SourceRef{Backend: "local", Path: reqPath, ProfileID: "test-alias"}, "test-alias")
 	if err != nil {
 		t.Fatalf("Failed to resolve extension: %v", err)
 	}
@@ -61,7 +64,7 @@ func TestManager_GetSQLiteDB_ExtensionResolution(t *testing.T) {
 	// If I request `testfile.csv`, key is `...:testfile.csv`.
 	// So they should share cache! This is great.
 
-	dbPath2, err := mgr.GetSQLiteDB(context.Background(), csvPath, creds, "test-alias")
+	dbPath2, err := mgr.GetSQLiteDB(context.Background(), source.SourceRef{Backend: "local", Path: csvPath, ProfileID: "test-alias"}, "test-alias")
 	if err != nil {
 		t.Fatalf("Failed to get DB with explicit extension: %v", err)
 	}
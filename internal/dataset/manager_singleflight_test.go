@@ -0,0 +1,149 @@
+package dataset
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flight2/internal/source"
+)
+
+// slowDetector forwards to defaultDetector but counts calls and sleeps
+// first, standing in for a slow fetch+convert so concurrent callers
+// actually pile up on sfGroup.Do instead of racing to finish one at a
+// time. fetchAndConvertImpl's in-memory conversion path calls Detect
+// exactly once per real fetch+convert, so counting calls is equivalent to
+// counting how many times the source was actually fetched and converted.
+type slowDetector struct {
+	calls *atomic.Int64
+	delay time.Duration
+}
+
+func (d slowDetector) Detect(sample []byte) DetectedFormat {
+	d.calls.Add(1)
+	time.Sleep(d.delay)
+	return defaultDetector{}.Detect(sample)
+}
+
+// Type: Integration Test
+func TestManager_GetSQLiteDB_CoalescesConcurrentMisses(t *testing.T) {
+	testOutputDir, _ := filepath.Abs("../../test_output")
+	if err := os.MkdirAll(testOutputDir, 0755); err != nil {
+		t.Fatalf("Failed to create test_output: %v", err)
+	}
+
+	f, err := os.CreateTemp(testOutputDir, "coalesce*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("id,name\n1,Alice\n2,Bob")
+	f.Close()
+
+	var calls atomic.Int64
+	mgr, err := NewManager(true, t.TempDir(), WithDetector(slowDetector{calls: &calls, delay: 200 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := map[string]interface{}{"type": "local"}
+	source.RegisterProfile("test-alias-coalesce", creds)
+	ref := source.SourceRef{Backend: "local", Path: f.Name(), ProfileID: "test-alias-coalesce"}
+
+	const n = 50
+	var wg sync.WaitGroup
+	paths := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = mgr.GetSQLiteDB(context.Background(), ref, "test-alias-coalesce")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, p := range paths {
+		if errs[i] != nil {
+			t.Fatalf("goroutine %d: GetSQLiteDB failed: %v", i, errs[i])
+		}
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			t.Fatalf("goroutine %d: db file not created at %s", i, p)
+		}
+		os.Remove(p)
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected exactly 1 fetch+convert across %d concurrent misses, got %d", n, got)
+	}
+}
+
+// Type: Integration Test
+//
+// TestManager_GetSQLiteDB_SingleflightSurvivesLeaderCancellation guards
+// against the shared fetch being bound to whichever caller's ctx happens
+// to win the race to lead sfGroup.Do: if the leader's own ctx is already
+// cancelled, every coalesced follower must still succeed, since their own
+// contexts are still live and have nothing to do with the leader's.
+func TestManager_GetSQLiteDB_SingleflightSurvivesLeaderCancellation(t *testing.T) {
+	testOutputDir, _ := filepath.Abs("../../test_output")
+	if err := os.MkdirAll(testOutputDir, 0755); err != nil {
+		t.Fatalf("Failed to create test_output: %v", err)
+	}
+
+	f, err := os.CreateTemp(testOutputDir, "coalesce_cancel*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("id,name\n1,Alice\n2,Bob")
+	f.Close()
+
+	var calls atomic.Int64
+	mgr, err := NewManager(true, t.TempDir(), WithDetector(slowDetector{calls: &calls, delay: 200 * time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := map[string]interface{}{"type": "local"}
+	source.RegisterProfile("test-alias-coalesce-cancel", creds)
+	ref := source.SourceRef{Backend: "local", Path: f.Name(), ProfileID: "test-alias-coalesce-cancel"}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	cancel() // already cancelled before the leader's sfGroup.Do closure runs
+
+	const followers = 10
+	var wg sync.WaitGroup
+	paths := make([]string, followers+1)
+	errs := make([]error, followers+1)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		paths[0], errs[0] = mgr.GetSQLiteDB(leaderCtx, ref, "test-alias-coalesce-cancel")
+	}()
+	time.Sleep(20 * time.Millisecond) // give the leader a head start on sfGroup.Do
+
+	for i := 1; i <= followers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = mgr.GetSQLiteDB(context.Background(), ref, "test-alias-coalesce-cancel")
+		}(i)
+	}
+	wg.Wait()
+
+	for i, p := range paths {
+		if errs[i] != nil {
+			t.Fatalf("goroutine %d: GetSQLiteDB failed: %v", i, errs[i])
+		}
+		if _, err := os.Stat(p); os.IsNotExist(err) {
+			t.Fatalf("goroutine %d: db file not created at %s", i, p)
+		}
+		os.Remove(p)
+	}
+}
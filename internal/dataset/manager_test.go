@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	"flight2/internal/source"
 )
 
 // Mocking source fetch or just testing logic?
@@ -42,11 +44,13 @@ func TestManager_GetSQLiteDB_LocalFile(t *testing.T) {
 	creds := map[string]interface{}{
 		"type": "local",
 	}
+	source.RegisterProfile("test-alias", creds)
 
 	// The source path needs to be absolute for local backend to work reliably in test
 	absPath := f.Name()
+	ref := source.SourceRef{Backend: "local", Path: absPath, ProfileID: "test-alias"}
 
-	dbPath, err := mgr.GetSQLiteDB(context.Background(), absPath, creds, "test-alias")
+	dbPath, err := mgr.GetSQLiteDB(context.Background(), ref, "test-alias")
 	if err != nil {
 		t.Fatalf("GetSQLiteDB failed: %v", err)
 	}
@@ -58,7 +62,7 @@ func TestManager_GetSQLiteDB_LocalFile(t *testing.T) {
 
 	// Test Cache
 	// If we call again, it should come from cache (check logs if we could, but here we just check it works)
-	dbPath2, err := mgr.GetSQLiteDB(context.Background(), absPath, creds, "test-alias")
+	dbPath2, err := mgr.GetSQLiteDB(context.Background(), ref, "test-alias")
 	if err != nil {
 		t.Fatalf("GetSQLiteDB cached failed: %v", err)
 	}
@@ -67,3 +71,44 @@ func TestManager_GetSQLiteDB_LocalFile(t *testing.T) {
 	// Check content?
 	// We assume mksqlite works if the file exists.
 }
+
+// Type: Integration Test
+//
+// Forces WithSpillThresholdBytes(0) so the same small CSV that
+// TestManager_GetSQLiteDB_LocalFile converts entirely from memory instead
+// spills to disk, exercising the other fetchAndConvertImpl branch.
+func TestManager_GetSQLiteDB_SpillsToDiskAboveThreshold(t *testing.T) {
+	testOutputDir, _ := filepath.Abs("../../test_output")
+	if err := os.MkdirAll(testOutputDir, 0755); err != nil {
+		t.Fatalf("Failed to create test_output: %v", err)
+	}
+
+	f, err := os.CreateTemp(testOutputDir, "test*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+
+	f.WriteString("id,name\n1,Alice\n2,Bob")
+	f.Close()
+
+	mgr, err := NewManager(true, t.TempDir(), WithSpillThresholdBytes(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds := map[string]interface{}{"type": "local"}
+	source.RegisterProfile("test-alias-spill", creds)
+
+	ref := source.SourceRef{Backend: "local", Path: f.Name(), ProfileID: "test-alias-spill"}
+
+	dbPath, err := mgr.GetSQLiteDB(context.Background(), ref, "test-alias-spill")
+	if err != nil {
+		t.Fatalf("GetSQLiteDB failed: %v", err)
+	}
+	defer os.Remove(dbPath)
+
+	if _, err := os.Stat(dbPath); os.IsNotExist(err) {
+		t.Fatalf("DB file not created at %s", dbPath)
+	}
+}
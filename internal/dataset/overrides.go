@@ -0,0 +1,95 @@
+package dataset
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ImportOverrides corrects a Detector or schema-inference guess for one
+// alias without requiring the source to be re-uploaded: Delimiter and
+// Header steer how delimited content is parsed, and Types pins specific
+// columns (by name) to a SQLite affinity instead of letting
+// inferColumnAffinity guess.
+type ImportOverrides struct {
+	Delimiter string            `json:"delimiter,omitempty"`
+	Header    *bool             `json:"header,omitempty"`
+	Types     map[string]string `json:"types,omitempty"`
+}
+
+// overrideStore persists ImportOverrides per alias in a small SQLite
+// database, following the same single-table pattern as jobStore.
+type overrideStore struct {
+	db *sql.DB
+}
+
+func newOverrideStore(dbPath string) (*overrideStore, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import overrides db: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS import_overrides (
+		alias TEXT PRIMARY KEY,
+		data  TEXT NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create import_overrides table: %w", err)
+	}
+
+	return &overrideStore{db: db}, nil
+}
+
+func (s *overrideStore) set(alias string, overrides ImportOverrides) error {
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO import_overrides (alias, data) VALUES (?, ?)
+		ON CONFLICT(alias) DO UPDATE SET data = excluded.data`, alias, string(data))
+	return err
+}
+
+func (s *overrideStore) get(alias string) (ImportOverrides, bool, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM import_overrides WHERE alias = ?`, alias).Scan(&data)
+	if err == sql.ErrNoRows {
+		return ImportOverrides{}, false, nil
+	}
+	if err != nil {
+		return ImportOverrides{}, false, err
+	}
+	var overrides ImportOverrides
+	if err := json.Unmarshal([]byte(data), &overrides); err != nil {
+		return ImportOverrides{}, false, err
+	}
+	return overrides, true, nil
+}
+
+func (s *overrideStore) delete(alias string) error {
+	_, err := s.db.Exec(`DELETE FROM import_overrides WHERE alias = ?`, alias)
+	return err
+}
+
+func (s *overrideStore) close() error {
+	return s.db.Close()
+}
+
+// SetImportOverrides records per-alias corrections (delimiter, header,
+// column types) applied the next time alias is ingested.
+func (m *Manager) SetImportOverrides(alias string, overrides ImportOverrides) error {
+	return m.overrideStore.set(alias, overrides)
+}
+
+// GetImportOverrides returns alias's overrides, or ok=false if none have
+// been set.
+func (m *Manager) GetImportOverrides(alias string) (overrides ImportOverrides, ok bool, err error) {
+	return m.overrideStore.get(alias)
+}
+
+// DeleteImportOverrides clears any overrides recorded for alias.
+func (m *Manager) DeleteImportOverrides(alias string) error {
+	return m.overrideStore.delete(alias)
+}
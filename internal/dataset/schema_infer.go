@@ -0,0 +1,199 @@
+package dataset
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// maxSchemaInferenceRows bounds how many rows inferColumnAffinity samples
+// per column before committing to an affinity, so inference stays fast
+// even on huge imports.
+const maxSchemaInferenceRows = 2000
+
+// inferAndRetype samples up to maxSchemaInferenceRows rows of every table
+// in dbPath and rewrites its columns - all TEXT, the importer's only
+// affinity - to INTEGER/REAL/TEXT based on what the sampled values actually
+// look like. overrideTypes pins a column (by name, across every table) to
+// an explicit affinity instead of letting it be inferred.
+func inferAndRetype(dbPath string, overrideTypes map[string]string) error {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("schema inference: open: %w", err)
+	}
+	defer db.Close()
+
+	tables, err := listTables(db)
+	if err != nil {
+		return fmt.Errorf("schema inference: list tables: %w", err)
+	}
+
+	for _, table := range tables {
+		if err := retypeTable(db, table, overrideTypes); err != nil {
+			return fmt.Errorf("schema inference: table %q: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func listTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// columnAffinity is the inferred (or overridden) SQLite type for one
+// column of a table being retyped.
+type columnAffinity struct {
+	name     string
+	affinity string
+}
+
+func retypeTable(db *sql.DB, table string, overrideTypes map[string]string) error {
+	cols, err := tableColumns(db, table)
+	if err != nil {
+		return err
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+
+	affinities := make([]columnAffinity, len(cols))
+	for i, col := range cols {
+		if t, ok := overrideTypes[col]; ok {
+			affinities[i] = columnAffinity{name: col, affinity: t}
+			continue
+		}
+		affinity, err := inferColumnAffinity(db, table, col)
+		if err != nil {
+			return err
+		}
+		affinities[i] = columnAffinity{name: col, affinity: affinity}
+	}
+
+	return rebuildTable(db, table, affinities)
+}
+
+func tableColumns(db *sql.DB, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, quoteIdent(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
+
+// inferColumnAffinity samples col's values and reports INTEGER if every
+// non-null, non-empty sample parses as an integer, REAL if every one parses
+// as a float, or TEXT otherwise (including when there are no such samples
+// to judge by). Empty strings are skipped the same way NULLs are.
+func inferColumnAffinity(db *sql.DB, table, col string) (string, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s LIMIT %d`, quoteIdent(col), quoteIdent(table), maxSchemaInferenceRows)
+	rows, err := db.Query(query)
+	if err != nil {
+		return "TEXT", err
+	}
+	defer rows.Close()
+
+	isInt, isReal, sawValue := true, true, false
+	for rows.Next() {
+		var v sql.NullString
+		if err := rows.Scan(&v); err != nil {
+			return "TEXT", err
+		}
+		if !v.Valid || v.String == "" {
+			continue
+		}
+		sawValue = true
+		if _, err := strconv.ParseInt(v.String, 10, 64); err != nil {
+			isInt = false
+		}
+		if _, err := strconv.ParseFloat(v.String, 64); err != nil {
+			isReal = false
+		}
+		if !isInt && !isReal {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "TEXT", err
+	}
+
+	switch {
+	case !sawValue:
+		return "TEXT", nil
+	case isInt:
+		return "INTEGER", nil
+	case isReal:
+		return "REAL", nil
+	default:
+		return "TEXT", nil
+	}
+}
+
+// rebuildTable recreates table with the given column affinities: SQLite
+// has no ALTER COLUMN, so this builds a sibling table, copies data through
+// a CAST per column, then swaps it in under the original name.
+func rebuildTable(db *sql.DB, table string, cols []columnAffinity) error {
+	tmpName := table + "__typed"
+
+	defs := make([]string, len(cols))
+	selects := make([]string, len(cols))
+	for i, c := range cols {
+		defs[i] = fmt.Sprintf("%s %s", quoteIdent(c.name), c.affinity)
+		selects[i] = fmt.Sprintf("CAST(%s AS %s)", quoteIdent(c.name), c.affinity)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, quoteIdent(tmpName))); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`CREATE TABLE %s (%s)`, quoteIdent(tmpName), strings.Join(defs, ", "))); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`INSERT INTO %s SELECT %s FROM %s`, quoteIdent(tmpName), strings.Join(selects, ", "), quoteIdent(table))); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE %s`, quoteIdent(table))); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s RENAME TO %s`, quoteIdent(tmpName), quoteIdent(table))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func quoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
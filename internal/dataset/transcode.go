@@ -0,0 +1,141 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// transcodeCSV re-delimits srcPath (read with the given delim) into a
+// sibling comma-CSV temp file under dir, since the downstream csv driver
+// only understands commas. When noHeader is true, srcPath's first row is
+// data rather than column names, so a synthetic col0..colN-1 header is
+// written ahead of it.
+func transcodeCSV(dir, srcPath string, delim byte, noHeader bool) (string, error) {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	r := csv.NewReader(in)
+	r.Comma = rune(delim)
+	r.FieldsPerRecord = -1
+
+	out, err := os.CreateTemp(dir, "flight2_transcode_*.csv")
+	if err != nil {
+		return "", err
+	}
+	outName := out.Name()
+	w := csv.NewWriter(out)
+
+	fail := func(err error) (string, error) {
+		out.Close()
+		os.Remove(outName)
+		return "", err
+	}
+
+	first := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("reading delimited input: %w", err))
+		}
+		if first && noHeader {
+			header := make([]string, len(record))
+			for i := range header {
+				header[i] = fmt.Sprintf("col%d", i)
+			}
+			if err := w.Write(header); err != nil {
+				return fail(err)
+			}
+		}
+		first = false
+		if err := w.Write(record); err != nil {
+			return fail(err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fail(err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(outName)
+		return "", err
+	}
+	return outName, nil
+}
+
+// transcodeNDJSON wraps newline-delimited JSON objects in srcPath into a
+// single JSON array in a sibling temp file under dir, since the downstream
+// json driver expects one document, not one per line.
+func transcodeNDJSON(dir, srcPath string) (string, error) {
+	in, err := os.Open(srcPath)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := os.CreateTemp(dir, "flight2_transcode_*.json")
+	if err != nil {
+		return "", err
+	}
+	outName := out.Name()
+
+	fail := func(err error) (string, error) {
+		out.Close()
+		os.Remove(outName)
+		return "", err
+	}
+
+	if _, err := out.WriteString("["); err != nil {
+		return fail(err)
+	}
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return fail(fmt.Errorf("invalid NDJSON line: %s", truncate(line, 80)))
+		}
+		if !first {
+			if _, err := out.WriteString(","); err != nil {
+				return fail(err)
+			}
+		}
+		first = false
+		if _, err := out.Write(line); err != nil {
+			return fail(err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fail(err)
+	}
+	if _, err := out.WriteString("]"); err != nil {
+		return fail(err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(outName)
+		return "", err
+	}
+	return outName, nil
+}
+
+func truncate(b []byte, max int) string {
+	if len(b) > max {
+		return string(b[:max]) + "..."
+	}
+	return string(b)
+}
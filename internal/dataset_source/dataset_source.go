@@ -0,0 +1,97 @@
+// Package dataset_source adapts the browse/view handlers' raw credential
+// maps (as returned by secrets.Service.GetCredentials) onto the
+// profile-keyed source.BackendRegistry, so those flows don't need to track
+// a source.SourceRef/profile of their own the way handleBanquet and
+// dataset.Manager do.
+package dataset_source
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"flight2/internal/source"
+)
+
+// anonProfileID derives a stable profile id from creds' content rather
+// than a user-facing alias, since callers here (handleBrowse, handleView)
+// only ever have a raw creds map in hand. Calls with the same credentials
+// reuse source's cached VFS instance instead of rebuilding an fs.Fs per
+// request.
+func anonProfileID(creds map[string]interface{}) string {
+	keys := make([]string, 0, len(creds))
+	for k := range creds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := md5.New()
+	for _, k := range keys {
+		io.WriteString(h, k)
+		io.WriteString(h, fmt.Sprint(creds[k]))
+	}
+	return "anon_" + hex.EncodeToString(h.Sum(nil))
+}
+
+// ref registers creds under its derived profile id and builds the
+// source.SourceRef relPath resolves to.
+func ref(relPath string, creds map[string]interface{}) source.SourceRef {
+	profileID := anonProfileID(creds)
+	source.RegisterProfile(profileID, creds)
+	backend, _ := creds["type"].(string)
+	return source.SourceRef{Backend: backend, Path: relPath, ProfileID: profileID}
+}
+
+// ListEntries lists relPath's directory using creds' backend.
+func ListEntries(ctx context.Context, relPath string, creds map[string]interface{}) ([]os.FileInfo, error) {
+	return source.ListEntries(ctx, ref(relPath, creds))
+}
+
+// GetFileStream opens relPath for reading using creds' backend.
+func GetFileStream(ctx context.Context, relPath string, creds map[string]interface{}) (io.ReadCloser, error) {
+	return source.GetFileStream(ctx, ref(relPath, creds))
+}
+
+// GetFileRange opens relPath starting at offset and limited to length
+// bytes (or to EOF if length < 0), using creds' backend. Backends with a
+// native ranged GET translate this into one instead of a full download -
+// see source.BackendRegistry.GetFileRange.
+func GetFileRange(ctx context.Context, relPath string, offset, length int64, creds map[string]interface{}) (io.ReadCloser, error) {
+	return source.GetFileRange(ctx, ref(relPath, creds), offset, length)
+}
+
+// Stat probes relPath for its size, modification time and (when the
+// backend exposes one) content hash, without opening it for read. See
+// source.Stat.
+func Stat(ctx context.Context, relPath string, creds map[string]interface{}) (source.ObjectInfo, error) {
+	return source.Stat(ctx, ref(relPath, creds))
+}
+
+// PutFileStream writes src to relPath using creds' backend, creating or
+// truncating the destination. See source.BackendRegistry.PutFileStream.
+func PutFileStream(ctx context.Context, relPath string, creds map[string]interface{}, src io.Reader) error {
+	return source.PutFileStream(ctx, ref(relPath, creds), src)
+}
+
+// Mkdir creates relPath as a directory using creds' backend, including any
+// missing parents. See source.BackendRegistry.Mkdir.
+func Mkdir(ctx context.Context, relPath string, creds map[string]interface{}) error {
+	return source.Mkdir(ctx, ref(relPath, creds))
+}
+
+// Move renames relPath to newPath using creds' backend. See
+// source.BackendRegistry.Move.
+func Move(ctx context.Context, relPath, newPath string, creds map[string]interface{}) error {
+	return source.Move(ctx, ref(relPath, creds), newPath)
+}
+
+// Remove deletes relPath using creds' backend. recursive removes a
+// non-empty directory and its contents rather than rejecting the call. See
+// source.BackendRegistry.Remove.
+func Remove(ctx context.Context, relPath string, creds map[string]interface{}, recursive bool) error {
+	return source.Remove(ctx, ref(relPath, creds), recursive)
+}
@@ -0,0 +1,400 @@
+// Package index crawls remote aliases through dataset_source.ListEntries
+// and persists a searchable SQLite index of what it finds, so
+// handleSearch can answer name/glob/extension/size/modified-time queries
+// without touching the backend at request time.
+//
+// The index itself is a plain file_entries table; file_entries_fts is an
+// FTS5 virtual table (requires the go-sqlite3 driver to be built with
+// -tags sqlite_fts5) kept in step with it by explicit insert/delete calls
+// rather than triggers, mirroring how dataset's jobStore hand-rolls its
+// upserts instead of relying on an ORM.
+package index
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"flight2/internal/dataset_source"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// CrawlState is the lifecycle state of an alias's crawl, reported by
+// Status and polled by GET /app/index/status/{alias}.
+type CrawlState string
+
+const (
+	CrawlIdle     CrawlState = "idle"
+	CrawlCrawling CrawlState = "crawling"
+	CrawlDone     CrawlState = "done"
+	CrawlError    CrawlState = "error"
+)
+
+// CrawlStatus records one alias's crawl progress, returned as JSON by
+// handleIndexStatus so the browse UI can show a progress indicator for the
+// "Reindex" button.
+type CrawlStatus struct {
+	Alias        string     `json:"alias"`
+	State        CrawlState `json:"state"`
+	FilesIndexed int        `json:"files_indexed"`
+	StartedAt    time.Time  `json:"started_at,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at,omitempty"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Row is one indexed file, as returned by Search.
+type Row struct {
+	Alias string
+	Path  string
+	Name  string
+	Size  int64
+	Mtime time.Time
+	Mime  string
+}
+
+// Query narrows Search to a subset of an alias's indexed files. Zero
+// values are "no predicate": an empty Q, Glob, Ext skip their filter, a
+// zero MinSize and a zero ModifiedSince likewise.
+type Query struct {
+	Q             string // FTS5 match against name or path: whole tokens only (e.g. "2024" in "2024.csv"), not arbitrary substrings
+	Glob          string // SQLite GLOB pattern against name
+	Ext           string // file extension, with or without a leading dot
+	MinSize       int64
+	ModifiedSince time.Time
+}
+
+// Index holds the crawl index's SQLite handle plus the in-flight status of
+// each alias's crawl. crawlConcurrency bounds how many ListEntries calls
+// one alias's crawl runs at once; it does not limit how many aliases can
+// crawl concurrently, matching per-alias rate limits (e.g. an R2 bucket's
+// request quota) rather than a process-wide one.
+type Index struct {
+	db               *sql.DB
+	crawlConcurrency int
+
+	statusMu sync.Mutex
+	status   map[string]*CrawlStatus
+}
+
+// NewIndex opens (creating if necessary) the crawl index database at
+// dbPath. crawlConcurrency defaults to 4 if <= 0, matching
+// dataset.WithJobWorkers' default.
+func NewIndex(dbPath string, crawlConcurrency int) (*Index, error) {
+	if crawlConcurrency <= 0 {
+		crawlConcurrency = 4
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index db: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS file_entries (
+		id    INTEGER PRIMARY KEY AUTOINCREMENT,
+		alias TEXT NOT NULL,
+		path  TEXT NOT NULL,
+		name  TEXT NOT NULL,
+		size  INTEGER NOT NULL,
+		mtime INTEGER NOT NULL,
+		mime  TEXT NOT NULL DEFAULT '',
+		UNIQUE(alias, path)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create file_entries: %w", err)
+	}
+	if _, err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS file_entries_fts USING fts5(
+		name, path
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create file_entries_fts (was go-sqlite3 built with -tags sqlite_fts5?): %w", err)
+	}
+
+	return &Index{db: db, crawlConcurrency: crawlConcurrency, status: make(map[string]*CrawlStatus)}, nil
+}
+
+// Close releases the index's database handle.
+func (idx *Index) Close() error {
+	return idx.db.Close()
+}
+
+// Status returns alias's most recent crawl status, or an idle status if
+// TriggerCrawl has never been called for it.
+func (idx *Index) Status(alias string) CrawlStatus {
+	idx.statusMu.Lock()
+	defer idx.statusMu.Unlock()
+	if st, ok := idx.status[alias]; ok {
+		return *st
+	}
+	return CrawlStatus{Alias: alias, State: CrawlIdle}
+}
+
+// TriggerCrawl starts a background crawl of alias if one isn't already
+// running, returning false without starting a second one if it is - the
+// same "cheap to call repeatedly" shape as dataset.Manager.SubmitJob's
+// fresh-cache short circuit. The crawl runs until ctx is cancelled or it
+// finishes on its own.
+func (idx *Index) TriggerCrawl(ctx context.Context, alias string, creds map[string]interface{}) bool {
+	idx.statusMu.Lock()
+	if st, ok := idx.status[alias]; ok && st.State == CrawlCrawling {
+		idx.statusMu.Unlock()
+		return false
+	}
+	st := &CrawlStatus{Alias: alias, State: CrawlCrawling, StartedAt: time.Now(), UpdatedAt: time.Now()}
+	idx.status[alias] = st
+	idx.statusMu.Unlock()
+
+	go idx.crawl(ctx, alias, creds, st)
+	return true
+}
+
+// crawl walks alias from its root, diffing each file against the existing
+// index by mtime so an unchanged file costs one SELECT instead of a
+// rewrite, then prunes rows for paths no longer present. Directories are
+// walked concurrently, bounded by idx.crawlConcurrency outstanding
+// ListEntries calls.
+func (idx *Index) crawl(ctx context.Context, alias string, creds map[string]interface{}, st *CrawlStatus) {
+	sem := make(chan struct{}, idx.crawlConcurrency)
+	seen := make(map[string]bool)
+	var seenMu sync.Mutex
+	var count int
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	var walk func(dir string)
+	walk = func(dir string) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		entries, err := dataset_source.ListEntries(ctx, dir, creds)
+		<-sem
+		if err != nil {
+			errOnce.Do(func() { firstErr = err })
+			return
+		}
+
+		for _, e := range entries {
+			rel := path.Join(dir, e.Name())
+			if e.IsDir() {
+				wg.Add(1)
+				go walk(rel)
+				continue
+			}
+
+			seenMu.Lock()
+			seen[rel] = true
+			seenMu.Unlock()
+
+			changed, err := idx.upsertIfChanged(alias, rel, e.Name(), e.Size(), e.ModTime())
+			if err != nil {
+				errOnce.Do(func() { firstErr = err })
+				continue
+			}
+			if changed {
+				seenMu.Lock()
+				count++
+				n := count
+				seenMu.Unlock()
+				idx.reportProgress(st, n)
+			}
+		}
+	}
+
+	wg.Add(1)
+	go walk("")
+	wg.Wait()
+
+	idx.statusMu.Lock()
+	defer idx.statusMu.Unlock()
+	if firstErr != nil {
+		st.State = CrawlError
+		st.Error = firstErr.Error()
+		st.UpdatedAt = time.Now()
+		return
+	}
+
+	if err := idx.pruneStale(alias, seen); err != nil {
+		st.State = CrawlError
+		st.Error = fmt.Sprintf("crawl finished but pruning stale entries failed: %v", err)
+		st.UpdatedAt = time.Now()
+		return
+	}
+
+	st.State = CrawlDone
+	st.FilesIndexed = count
+	st.UpdatedAt = time.Now()
+}
+
+// reportProgress updates st's FilesIndexed count as a crawl runs, rather
+// than only once it finishes, so a polled /app/index/status/{alias}
+// reflects live progress the way dataset's runJob reports
+// BytesDownloaded.
+func (idx *Index) reportProgress(st *CrawlStatus, count int) {
+	idx.statusMu.Lock()
+	defer idx.statusMu.Unlock()
+	st.FilesIndexed = count
+	st.UpdatedAt = time.Now()
+}
+
+// upsertIfChanged records rel's metadata under alias if it's new or its
+// size/mtime differ from what's indexed, returning whether a write
+// happened. mime is derived from rel's extension, same as handleRaw's
+// content-type guess.
+func (idx *Index) upsertIfChanged(alias, rel, name string, size int64, mtime time.Time) (bool, error) {
+	var existingSize int64
+	var existingMtime int64
+	err := idx.db.QueryRow(`SELECT size, mtime FROM file_entries WHERE alias = ? AND path = ?`, alias, rel).Scan(&existingSize, &existingMtime)
+	if err == nil && existingSize == size && existingMtime == mtime.Unix() {
+		return false, nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(name))
+
+	_, err = idx.db.Exec(`INSERT INTO file_entries (alias, path, name, size, mtime, mime)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(alias, path) DO UPDATE SET
+			name = excluded.name, size = excluded.size, mtime = excluded.mtime, mime = excluded.mime`,
+		alias, rel, name, size, mtime.Unix(), mimeType)
+	if err != nil {
+		return false, err
+	}
+	// An UPDATE branch of the upsert doesn't report the existing rowid
+	// through LastInsertId (it keeps whatever the connection last
+	// inserted), so look it up explicitly before resyncing the FTS row.
+	var id int64
+	if err := idx.db.QueryRow(`SELECT id FROM file_entries WHERE alias = ? AND path = ?`, alias, rel).Scan(&id); err != nil {
+		return false, err
+	}
+
+	if _, err := idx.db.Exec(`DELETE FROM file_entries_fts WHERE rowid = ?`, id); err != nil {
+		return false, err
+	}
+	if _, err := idx.db.Exec(`INSERT INTO file_entries_fts (rowid, name, path) VALUES (?, ?, ?)`, id, name, rel); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// pruneStale deletes alias's indexed rows whose path wasn't visited by the
+// crawl that produced seen - i.e. files deleted or moved on the backend
+// since the last crawl.
+func (idx *Index) pruneStale(alias string, seen map[string]bool) error {
+	rows, err := idx.db.Query(`SELECT id, path FROM file_entries WHERE alias = ?`, alias)
+	if err != nil {
+		return err
+	}
+	var stale []int64
+	for rows.Next() {
+		var id int64
+		var p string
+		if err := rows.Scan(&id, &p); err != nil {
+			rows.Close()
+			return err
+		}
+		if !seen[p] {
+			stale = append(stale, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, id := range stale {
+		if _, err := idx.db.Exec(`DELETE FROM file_entries WHERE id = ?`, id); err != nil {
+			return err
+		}
+		if _, err := idx.db.Exec(`DELETE FROM file_entries_fts WHERE rowid = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Search returns up to limit of alias's indexed files matching q, newest
+// first by mtime.
+func (idx *Index) Search(alias string, q Query, limit int) ([]Row, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+
+	var where []string
+	var args []interface{}
+	where = append(where, "alias = ?")
+	args = append(args, alias)
+
+	var rowsQuery string
+	if q.Q != "" {
+		rowsQuery = `SELECT fe.alias, fe.path, fe.name, fe.size, fe.mtime, fe.mime
+			FROM file_entries_fts fts JOIN file_entries fe ON fe.id = fts.rowid
+			WHERE fts MATCH ? AND `
+		args = append([]interface{}{ftsQuery(q.Q)}, args...)
+	} else {
+		rowsQuery = `SELECT alias, path, name, size, mtime, mime FROM file_entries fe WHERE `
+	}
+
+	if q.Glob != "" {
+		where = append(where, "fe.name GLOB ?")
+		args = append(args, q.Glob)
+	}
+	if q.Ext != "" {
+		where = append(where, "lower(fe.name) LIKE ?")
+		args = append(args, "%"+strings.ToLower(normalizeExt(q.Ext)))
+	}
+	if q.MinSize > 0 {
+		where = append(where, "fe.size >= ?")
+		args = append(args, q.MinSize)
+	}
+	if !q.ModifiedSince.IsZero() {
+		where = append(where, "fe.mtime >= ?")
+		args = append(args, q.ModifiedSince.Unix())
+	}
+
+	sqlStr := rowsQuery + strings.Join(where, " AND ") + " ORDER BY fe.mtime DESC LIMIT ?"
+	args = append(args, limit)
+
+	rs, err := idx.db.Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	var out []Row
+	for rs.Next() {
+		var r Row
+		var mtimeUnix int64
+		if err := rs.Scan(&r.Alias, &r.Path, &r.Name, &r.Size, &mtimeUnix, &r.Mime); err != nil {
+			return nil, err
+		}
+		r.Mtime = time.Unix(mtimeUnix, 0)
+		out = append(out, r)
+	}
+	return out, rs.Err()
+}
+
+// ftsQuery turns a free-text q into an FTS5 MATCH expression that matches
+// either column, quoting it so punctuation in a filename (dots,
+// underscores) isn't parsed as FTS5 query syntax.
+func ftsQuery(q string) string {
+	return fmt.Sprintf(`%s OR path:%s`, strconv.Quote(q), strconv.Quote(q))
+}
+
+// normalizeExt lowercases ext and ensures it has a leading dot, so callers
+// can pass either "csv" or ".csv".
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Type: Unit Test
+//
+// Crawling itself is an integration concern (it drives
+// dataset_source.ListEntries against a real backend, the same tension
+// dataset's manager_test.go notes for GetSQLiteDB), so this exercises the
+// indexing and search logic directly against upsertIfChanged/Search/
+// pruneStale instead of a full TriggerCrawl.
+func TestIndex_UpsertSearchPrune(t *testing.T) {
+	idx, err := NewIndex(filepath.Join(t.TempDir(), "index.db"), 2)
+	if err != nil {
+		t.Fatalf("NewIndex failed: %v", err)
+	}
+	defer idx.Close()
+
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	rows := []struct {
+		path  string
+		name  string
+		size  int64
+		mtime time.Time
+	}{
+		{"reports/2024.csv", "2024.csv", 1000, old},
+		{"reports/notes.txt", "notes.txt", 50, now},
+		{"images/logo.png", "logo.png", 2048, now},
+	}
+	for _, r := range rows {
+		changed, err := idx.upsertIfChanged("my-alias", r.path, r.name, r.size, r.mtime)
+		if err != nil {
+			t.Fatalf("upsertIfChanged(%q) failed: %v", r.path, err)
+		}
+		if !changed {
+			t.Errorf("upsertIfChanged(%q) on a new row should report changed", r.path)
+		}
+	}
+
+	// Re-inserting the same metadata should be a no-op.
+	if changed, err := idx.upsertIfChanged("my-alias", "images/logo.png", "logo.png", 2048, now); err != nil {
+		t.Fatalf("re-upsert failed: %v", err)
+	} else if changed {
+		t.Errorf("re-upsert with unchanged metadata should report unchanged")
+	}
+
+	t.Run("substring query", func(t *testing.T) {
+		got, err := idx.Search("my-alias", Query{Q: "2024"}, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Path != "reports/2024.csv" {
+			t.Errorf("expected reports/2024.csv, got %+v", got)
+		}
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		got, err := idx.Search("my-alias", Query{Glob: "*.png"}, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Name != "logo.png" {
+			t.Errorf("expected logo.png, got %+v", got)
+		}
+	})
+
+	t.Run("extension filter", func(t *testing.T) {
+		got, err := idx.Search("my-alias", Query{Ext: "csv"}, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Name != "2024.csv" {
+			t.Errorf("expected 2024.csv, got %+v", got)
+		}
+	})
+
+	t.Run("min size", func(t *testing.T) {
+		got, err := idx.Search("my-alias", Query{MinSize: 1500}, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(got) != 1 || got[0].Name != "logo.png" {
+			t.Errorf("expected only logo.png, got %+v", got)
+		}
+	})
+
+	t.Run("modified since", func(t *testing.T) {
+		got, err := idx.Search("my-alias", Query{ModifiedSince: now.Add(-1 * time.Hour)}, 10)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(got) != 2 {
+			t.Errorf("expected 2 rows modified after cutoff, got %d (%+v)", len(got), got)
+		}
+	})
+
+	// pruneStale should remove anything not in "seen" (simulating a file
+	// that's been deleted on the backend since the last crawl) but leave
+	// everything else alone.
+	if err := idx.pruneStale("my-alias", map[string]bool{"reports/2024.csv": true, "images/logo.png": true}); err != nil {
+		t.Fatalf("pruneStale failed: %v", err)
+	}
+	got, err := idx.Search("my-alias", Query{}, 10)
+	if err != nil {
+		t.Fatalf("Search after prune failed: %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected 2 rows to survive pruning, got %d (%+v)", len(got), got)
+	}
+	for _, r := range got {
+		if r.Path == "reports/notes.txt" {
+			t.Errorf("expected reports/notes.txt to be pruned, still present: %+v", got)
+		}
+	}
+}
+
+func TestIndex_StatusDefaultsIdle(t *testing.T) {
+	idx, err := NewIndex(filepath.Join(t.TempDir(), "index.db"), 2)
+	if err != nil {
+		t.Fatalf("NewIndex failed: %v", err)
+	}
+	defer idx.Close()
+
+	st := idx.Status("never-crawled")
+	if st.State != CrawlIdle {
+		t.Errorf("expected idle status for an alias never crawled, got %+v", st)
+	}
+}
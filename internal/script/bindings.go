@@ -0,0 +1,317 @@
+package script
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"flight2/internal/source"
+)
+
+// buildRequestTable turns req into the Lua table a script's handle(ctx)
+// receives: path, query, alias, remote_addr, matching the fields the
+// request body describes.
+func buildRequestTable(L *lua.LState, req Request) *lua.LTable {
+	t := L.NewTable()
+	L.SetField(t, "path", lua.LString(req.Path))
+	L.SetField(t, "alias", lua.LString(req.Alias))
+	L.SetField(t, "remote_addr", lua.LString(req.RemoteAddr))
+
+	query := L.NewTable()
+	for k, vs := range req.Query {
+		if len(vs) == 0 {
+			continue
+		}
+		L.SetField(query, k, lua.LString(vs[0]))
+	}
+	L.SetField(t, "query", query)
+
+	return t
+}
+
+// registerSecrets installs the "secrets" global, exposing secrets:get(alias)
+// as the only way a script can reach the credential store.
+func registerSecrets(L *lua.LState, deps Deps) {
+	t := L.NewTable()
+	L.SetField(t, "get", L.NewFunction(func(L *lua.LState) int {
+		// Argument 1 is the secrets table itself (secrets:get colon call).
+		alias := L.CheckString(2)
+		creds, err := deps.Secrets.GetCredentials(alias)
+		if err != nil {
+			L.RaiseError("secrets:get(%q): %v", alias, err)
+			return 0
+		}
+		L.Push(mapToLua(L, creds))
+		return 1
+	}))
+	L.SetGlobal("secrets", t)
+}
+
+// registerDataset installs the "dataset" global, exposing dataset:open as
+// the only way a script can turn a source path into a queryable handle.
+func registerDataset(L *lua.LState, deps Deps, defaultAlias string) {
+	t := L.NewTable()
+	L.SetField(t, "open", L.NewFunction(func(L *lua.LState) int {
+		// Argument 1 is the dataset table itself (dataset:open colon call).
+		src := L.CheckString(2)
+		alias := defaultAlias
+		if L.GetTop() >= 3 {
+			alias = L.CheckString(3)
+		}
+
+		ref, err := resolveRef(deps, alias, src)
+		if err != nil {
+			L.RaiseError("dataset:open(%q): %v", src, err)
+			return 0
+		}
+
+		dbPath, err := deps.DataManager.GetSQLiteDB(L.Context(), ref, alias)
+		if err != nil {
+			L.RaiseError("dataset:open(%q): %v", src, err)
+			return 0
+		}
+
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			L.RaiseError("dataset:open(%q): %v", src, err)
+			return 0
+		}
+
+		L.Push(newHandleTable(L, db))
+		return 1
+	}))
+	L.SetGlobal("dataset", t)
+}
+
+// resolveRef mirrors Server.resolveJobRef: look up alias's stored
+// credentials (or fall back to a local reference when alias is empty) and
+// register a source profile for it.
+func resolveRef(deps Deps, alias, sourcePath string) (source.SourceRef, error) {
+	var creds map[string]interface{}
+	if alias != "" {
+		c, err := deps.Secrets.GetCredentials(alias)
+		if err != nil {
+			return source.SourceRef{}, fmt.Errorf("error retrieving credentials for alias %s: %w", alias, err)
+		}
+		creds = c
+	} else {
+		creds = map[string]interface{}{"type": "local"}
+	}
+
+	profileID := alias
+	if profileID == "" {
+		profileID = "__local__"
+	}
+	source.RegisterProfile(profileID, creds)
+	backend, _ := creds["type"].(string)
+	return source.SourceRef{Backend: backend, Path: sourcePath, ProfileID: profileID}, nil
+}
+
+// newHandleTable wraps db in a Lua table exposing query(sql, ...params) and
+// close(), the "run parameterized SQL and iterate rows" half of
+// dataset:open's contract.
+func newHandleTable(L *lua.LState, db *sql.DB) *lua.LTable {
+	t := L.NewTable()
+	L.SetField(t, "query", L.NewFunction(func(L *lua.LState) int {
+		query := L.CheckString(1)
+		args := make([]interface{}, 0, L.GetTop()-1)
+		for i := 2; i <= L.GetTop(); i++ {
+			args = append(args, luaToGo(L.Get(i)))
+		}
+
+		rows, err := db.QueryContext(L.Context(), query, args...)
+		if err != nil {
+			L.RaiseError("query failed: %v", err)
+			return 0
+		}
+		defer rows.Close()
+
+		cols, err := rows.Columns()
+		if err != nil {
+			L.RaiseError("query failed: %v", err)
+			return 0
+		}
+
+		result := L.NewTable()
+		idx := 1
+		for rows.Next() {
+			vals := make([]interface{}, len(cols))
+			ptrs := make([]interface{}, len(cols))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				L.RaiseError("row scan failed: %v", err)
+				return 0
+			}
+			row := L.NewTable()
+			for i, col := range cols {
+				L.SetField(row, col, goToLua(L, vals[i]))
+			}
+			L.RawSetInt(result, idx, row)
+			idx++
+		}
+		if err := rows.Err(); err != nil {
+			L.RaiseError("query failed: %v", err)
+			return 0
+		}
+
+		L.Push(result)
+		return 1
+	}))
+	L.SetField(t, "close", L.NewFunction(func(L *lua.LState) int {
+		db.Close()
+		return 0
+	}))
+	return t
+}
+
+// registerResp installs the "resp" global. Scripts build their response
+// through it rather than returning a value, since they may want to stream
+// several writes (e.g. a CSV header row, then data rows) before finishing.
+// Each function is called in colon form (resp:write(...)), so argument 1 is
+// the resp table itself and the caller's first argument is at index 2.
+func registerResp(L *lua.LState, resp *Response) {
+	t := L.NewTable()
+	L.SetField(t, "status", L.NewFunction(func(L *lua.LState) int {
+		resp.StatusCode = L.CheckInt(2)
+		return 0
+	}))
+	L.SetField(t, "html", L.NewFunction(func(L *lua.LState) int {
+		resp.ContentType = "text/html; charset=utf-8"
+		resp.Body = append(resp.Body, L.CheckString(2)...)
+		return 0
+	}))
+	L.SetField(t, "write", L.NewFunction(func(L *lua.LState) int {
+		resp.Body = append(resp.Body, L.CheckString(2)...)
+		return 0
+	}))
+	L.SetField(t, "json", L.NewFunction(func(L *lua.LState) int {
+		value := luaToGo(L.CheckAny(2))
+		data, err := json.Marshal(value)
+		if err != nil {
+			L.RaiseError("resp:json: %v", err)
+			return 0
+		}
+		resp.ContentType = "application/json"
+		resp.Body = append(resp.Body, data...)
+		return 0
+	}))
+	L.SetField(t, "csv", L.NewFunction(func(L *lua.LState) int {
+		rows, ok := L.CheckAny(2).(*lua.LTable)
+		if !ok {
+			L.RaiseError("resp:csv: expected a table of rows")
+			return 0
+		}
+
+		var sb strings.Builder
+		w := csv.NewWriter(&sb)
+		rows.ForEach(func(_, rowVal lua.LValue) {
+			row, ok := rowVal.(*lua.LTable)
+			if !ok {
+				return
+			}
+			var record []string
+			row.ForEach(func(_, cell lua.LValue) {
+				record = append(record, lua.LVAsString(cell))
+			})
+			w.Write(record)
+		})
+		w.Flush()
+
+		resp.ContentType = "text/csv"
+		resp.Body = append(resp.Body, sb.String()...)
+		return 0
+	}))
+	L.SetGlobal("resp", t)
+}
+
+// mapToLua converts a credential/JSON-shaped Go map into a Lua table.
+func mapToLua(L *lua.LState, m map[string]interface{}) *lua.LTable {
+	t := L.NewTable()
+	for k, v := range m {
+		L.SetField(t, k, goToLua(L, v))
+	}
+	return t
+}
+
+// goToLua converts a Go value produced by JSON decoding or a database scan
+// into its Lua equivalent.
+func goToLua(L *lua.LState, v interface{}) lua.LValue {
+	switch val := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(val)
+	case string:
+		return lua.LString(val)
+	case []byte:
+		return lua.LString(val)
+	case int:
+		return lua.LNumber(val)
+	case int64:
+		return lua.LNumber(val)
+	case float64:
+		return lua.LNumber(val)
+	case map[string]interface{}:
+		return mapToLua(L, val)
+	case []interface{}:
+		t := L.NewTable()
+		for i, item := range val {
+			L.RawSetInt(t, i+1, goToLua(L, item))
+		}
+		return t
+	default:
+		return lua.LString(fmt.Sprintf("%v", val))
+	}
+}
+
+// luaToGo converts a Lua value back into a plain Go value, for SQL query
+// arguments and resp:json payloads.
+func luaToGo(v lua.LValue) interface{} {
+	switch val := v.(type) {
+	case lua.LBool:
+		return bool(val)
+	case lua.LNumber:
+		return float64(val)
+	case lua.LString:
+		return string(val)
+	case *lua.LTable:
+		return luaTableToGo(val)
+	default:
+		return nil
+	}
+}
+
+// luaTableToGo converts t to a []interface{} if it looks like a Lua array
+// (consecutive integer keys from 1), or a map[string]interface{} otherwise.
+func luaTableToGo(t *lua.LTable) interface{} {
+	length := t.Len()
+	if length > 0 {
+		arr := make([]interface{}, 0, length)
+		isArray := true
+		t.ForEach(func(k, val lua.LValue) {
+			if _, ok := k.(lua.LNumber); !ok {
+				isArray = false
+			}
+		})
+		if isArray {
+			for i := 1; i <= length; i++ {
+				arr = append(arr, luaToGo(t.RawGetInt(i)))
+			}
+			return arr
+		}
+	}
+
+	m := make(map[string]interface{})
+	t.ForEach(func(k, val lua.LValue) {
+		m[lua.LVAsString(k)] = luaToGo(val)
+	})
+	return m
+}
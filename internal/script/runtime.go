@@ -0,0 +1,50 @@
+package script
+
+import (
+	"context"
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// runScript compiles and runs cs's source in a fresh sandboxed VM, calling
+// its handle(ctx) function with a table built from req and returning
+// whatever resp accumulated. The VM enforces ctx's deadline via
+// SetContext, interrupting a script stuck in a tight loop instead of
+// hanging the request - but only because Registry.Handle always passes a
+// ctx already bounded by its own timeout, not the caller's raw
+// request context (which may carry no deadline at all).
+func runScript(ctx context.Context, cs *compiledScript, deps Deps, req Request) (Response, error) {
+	L := newSandboxedState()
+	defer L.Close()
+	L.SetContext(ctx)
+
+	var resp Response
+	registerSecrets(L, deps)
+	registerDataset(L, deps, req.Alias)
+	registerResp(L, &resp)
+
+	if err := L.DoString(cs.source); err != nil {
+		return Response{}, fmt.Errorf("script %q failed to load: %w", cs.name, err)
+	}
+
+	handle := L.GetGlobal("handle")
+	fn, ok := handle.(*lua.LFunction)
+	if !ok {
+		return Response{}, fmt.Errorf("script %q does not define a handle(ctx) function", cs.name)
+	}
+
+	reqTable := buildRequestTable(L, req)
+	if err := L.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    0,
+		Protect: true,
+	}, reqTable); err != nil {
+		return Response{}, fmt.Errorf("script %q failed: %w", cs.name, err)
+	}
+
+	if resp.StatusCode == 0 {
+		resp.StatusCode = 200
+	}
+	return resp, nil
+}
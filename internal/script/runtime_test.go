@@ -0,0 +1,64 @@
+package script
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestRegistry(t *testing.T, source string, opts ...Option) *Registry {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "test.lua"), []byte(source), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	r, err := NewRegistry(dir, Deps{}, false, opts...)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+// TestRegistryHandle_TimesOutRunawayScript guards the DoS surface a
+// while-true script would otherwise open: Handle must interrupt it within
+// its configured timeout instead of hanging the calling goroutine for as
+// long as the client stays connected.
+func TestRegistryHandle_TimesOutRunawayScript(t *testing.T) {
+	r := newTestRegistry(t, `
+function handle(req)
+  while true do end
+end
+`, WithTimeout(50*time.Millisecond))
+
+	start := time.Now()
+	_, err := r.Handle(context.Background(), "test", Request{})
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected a runaway script to return an error, got nil")
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("Handle took %s to interrupt a runaway script, want well under 2s", elapsed)
+	}
+}
+
+func TestRegistryHandle_AllowsScriptWithinTimeout(t *testing.T) {
+	r := newTestRegistry(t, `
+function handle(req)
+  resp:write("ok")
+end
+`, WithTimeout(time.Second))
+
+	resp, err := r.Handle(context.Background(), "test", Request{})
+	if err != nil {
+		t.Fatalf("Handle failed: %v", err)
+	}
+	if string(resp.Body) != "ok" {
+		t.Fatalf("resp.Body = %q, want %q", resp.Body, "ok")
+	}
+}
@@ -0,0 +1,38 @@
+package script
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+// sandboxLibs is every stdlib piece a script is allowed: base (for
+// pcall/error/print), table, string, and math. Notably absent: package
+// (no require, no loading other scripts or native modules), io and os (no
+// filesystem or environment access), and debug (no introspecting or
+// escaping the sandbox through it).
+var sandboxLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+}
+
+// newSandboxedState returns a fresh Lua VM with only sandboxLibs loaded, so
+// a script has no way to touch the filesystem, spawn processes, read the
+// environment, or pull in code from outside what Registry hands it.
+func newSandboxedState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range sandboxLibs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// The base library also defines dofile and loadfile, which read Lua
+	// source from disk, and require; remove them from the sandbox.
+	for _, name := range []string{"dofile", "loadfile", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
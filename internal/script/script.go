@@ -0,0 +1,225 @@
+// Package script loads Lua scripts from a directory and runs them as HTTP
+// handlers, the way internal/config's Watcher turns a file on disk into
+// live behavior without a restart. Scripts get a small, deliberately
+// limited API - credential lookup, dataset ingestion, parameterized SQL,
+// and a response writer - sandboxed behind a gopher-lua VM with the
+// os/io/package loaders disabled.
+package script
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"flight2/internal/dataset"
+	"flight2/internal/secrets"
+)
+
+// defaultScriptTimeout bounds how long a single script invocation may run
+// before its Lua context is cancelled, so a runaway or malicious script
+// can't hang a request goroutine forever. r.Context() alone doesn't
+// provide this - an HTTP request's context only ever cancels early
+// (client disconnect), never on a deadline - so Registry.Handle always
+// derives its own bounded context via context.WithTimeout before calling
+// runScript, regardless of what the caller's ctx looks like.
+const defaultScriptTimeout = 10 * time.Second
+
+// Option customizes a Registry's behavior, passed to NewRegistry.
+type Option func(*Registry)
+
+// WithTimeout overrides how long a single script invocation may run
+// before being interrupted, in place of defaultScriptTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Registry) { r.timeout = d }
+}
+
+// Deps are the Flight2 subsystems a script's bindings are allowed to call
+// into: secrets:get and dataset:open, specifically, and nothing else.
+type Deps struct {
+	Secrets     *secrets.Service
+	DataManager *dataset.Manager
+}
+
+// Request is the subset of an incoming HTTP request handed to a script's
+// handle(ctx) function.
+type Request struct {
+	Path       string
+	Query      map[string][]string
+	Alias      string
+	RemoteAddr string
+}
+
+// Response is what a script's resp table accumulates; handleScript writes
+// it back to the real http.ResponseWriter once the script returns.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// compiledScript is one loaded *.lua file, keyed by its name (the filename
+// without extension) under /app/script/{name}.
+type compiledScript struct {
+	name   string
+	path   string
+	source string
+}
+
+// Registry scans a directory for *.lua scripts, compiles them on demand per
+// request, and reloads a script's source when fsnotify reports its file
+// changed - hot reload without restarting the process.
+type Registry struct {
+	dir     string
+	deps    Deps
+	verbose bool
+	timeout time.Duration
+
+	mu      sync.RWMutex
+	scripts map[string]*compiledScript
+
+	fsw *fsnotify.Watcher
+}
+
+// NewRegistry scans dir for *.lua files, loads their source, and starts
+// watching dir for changes. A dir that doesn't exist makes NewRegistry
+// return an error, since it can't be watched; callers check os.Stat
+// themselves before deciding whether scripting is enabled at all
+// (mirroring how NewServer treats a missing ServeFolder).
+func NewRegistry(dir string, deps Deps, verbose bool, opts ...Option) (*Registry, error) {
+	r := &Registry{
+		dir:     dir,
+		deps:    deps,
+		verbose: verbose,
+		timeout: defaultScriptTimeout,
+		scripts: make(map[string]*compiledScript),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := r.loadAll(); err != nil {
+		return nil, err
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create script watcher: %w", err)
+	}
+	if err := fsw.Add(dir); err != nil {
+		fsw.Close()
+		return nil, fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	r.fsw = fsw
+	go r.watch()
+
+	return r, nil
+}
+
+func (r *Registry) loadAll() error {
+	matches, err := filepath.Glob(filepath.Join(r.dir, "*.lua"))
+	if err != nil {
+		return fmt.Errorf("failed to scan script directory %s: %w", r.dir, err)
+	}
+
+	scripts := make(map[string]*compiledScript, len(matches))
+	for _, path := range matches {
+		cs, err := loadScript(path)
+		if err != nil {
+			log.Printf("script: skipping %s: %v", path, err)
+			continue
+		}
+		scripts[cs.name] = cs
+	}
+
+	r.mu.Lock()
+	r.scripts = scripts
+	r.mu.Unlock()
+	return nil
+}
+
+func loadScript(path string) (*compiledScript, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(filepath.Base(path), ".lua")
+	return &compiledScript{name: name, path: path, source: string(data)}, nil
+}
+
+// watch blocks processing fsnotify events until the watcher is closed,
+// reloading the script a changed file corresponds to. Run it from its own
+// goroutine; NewRegistry already does.
+func (r *Registry) watch() {
+	for {
+		select {
+		case event, ok := <-r.fsw.Events:
+			if !ok {
+				return
+			}
+			if !strings.HasSuffix(event.Name, ".lua") {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				r.reload(event.Name)
+			} else if event.Op&fsnotify.Remove != 0 {
+				r.forget(event.Name)
+			}
+		case err, ok := <-r.fsw.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("script watcher error: %v", err)
+		}
+	}
+}
+
+func (r *Registry) reload(path string) {
+	cs, err := loadScript(path)
+	if err != nil {
+		log.Printf("script: failed to reload %s, keeping previous version: %v", path, err)
+		return
+	}
+	r.mu.Lock()
+	r.scripts[cs.name] = cs
+	r.mu.Unlock()
+	if r.verbose {
+		log.Printf("script: reloaded %s", cs.name)
+	}
+}
+
+func (r *Registry) forget(path string) {
+	name := strings.TrimSuffix(filepath.Base(path), ".lua")
+	r.mu.Lock()
+	delete(r.scripts, name)
+	r.mu.Unlock()
+}
+
+// Close stops watching the script directory.
+func (r *Registry) Close() error {
+	return r.fsw.Close()
+}
+
+// Handle runs the named script's handle(ctx) function and returns the
+// Response its resp table accumulated. ctx bounds the overall request; the
+// script itself gets at most r.timeout of that before its Lua state is
+// interrupted.
+func (r *Registry) Handle(ctx context.Context, name string, req Request) (Response, error) {
+	r.mu.RLock()
+	cs, ok := r.scripts[name]
+	r.mu.RUnlock()
+	if !ok {
+		return Response{}, fmt.Errorf("no script named %q", name)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return runScript(runCtx, cs, r.deps, req)
+}
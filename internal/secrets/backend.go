@@ -0,0 +1,29 @@
+package secrets
+
+import "context"
+
+// Backend is the storage abstraction behind Service. It deals only in opaque
+// encrypted blobs keyed by alias; encryption, JSON encoding, and alias
+// generation all live in Service so every Backend implementation stays
+// trivial.
+type Backend interface {
+	// Get returns the raw stored blob for alias, or an error if it does not
+	// exist.
+	Get(ctx context.Context, alias string) ([]byte, error)
+	// Put stores (or overwrites) the raw blob for alias.
+	Put(ctx context.Context, alias string, data []byte) error
+	// List returns every known alias.
+	List(ctx context.Context) ([]string, error)
+	// Delete removes alias. Implementations should return nil if the alias
+	// does not exist.
+	Delete(ctx context.Context, alias string) error
+	// Close releases any resources held by the backend.
+	Close() error
+}
+
+// ErrNotFound is returned by a Backend when an alias has no stored value.
+var ErrNotFound = backendError("secrets: alias not found")
+
+type backendError string
+
+func (e backendError) Error() string { return string(e) }
@@ -0,0 +1,71 @@
+package secrets
+
+import "context"
+
+// chainBackend tries each underlying backend in order on Get/List, falling
+// through to the next on ErrNotFound. Writes (Put/Delete) always target the
+// first backend, which is assumed to be the primary, writable store.
+type chainBackend struct {
+	backends []Backend
+}
+
+func newChainBackend(backends ...Backend) *chainBackend {
+	return &chainBackend{backends: backends}
+}
+
+func (c *chainBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	var lastErr error
+	for _, b := range c.backends {
+		data, err := b.Get(ctx, alias)
+		if err == nil {
+			return data, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = ErrNotFound
+	}
+	return nil, lastErr
+}
+
+func (c *chainBackend) Put(ctx context.Context, alias string, data []byte) error {
+	if len(c.backends) == 0 {
+		return ErrNotFound
+	}
+	return c.backends[0].Put(ctx, alias, data)
+}
+
+func (c *chainBackend) List(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var aliases []string
+	for _, b := range c.backends {
+		found, err := b.List(ctx)
+		if err != nil {
+			continue
+		}
+		for _, a := range found {
+			if !seen[a] {
+				seen[a] = true
+				aliases = append(aliases, a)
+			}
+		}
+	}
+	return aliases, nil
+}
+
+func (c *chainBackend) Delete(ctx context.Context, alias string) error {
+	if len(c.backends) == 0 {
+		return nil
+	}
+	return c.backends[0].Delete(ctx, alias)
+}
+
+func (c *chainBackend) Close() error {
+	var firstErr error
+	for _, b := range c.backends {
+		if err := b.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
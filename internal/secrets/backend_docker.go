@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerConfig mirrors the relevant bits of ~/.docker/config.json.
+type dockerConfig struct {
+	Auths       map[string]json.RawMessage `json:"auths"`
+	CredsStore  string                     `json:"credsStore"`
+	CredHelpers map[string]string          `json:"credHelpers"`
+}
+
+// dockerCredential is what `docker-credential-<helper> get` prints on stdout.
+type dockerCredential struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// dockerBackend resolves aliases of the form "docker:<registry>" against
+// Docker's config.json and native credential helpers
+// (docker-credential-osxkeychain, -secretservice, -wincred, -pass, ...), so
+// credentials already managed by `docker login` can be reused without
+// copying them into secrets.db.
+type dockerBackend struct {
+	configPath string
+	cfg        dockerConfig
+}
+
+const dockerAliasPrefix = "docker:"
+
+// newDockerBackend loads configPath (defaulting to ~/.docker/config.json
+// when empty).
+func newDockerBackend(configPath string) (*dockerBackend, error) {
+	if configPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("docker backend: could not determine home dir: %w", err)
+		}
+		configPath = filepath.Join(home, ".docker", "config.json")
+	}
+
+	b := &dockerBackend{configPath: configPath}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return b, nil
+		}
+		return nil, fmt.Errorf("docker backend: failed to read %s: %w", configPath, err)
+	}
+	if err := json.Unmarshal(data, &b.cfg); err != nil {
+		return nil, fmt.Errorf("docker backend: invalid %s: %w", configPath, err)
+	}
+	return b, nil
+}
+
+// registry strips the "docker:" alias prefix, e.g. "docker:ghcr.io" -> "ghcr.io".
+func registry(alias string) (string, bool) {
+	if !strings.HasPrefix(alias, dockerAliasPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(alias, dockerAliasPrefix), true
+}
+
+// helperFor returns the docker-credential-<name> binary that owns reg, or
+// "" if none is configured (including the global credsStore fallback).
+func (b *dockerBackend) helperFor(reg string) string {
+	if h, ok := b.cfg.CredHelpers[reg]; ok {
+		return h
+	}
+	return b.cfg.CredsStore
+}
+
+// runHelper shells out to docker-credential-<helper> per the credential
+// helper protocol: the subcommand's name goes on argv, the server URL is
+// written to stdin, and JSON comes back on stdout.
+func runHelper(ctx context.Context, helper, subcommand, serverURL string) (*dockerCredential, error) {
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, subcommand)
+	cmd.Stdin = strings.NewReader(serverURL)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("docker-credential-%s %s: %w: %s", helper, subcommand, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if subcommand == "erase" {
+		return nil, nil
+	}
+
+	var cred dockerCredential
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return nil, fmt.Errorf("docker-credential-%s %s: malformed response: %w", helper, subcommand, err)
+	}
+	return &cred, nil
+}
+
+func (b *dockerBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	reg, ok := registry(alias)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var username, secret string
+
+	if helper := b.helperFor(reg); helper != "" {
+		cred, err := runHelper(ctx, helper, "get", reg)
+		if err != nil {
+			return nil, err
+		}
+		username, secret = cred.Username, cred.Secret
+	} else if raw, ok := b.cfg.Auths[reg]; ok {
+		var entry struct {
+			Auth string `json:"auth"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, fmt.Errorf("docker backend: malformed auths entry for %s: %w", reg, err)
+		}
+		// entry.Auth is base64("user:pass"), the same encoding `docker
+		// login` writes - decode it so callers get a real
+		// username/password instead of the opaque auth string.
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("docker backend: malformed auth for %s: %w", reg, err)
+		}
+		user, pass, ok := strings.Cut(string(decoded), ":")
+		if !ok {
+			return nil, fmt.Errorf("docker backend: malformed auth for %s: expected \"user:pass\"", reg)
+		}
+		username, secret = user, pass
+	} else {
+		return nil, ErrNotFound
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":     "docker",
+		"registry": reg,
+		"username": username,
+		"password": secret,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *dockerBackend) Put(ctx context.Context, alias string, data []byte) error {
+	reg, ok := registry(alias)
+	if !ok {
+		return fmt.Errorf("docker backend: alias %q is not a docker: alias", alias)
+	}
+	helper := b.helperFor(reg)
+	if helper == "" {
+		return fmt.Errorf("docker backend: no credential helper configured for %s", reg)
+	}
+
+	var creds map[string]interface{}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return fmt.Errorf("docker backend: invalid credential payload: %w", err)
+	}
+
+	payload, err := json.Marshal(dockerCredential{
+		ServerURL: reg,
+		Username:  fmt.Sprint(creds["username"]),
+		Secret:    fmt.Sprint(creds["password"]),
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "store")
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("docker-credential-%s store: %w: %s", helper, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (b *dockerBackend) List(ctx context.Context) ([]string, error) {
+	aliases := make([]string, 0, len(b.cfg.Auths))
+	for reg := range b.cfg.Auths {
+		aliases = append(aliases, dockerAliasPrefix+reg)
+	}
+	for reg := range b.cfg.CredHelpers {
+		aliases = append(aliases, dockerAliasPrefix+reg)
+	}
+	return aliases, nil
+}
+
+func (b *dockerBackend) Delete(ctx context.Context, alias string) error {
+	reg, ok := registry(alias)
+	if !ok {
+		return fmt.Errorf("docker backend: alias %q is not a docker: alias", alias)
+	}
+	helper := b.helperFor(reg)
+	if helper == "" {
+		return fmt.Errorf("docker backend: no credential helper configured for %s", reg)
+	}
+	_, err := runHelper(ctx, helper, "erase", reg)
+	return err
+}
+
+func (b *dockerBackend) Close() error { return nil }
@@ -0,0 +1,55 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envBackendPrefix is prepended to an upper-cased, hyphen-to-underscore
+// alias to form the environment variable name an envBackend reads from.
+const envBackendPrefix = "FLIGHT2_SECRET_"
+
+// envBackend is a read-only Backend for CI, where credentials are injected
+// as environment variables rather than persisted anywhere.
+type envBackend struct{}
+
+func newEnvBackend() *envBackend {
+	return &envBackend{}
+}
+
+func envVarName(alias string) string {
+	return envBackendPrefix + strings.ToUpper(strings.ReplaceAll(alias, "-", "_"))
+}
+
+func (b *envBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	v, ok := os.LookupEnv(envVarName(alias))
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return []byte(v), nil
+}
+
+func (b *envBackend) Put(ctx context.Context, alias string, data []byte) error {
+	return fmt.Errorf("env backend: read-only, cannot store %q", alias)
+}
+
+func (b *envBackend) List(ctx context.Context) ([]string, error) {
+	var aliases []string
+	for _, kv := range os.Environ() {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, envBackendPrefix) {
+			continue
+		}
+		alias := strings.ToLower(strings.TrimPrefix(name, envBackendPrefix))
+		aliases = append(aliases, strings.ReplaceAll(alias, "_", "-"))
+	}
+	return aliases, nil
+}
+
+func (b *envBackend) Delete(ctx context.Context, alias string) error {
+	return fmt.Errorf("env backend: read-only, cannot delete %q", alias)
+}
+
+func (b *envBackend) Close() error { return nil }
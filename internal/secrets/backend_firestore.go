@@ -0,0 +1,103 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// firestoreBlobField is the single field each secrets document carries, the
+// same opaque encrypted payload every other Backend stores.
+const firestoreBlobField = "data"
+
+// firestoreBackend stores credential blobs as Firestore documents, one per
+// alias, under a single collection - the other shared-vault option
+// alongside postgresBackend for multi-instance deployments that are
+// already on GCP.
+type firestoreBackend struct {
+	client     *firestore.Client
+	collection string
+}
+
+// newFirestoreBackend opens a Firestore client for projectID and points it
+// at collection (defaulting to "flight2-secrets").
+func newFirestoreBackend(ctx context.Context, projectID, collection string) (*firestoreBackend, error) {
+	if projectID == "" {
+		return nil, fmt.Errorf("firestore backend: project id is required")
+	}
+	if collection == "" {
+		collection = "flight2-secrets"
+	}
+
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("firestore backend: failed to create client: %w", err)
+	}
+
+	return &firestoreBackend{client: client, collection: collection}, nil
+}
+
+func (b *firestoreBackend) doc(alias string) *firestore.DocumentRef {
+	return b.client.Collection(b.collection).Doc(alias)
+}
+
+func (b *firestoreBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	snap, err := b.doc(alias).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("firestore backend: get %s: %w", alias, err)
+	}
+
+	data, err := snap.DataAt(firestoreBlobField)
+	if err != nil {
+		return nil, fmt.Errorf("firestore backend: malformed document for %s: %w", alias, err)
+	}
+	blob, ok := data.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("firestore backend: malformed document for %s", alias)
+	}
+	return blob, nil
+}
+
+func (b *firestoreBackend) Put(ctx context.Context, alias string, data []byte) error {
+	_, err := b.doc(alias).Set(ctx, map[string]interface{}{firestoreBlobField: data})
+	if err != nil {
+		return fmt.Errorf("firestore backend: put %s: %w", alias, err)
+	}
+	return nil
+}
+
+func (b *firestoreBackend) List(ctx context.Context) ([]string, error) {
+	var aliases []string
+	iter := b.client.Collection(b.collection).Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("firestore backend: list: %w", err)
+		}
+		aliases = append(aliases, doc.Ref.ID)
+	}
+	return aliases, nil
+}
+
+func (b *firestoreBackend) Delete(ctx context.Context, alias string) error {
+	_, err := b.doc(alias).Delete(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("firestore backend: delete %s: %w", alias, err)
+	}
+	return nil
+}
+
+func (b *firestoreBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,89 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresBackend stores the same opaque encrypted blobs as sqliteBackend,
+// but in a shared Postgres table, so several Flight2 instances can serve
+// against one credential vault instead of each needing its own local
+// secrets.db.
+type postgresBackend struct {
+	db *sql.DB
+}
+
+// newPostgresBackend opens dsn (a standard "postgres://..." connection
+// string) and bootstraps the secrets table if it doesn't already exist.
+func newPostgresBackend(dsn string) (*postgresBackend, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("postgres backend: dsn is required")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres backend: failed to open: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgres backend: failed to connect: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS secrets (
+		alias TEXT PRIMARY KEY,
+		data  BYTEA NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgres backend: failed to create secrets table: %w", err)
+	}
+
+	return &postgresBackend{db: db}, nil
+}
+
+func (b *postgresBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	var data []byte
+	err := b.db.QueryRowContext(ctx, `SELECT data FROM secrets WHERE alias = $1`, alias).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *postgresBackend) Put(ctx context.Context, alias string, data []byte) error {
+	_, err := b.db.ExecContext(ctx, `INSERT INTO secrets (alias, data) VALUES ($1, $2)
+		ON CONFLICT (alias) DO UPDATE SET data = EXCLUDED.data`, alias, data)
+	return err
+}
+
+func (b *postgresBackend) List(ctx context.Context) ([]string, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT alias FROM secrets ORDER BY alias`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+func (b *postgresBackend) Delete(ctx context.Context, alias string) error {
+	_, err := b.db.ExecContext(ctx, `DELETE FROM secrets WHERE alias = $1`, alias)
+	return err
+}
+
+func (b *postgresBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteBackend is the default Backend: an encrypted blob per alias stored
+// in a local SQLite database.
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+// newSQLiteBackend opens (creating if needed) the secrets database at
+// dbPath and ensures its schema exists.
+func newSQLiteBackend(dbPath string) (*sqliteBackend, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open secrets db: %w", err)
+	}
+
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS secrets (
+		alias TEXT PRIMARY KEY,
+		data  BLOB NOT NULL
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create secrets table: %w", err)
+	}
+
+	return &sqliteBackend{db: db}, nil
+}
+
+func (b *sqliteBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	var data []byte
+	err := b.db.QueryRowContext(ctx, `SELECT data FROM secrets WHERE alias = ?`, alias).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (b *sqliteBackend) Put(ctx context.Context, alias string, data []byte) error {
+	_, err := b.db.ExecContext(ctx, `INSERT INTO secrets (alias, data) VALUES (?, ?)
+		ON CONFLICT(alias) DO UPDATE SET data = excluded.data`, alias, data)
+	return err
+}
+
+func (b *sqliteBackend) List(ctx context.Context) ([]string, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT alias FROM secrets ORDER BY alias`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+func (b *sqliteBackend) Delete(ctx context.Context, alias string) error {
+	_, err := b.db.ExecContext(ctx, `DELETE FROM secrets WHERE alias = ?`, alias)
+	return err
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,158 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// VaultConfig configures the HashiCorp Vault KV v2 backend.
+type VaultConfig struct {
+	Address string // e.g. https://vault.internal:8200
+	Token   string // static token; AppRole login happens before this is set
+	Mount   string // KV v2 mount point, default "secret"
+	Prefix  string // path prefix under the mount, e.g. "flight2"
+}
+
+// vaultBackend resolves and stores credentials against a Vault KV v2 secrets
+// engine, so sensitive aliases like "production-s3-backups" are never
+// persisted to secrets.db.
+type vaultBackend struct {
+	cfg    VaultConfig
+	client *http.Client
+}
+
+func newVaultBackend(cfg VaultConfig) (*vaultBackend, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault backend: address is required")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("vault backend: token is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	return &vaultBackend{cfg: cfg, client: &http.Client{}}, nil
+}
+
+func (b *vaultBackend) path(alias string) string {
+	p := strings.Trim(b.cfg.Prefix, "/")
+	if p != "" {
+		p += "/"
+	}
+	return fmt.Sprintf("%s/v1/%s/data/%s%s", strings.TrimRight(b.cfg.Address, "/"), b.cfg.Mount, p, alias)
+}
+
+func (b *vaultBackend) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", b.cfg.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return b.client.Do(req)
+}
+
+func (b *vaultBackend) Get(ctx context.Context, alias string) ([]byte, error) {
+	resp, err := b.do(ctx, http.MethodGet, b.path(alias), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault backend: get %s: status %d", alias, resp.StatusCode)
+	}
+
+	var payload struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("vault backend: decode response: %w", err)
+	}
+	blob, ok := payload.Data.Data["blob"].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault backend: malformed secret for %s", alias)
+	}
+	return []byte(blob), nil
+}
+
+func (b *vaultBackend) Put(ctx context.Context, alias string, data []byte) error {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"data": map[string]interface{}{"blob": string(data)},
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, b.path(alias), bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("vault backend: put %s: status %d", alias, resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *vaultBackend) List(ctx context.Context) ([]string, error) {
+	p := strings.Trim(b.cfg.Prefix, "/")
+	url := fmt.Sprintf("%s/v1/%s/metadata/%s?list=true", strings.TrimRight(b.cfg.Address, "/"), b.cfg.Mount, p)
+
+	resp, err := b.do(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault backend: list: status %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("vault backend: decode list response: %w", err)
+	}
+	return payload.Data.Keys, nil
+}
+
+func (b *vaultBackend) Delete(ctx context.Context, alias string) error {
+	p := strings.Trim(b.cfg.Prefix, "/")
+	if p != "" {
+		p += "/"
+	}
+	url := fmt.Sprintf("%s/v1/%s/metadata/%s%s", strings.TrimRight(b.cfg.Address, "/"), b.cfg.Mount, p, alias)
+
+	resp, err := b.do(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("vault backend: delete %s: status %d", alias, resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *vaultBackend) Close() error { return nil }
@@ -0,0 +1,59 @@
+package secrets
+
+import (
+	"os"
+	"testing"
+)
+
+func TestLoadOrCreateKey_GeneratesAndPersists(t *testing.T) {
+	keyPath := "test_generated.key"
+	defer os.Remove(keyPath)
+
+	key1, err := loadOrCreateKey(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	key2, err := loadOrCreateKey(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to reload persisted key: %v", err)
+	}
+
+	if key1 != key2 {
+		t.Fatal("Reloaded key does not match the key that was persisted")
+	}
+}
+
+func TestLoadOrCreateKey_EnvOverridesFile(t *testing.T) {
+	keyPath := "test_env_override.key"
+	defer os.Remove(keyPath)
+
+	if _, err := loadOrCreateKey(keyPath); err != nil {
+		t.Fatalf("Failed to seed key file: %v", err)
+	}
+
+	os.Setenv(secretsKeyEnvVar, "a-shared-passphrase")
+	defer os.Unsetenv(secretsKeyEnvVar)
+
+	fromEnv, err := loadOrCreateKey(keyPath)
+	if err != nil {
+		t.Fatalf("Failed to load key from env: %v", err)
+	}
+
+	want, err := keyFromMaterial("a-shared-passphrase")
+	if err != nil {
+		t.Fatalf("keyFromMaterial failed: %v", err)
+	}
+	if fromEnv != want {
+		t.Fatal("Env-sourced key did not match keyFromMaterial output")
+	}
+}
+
+func TestKeyFromMaterial_KMSReferenceUnsupported(t *testing.T) {
+	if _, err := keyFromMaterial("gcpkms://projects/p/locations/global/keyRings/r/cryptoKeys/k"); err == nil {
+		t.Fatal("Expected error for unsupported gcpkms:// reference")
+	}
+	if _, err := keyFromMaterial("awskms://alias/my-key"); err == nil {
+		t.Fatal("Expected error for unsupported awskms:// reference")
+	}
+}
@@ -0,0 +1,105 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ownerAliasPrefix namespaces the alias-to-subject index used to scope
+// credentials to the OIDC-authenticated user that created them, the same
+// way wrapAliasPrefix namespaces wrap tokens in the same backend.
+const ownerAliasPrefix = "owner:"
+
+// isReservedAlias reports whether alias is one of Service's own bookkeeping
+// keys rather than a credential a caller stored, so ListAliases doesn't leak
+// wrap tokens, transit keyrings or ownership records into the credential
+// listing.
+func isReservedAlias(alias string) bool {
+	return strings.HasPrefix(alias, wrapAliasPrefix) || strings.HasPrefix(alias, ownerAliasPrefix) ||
+		strings.HasPrefix(alias, transitAliasPrefix)
+}
+
+// StoreCredentialsForUser stores data under alias exactly like
+// StoreCredentials, then records subject (the OIDC "sub" claim) as its
+// owner so GetCredentialsForUser/ListAliasesForUser can scope access to it.
+func (s *Service) StoreCredentialsForUser(subject, alias string, data map[string]interface{}) (string, error) {
+	alias, err := s.StoreCredentials(alias, data)
+	if err != nil {
+		return "", err
+	}
+	if err := s.setOwner(alias, subject); err != nil {
+		return "", fmt.Errorf("failed to record owner for %q: %w", alias, err)
+	}
+	return alias, nil
+}
+
+// GetCredentialsForUser returns the credentials stored under alias, as long
+// as it has no recorded owner (legacy data from before per-user scoping was
+// enabled) or is owned by subject. A mismatched owner fails exactly like a
+// missing alias, so one user can't probe for another's aliases.
+func (s *Service) GetCredentialsForUser(subject, alias string) (map[string]interface{}, error) {
+	if err := s.checkOwner(subject, alias); err != nil {
+		return nil, err
+	}
+	return s.GetCredentials(alias)
+}
+
+// DeleteCredentialsForUser deletes alias the same way DeleteCredentials
+// does, but only when subject owns it (or it has no recorded owner).
+func (s *Service) DeleteCredentialsForUser(subject, alias string) error {
+	if err := s.checkOwner(subject, alias); err != nil {
+		return err
+	}
+	if err := s.DeleteCredentials(alias); err != nil {
+		return err
+	}
+	return s.getBackend().Delete(context.Background(), ownerAliasPrefix+alias)
+}
+
+// ListAliasesForUser returns every alias owned by subject, plus any
+// legacy alias that predates ownership tracking.
+func (s *Service) ListAliasesForUser(subject string) ([]string, error) {
+	aliases, err := s.ListAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	scoped := make([]string, 0, len(aliases))
+	for _, a := range aliases {
+		owner, err := s.ownerOf(a)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check owner for %q: %w", a, err)
+		}
+		if owner == "" || owner == subject {
+			scoped = append(scoped, a)
+		}
+	}
+	return scoped, nil
+}
+
+func (s *Service) checkOwner(subject, alias string) error {
+	owner, err := s.ownerOf(alias)
+	if err != nil {
+		return fmt.Errorf("failed to check owner for %q: %w", alias, err)
+	}
+	if owner != "" && owner != subject {
+		return fmt.Errorf("failed to load credentials for %q: %w", alias, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Service) ownerOf(alias string) (string, error) {
+	data, err := s.getBackend().Get(context.Background(), ownerAliasPrefix+alias)
+	if err == ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s *Service) setOwner(alias, subject string) error {
+	return s.getBackend().Put(context.Background(), ownerAliasPrefix+alias, []byte(subject))
+}
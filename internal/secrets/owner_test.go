@@ -0,0 +1,78 @@
+package secrets
+
+import "testing"
+
+func TestStoreAndGetCredentialsForUser(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentialsForUser("user-a", "alias-a", map[string]interface{}{"type": "s3"})
+	if err != nil {
+		t.Fatalf("Failed to store credentials for user: %v", err)
+	}
+
+	if _, err := svc.GetCredentialsForUser("user-b", alias); err == nil {
+		t.Fatal("Expected user-b to be denied access to user-a's credentials")
+	}
+
+	creds, err := svc.GetCredentialsForUser("user-a", alias)
+	if err != nil {
+		t.Fatalf("Owner failed to fetch their own credentials: %v", err)
+	}
+	if creds["type"] != "s3" {
+		t.Fatalf("Unexpected credentials: %v", creds)
+	}
+}
+
+func TestListAliasesForUser(t *testing.T) {
+	svc := newTestService(t)
+
+	if _, err := svc.StoreCredentialsForUser("user-a", "a-alias", map[string]interface{}{"type": "s3"}); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+	if _, err := svc.StoreCredentialsForUser("user-b", "b-alias", map[string]interface{}{"type": "s3"}); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+	if _, err := svc.StoreCredentials("unowned-alias", map[string]interface{}{"type": "local"}); err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	aliases, err := svc.ListAliasesForUser("user-a")
+	if err != nil {
+		t.Fatalf("Failed to list aliases for user: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, a := range aliases {
+		found[a] = true
+	}
+	if !found["a-alias"] {
+		t.Error("Expected user-a's own alias in its scoped listing")
+	}
+	if !found["unowned-alias"] {
+		t.Error("Expected legacy unowned alias to remain visible to every user")
+	}
+	if found["b-alias"] {
+		t.Error("user-a should not see user-b's alias")
+	}
+}
+
+func TestDeleteCredentialsForUser_DeniesNonOwner(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentialsForUser("user-a", "alias-a", map[string]interface{}{"type": "s3"})
+	if err != nil {
+		t.Fatalf("Failed to store: %v", err)
+	}
+
+	if err := svc.DeleteCredentialsForUser("user-b", alias); err == nil {
+		t.Fatal("Expected user-b to be denied deleting user-a's credential")
+	}
+
+	if err := svc.DeleteCredentialsForUser("user-a", alias); err != nil {
+		t.Fatalf("Owner failed to delete their own credential: %v", err)
+	}
+
+	if _, err := svc.GetCredentials(alias); err == nil {
+		t.Fatal("Expected credential to be gone after delete")
+	}
+}
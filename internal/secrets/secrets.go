@@ -0,0 +1,330 @@
+// Package secrets stores and resolves rclone-style credential maps under a
+// short alias, encrypting them at rest behind a pluggable Backend.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Service resolves credential aliases to their underlying data, encrypting
+// everything with a key loaded from (or generated into) a key file before
+// handing it to the configured Backend.
+type Service struct {
+	backendMu sync.RWMutex
+	backend   Backend
+
+	key     [32]byte
+	transit *transit
+
+	// wrapMu serializes WrapCredentials/UnwrapCredentials so a token's
+	// single-use check-and-mark can't race across concurrent requests.
+	wrapMu sync.Mutex
+}
+
+// NewService opens the default SQLite-encrypted backend at dbPath and loads
+// (or generates) the AES key at keyPath. This is the historical entry point
+// used by most callers; use NewServiceWithBackend for Vault/env/etc.
+func NewService(dbPath, keyPath string) (*Service, error) {
+	backend, err := newSQLiteBackend(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewServiceWithBackend(backend, keyPath)
+}
+
+// NewServiceWithBackend builds a Service around an arbitrary Backend,
+// loading (or generating) the AES key at keyPath as usual.
+func NewServiceWithBackend(backend Backend, keyPath string) (*Service, error) {
+	key, err := loadOrCreateKey(keyPath)
+	if err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("failed to load secret key: %w", err)
+	}
+	s := &Service{backend: backend, key: key}
+	s.transit = newTransit(s)
+	return s, nil
+}
+
+// getBackend returns the Service's current Backend. It's read through a
+// lock because ReloadBackend can swap it out while requests are in
+// flight.
+func (s *Service) getBackend() Backend {
+	s.backendMu.RLock()
+	defer s.backendMu.RUnlock()
+	return s.backend
+}
+
+// ReloadBackend rebuilds the backend described by cfg/dbPath (the same way
+// NewServiceFromConfig would) and swaps it in, closing the previous
+// backend once the swap completes. Call this from a config.Watcher
+// subscriber on SecretsBackendChanged so a Vault address or Docker config
+// path can change without restarting the process.
+func (s *Service) ReloadBackend(cfg *BackendConfig, dbPath string) error {
+	next, err := buildBackend(cfg, dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to rebuild secrets backend: %w", err)
+	}
+
+	s.backendMu.Lock()
+	prev := s.backend
+	s.backend = next
+	s.backendMu.Unlock()
+
+	return prev.Close()
+}
+
+// secretsKeyEnvVar, when set, takes priority over keyPath: it lets every
+// instance behind a shared Postgres/Firestore backend derive the same AES
+// key without shipping a key file to each one. A gcpkms:// or awskms://
+// value names a KMS-wrapped key rather than raw material.
+const secretsKeyEnvVar = "SECRETS_KEY"
+
+func loadOrCreateKey(keyPath string) ([32]byte, error) {
+	var key [32]byte
+
+	if raw := os.Getenv(secretsKeyEnvVar); raw != "" {
+		return keyFromMaterial(raw)
+	}
+
+	if data, err := os.ReadFile(keyPath); err == nil {
+		// Historical key files may be raw 32 bytes or a hex-encoded string;
+		// normalize either into a 32-byte AES-256 key.
+		if len(data) == 32 {
+			copy(key[:], data)
+			return key, nil
+		}
+		sum := sha256.Sum256(data)
+		copy(key[:], sum[:])
+		return key, nil
+	} else if !os.IsNotExist(err) {
+		return key, err
+	}
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return key, err
+	}
+	if err := os.WriteFile(keyPath, key[:], 0600); err != nil {
+		return key, fmt.Errorf("failed to persist new secret key: %w", err)
+	}
+	return key, nil
+}
+
+// keyFromMaterial turns the value of SECRETS_KEY into a 32-byte AES-256
+// key. A gcpkms:// or awskms:// reference names a key held by a cloud KMS;
+// without a vendored KMS client to unwrap it we can't honor that yet, so we
+// report it rather than silently treating the URI itself as key material.
+// Anything else is normalized the same way a key file is: used directly if
+// it's already 32 raw bytes, otherwise hashed down with SHA-256.
+func keyFromMaterial(raw string) ([32]byte, error) {
+	var key [32]byte
+
+	if strings.HasPrefix(raw, "gcpkms://") || strings.HasPrefix(raw, "awskms://") {
+		return key, fmt.Errorf("secrets: KMS-backed %s references are not supported in this build; set %s to raw key material instead", secretsKeyEnvVar, secretsKeyEnvVar)
+	}
+
+	if len(raw) == 32 {
+		copy(key[:], raw)
+		return key, nil
+	}
+	sum := sha256.Sum256([]byte(raw))
+	copy(key[:], sum[:])
+	return key, nil
+}
+
+func (s *Service) encrypt(plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key[:])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (s *Service) decrypt(ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(s.key[:])
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, nil)
+}
+
+// StoreCredentials encrypts data and persists it under alias. If alias is
+// empty, a random alias is generated and returned.
+func (s *Service) StoreCredentials(alias string, data map[string]interface{}) (string, error) {
+	if alias == "" {
+		var buf [8]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return "", err
+		}
+		alias = hex.EncodeToString(buf[:])
+	}
+
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	ciphertext, err := s.transit.encrypt(credentialsTransitKeyName, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	if err := s.getBackend().Put(context.Background(), alias, []byte(ciphertext)); err != nil {
+		return "", fmt.Errorf("failed to store credentials for %q: %w", alias, err)
+	}
+	return alias, nil
+}
+
+// GetCredentials decrypts and returns the credential map stored under alias.
+func (s *Service) GetCredentials(alias string) (map[string]interface{}, error) {
+	blob, err := s.getBackend().Get(context.Background(), alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load credentials for %q: %w", alias, err)
+	}
+
+	plaintext, err := s.decryptCredentialBlob(alias, blob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials for %q: %w", alias, err)
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode credentials for %q: %w", alias, err)
+	}
+	return data, nil
+}
+
+// decryptCredentialBlob decrypts a stored credential blob, routing
+// transit-formatted ciphertexts to the transit engine (the common case)
+// and falling back to the legacy static-key AES-GCM format for anything
+// stored before the transit engine existed.
+func (s *Service) decryptCredentialBlob(alias string, blob []byte) ([]byte, error) {
+	if looksLikeTransitCiphertext(blob) {
+		return s.transit.decrypt(credentialsTransitKeyName, string(blob))
+	}
+	return s.decrypt(blob)
+}
+
+// RotateCredentialsKey adds a new version of the transit key that secures
+// rclone credentials going forward. Already-stored credentials keep
+// decrypting fine against their original version - call RewrapCredentials
+// to move them onto the new one.
+func (s *Service) RotateCredentialsKey() (int, error) {
+	return s.transit.rotate(credentialsTransitKeyName)
+}
+
+// RewrapCredentials re-encrypts every stored credential under the
+// credentials key's current version. There's no downtime: each alias is
+// decrypted and immediately re-stored one at a time, so GetCredentials
+// always sees a valid ciphertext for it, whether this has reached that
+// alias yet or not.
+func (s *Service) RewrapCredentials() error {
+	aliases, err := s.ListAliases()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials for rewrap: %w", err)
+	}
+
+	for _, alias := range aliases {
+		blob, err := s.getBackend().Get(context.Background(), alias)
+		if err != nil {
+			return fmt.Errorf("failed to load credential %q for rewrap: %w", alias, err)
+		}
+		plaintext, err := s.decryptCredentialBlob(alias, blob)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt credential %q for rewrap: %w", alias, err)
+		}
+		ciphertext, err := s.transit.encrypt(credentialsTransitKeyName, plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt credential %q: %w", alias, err)
+		}
+		if err := s.getBackend().Put(context.Background(), alias, []byte(ciphertext)); err != nil {
+			return fmt.Errorf("failed to store rewrapped credential %q: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// TransitEncrypt envelope-encrypts plaintext under the named transit key,
+// generating the key on first use. The ciphertext format is
+// "vault:v<version>:<base64>", matching Vault's own transit engine.
+func (s *Service) TransitEncrypt(keyName string, plaintext []byte) (string, error) {
+	return s.transit.encrypt(keyName, plaintext)
+}
+
+// TransitDecrypt decrypts ciphertext under the named transit key, routing
+// to whichever key version it was encrypted under.
+func (s *Service) TransitDecrypt(keyName, ciphertext string) ([]byte, error) {
+	return s.transit.decrypt(keyName, ciphertext)
+}
+
+// TransitRewrap re-encrypts ciphertext under the named key's current
+// version without exposing the plaintext to the caller.
+func (s *Service) TransitRewrap(keyName, ciphertext string) (string, error) {
+	return s.transit.rewrap(keyName, ciphertext)
+}
+
+// TransitRotate adds a new version to the named transit key and returns
+// it. Existing ciphertexts keep decrypting against their original
+// version until rewrapped.
+func (s *Service) TransitRotate(keyName string) (int, error) {
+	return s.transit.rotate(keyName)
+}
+
+// TransitSetMinDecryptVersion forbids TransitDecrypt from accepting
+// versions of keyName older than minVersion, forcing old ciphertexts to
+// be rewrapped first.
+func (s *Service) TransitSetMinDecryptVersion(keyName string, minVersion int) error {
+	return s.transit.setMinDecryptVersion(keyName, minVersion)
+}
+
+// ListAliases returns every credential alias known to the backend, not
+// counting Service's own bookkeeping entries (wrap tokens, transit
+// keyrings, owner records).
+func (s *Service) ListAliases() ([]string, error) {
+	all, err := s.getBackend().List(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := make([]string, 0, len(all))
+	for _, a := range all {
+		if !isReservedAlias(a) {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases, nil
+}
+
+// DeleteCredentials removes alias from the backend.
+func (s *Service) DeleteCredentials(alias string) error {
+	return s.getBackend().Delete(context.Background(), alias)
+}
+
+// Close releases the underlying backend's resources.
+func (s *Service) Close() error {
+	return s.getBackend().Close()
+}
@@ -0,0 +1,303 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// transitAliasPrefix namespaces a transit key's persisted keyring within
+// the same Backend used for plain credential storage, the same way
+// dockerAliasPrefix namespaces docker-sourced credentials.
+const transitAliasPrefix = "transit:keyring:"
+
+// credentialsTransitKeyName is the transit key StoreCredentials and
+// GetCredentials envelope-encrypt under. Rotating it and calling
+// Service.RewrapCredentials is how every stored rclone credential gets
+// re-encrypted after a key rotation without any service downtime.
+const credentialsTransitKeyName = "credentials"
+
+// transitKeyVersion is one generation of key material for a named transit
+// key. Rotation only ever appends; nothing is ever deleted, so old
+// ciphertexts stay decryptable until MinDecryptVersion forbids it.
+type transitKeyVersion struct {
+	Version int    `json:"version"`
+	Key     []byte `json:"key"`
+}
+
+// transitKeyring is the persisted state for one named transit key.
+type transitKeyring struct {
+	Name              string              `json:"name"`
+	Versions          []transitKeyVersion `json:"versions"`
+	MinDecryptVersion int                 `json:"min_decrypt_version"`
+}
+
+func (k *transitKeyring) latest() transitKeyVersion {
+	return k.Versions[len(k.Versions)-1]
+}
+
+func (k *transitKeyring) version(v int) (transitKeyVersion, bool) {
+	for _, kv := range k.Versions {
+		if kv.Version == v {
+			return kv, true
+		}
+	}
+	return transitKeyVersion{}, false
+}
+
+// transit is a Vault-inspired transit secrets engine: named keys with
+// versioned material, used purely for envelope encryption. Keyrings are
+// persisted through the owning Service's Backend, encrypted at rest with
+// the Service's own root key the same way a KMS wraps data keys with a
+// key-encrypting key.
+type transit struct {
+	s *Service
+
+	mu       sync.Mutex
+	keyrings map[string]*transitKeyring
+}
+
+func newTransit(s *Service) *transit {
+	return &transit{s: s, keyrings: make(map[string]*transitKeyring)}
+}
+
+func newTransitKeyMaterial() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// getOrCreateKeyring returns the named keyring, generating and persisting
+// a fresh one version 1 if this is the key's first use.
+func (t *transit) getOrCreateKeyring(name string) (*transitKeyring, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if kr, ok := t.keyrings[name]; ok {
+		return kr, nil
+	}
+
+	kr, err := t.fetchKeyring(name)
+	if err != nil {
+		if err != ErrNotFound {
+			return nil, err
+		}
+		key, genErr := newTransitKeyMaterial()
+		if genErr != nil {
+			return nil, genErr
+		}
+		kr = &transitKeyring{
+			Name:              name,
+			MinDecryptVersion: 1,
+			Versions:          []transitKeyVersion{{Version: 1, Key: key}},
+		}
+		if err := t.persist(kr); err != nil {
+			return nil, err
+		}
+	}
+
+	t.keyrings[name] = kr
+	return kr, nil
+}
+
+func (t *transit) fetchKeyring(name string) (*transitKeyring, error) {
+	ciphertext, err := t.s.getBackend().Get(context.Background(), transitAliasPrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := t.s.decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt transit keyring %q: %w", name, err)
+	}
+	var kr transitKeyring
+	if err := json.Unmarshal(plaintext, &kr); err != nil {
+		return nil, fmt.Errorf("failed to decode transit keyring %q: %w", name, err)
+	}
+	return &kr, nil
+}
+
+func (t *transit) persist(kr *transitKeyring) error {
+	plaintext, err := json.Marshal(kr)
+	if err != nil {
+		return fmt.Errorf("failed to encode transit keyring %q: %w", kr.Name, err)
+	}
+	ciphertext, err := t.s.encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt transit keyring %q: %w", kr.Name, err)
+	}
+	return t.s.getBackend().Put(context.Background(), transitAliasPrefix+kr.Name, ciphertext)
+}
+
+// formatCiphertext renders version/data the way Vault's transit engine
+// does: "vault:v<version>:<base64>".
+func formatCiphertext(version int, data []byte) string {
+	return fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(data))
+}
+
+func parseCiphertext(ciphertext string) (version int, data []byte, err error) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" || !strings.HasPrefix(parts[1], "v") {
+		return 0, nil, fmt.Errorf("transit: malformed ciphertext")
+	}
+	version, err = strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil {
+		return 0, nil, fmt.Errorf("transit: malformed ciphertext version: %w", err)
+	}
+	data, err = base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return 0, nil, fmt.Errorf("transit: malformed ciphertext payload: %w", err)
+	}
+	return version, data, nil
+}
+
+// looksLikeTransitCiphertext reports whether blob is transit-formatted
+// ("vault:vN:...") rather than a legacy raw AES-GCM blob predating the
+// transit engine.
+func looksLikeTransitCiphertext(blob []byte) bool {
+	return strings.HasPrefix(string(blob), "vault:v")
+}
+
+// encrypt envelope-encrypts plaintext under name's latest key version.
+func (t *transit) encrypt(name string, plaintext []byte) (string, error) {
+	kr, err := t.getOrCreateKeyring(name)
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	kv := kr.latest()
+	t.mu.Unlock()
+
+	data, err := aesGCMSeal(kv.Key, plaintext)
+	if err != nil {
+		return "", fmt.Errorf("transit: failed to encrypt with key %q: %w", name, err)
+	}
+	return formatCiphertext(kv.Version, data), nil
+}
+
+// decrypt routes ciphertext to the key version it was encrypted under,
+// rejecting versions older than the key's configured MinDecryptVersion.
+func (t *transit) decrypt(name, ciphertext string) ([]byte, error) {
+	version, data, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	kr, err := t.getOrCreateKeyring(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if version < kr.MinDecryptVersion {
+		return nil, fmt.Errorf("transit: key %q version %d is below the minimum decryptable version %d; rewrap required", name, version, kr.MinDecryptVersion)
+	}
+	kv, ok := kr.version(version)
+	if !ok {
+		return nil, fmt.Errorf("transit: key %q has no version %d", name, version)
+	}
+
+	plaintext, err := aesGCMOpen(kv.Key, data)
+	if err != nil {
+		return nil, fmt.Errorf("transit: failed to decrypt with key %q version %d: %w", name, version, err)
+	}
+	return plaintext, nil
+}
+
+// rewrap decrypts ciphertext and re-encrypts it under name's latest
+// version, the per-ciphertext operation a bulk RewrapCredentials loops
+// over after a rotation.
+func (t *transit) rewrap(name, ciphertext string) (string, error) {
+	plaintext, err := t.decrypt(name, ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return t.encrypt(name, plaintext)
+}
+
+// rotate adds a new key version, which future encrypt calls use; existing
+// ciphertexts keep decrypting against their original version until
+// rewrapped.
+func (t *transit) rotate(name string) (int, error) {
+	kr, err := t.getOrCreateKeyring(name)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := newTransitKeyMaterial()
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nextVersion := kr.latest().Version + 1
+	kr.Versions = append(kr.Versions, transitKeyVersion{Version: nextVersion, Key: key})
+	if err := t.persist(kr); err != nil {
+		return 0, err
+	}
+	return nextVersion, nil
+}
+
+// setMinDecryptVersion forbids Decrypt from accepting versions older than
+// minVersion, forcing callers to rewrap first - Vault transit's
+// min_decryption_version key config.
+func (t *transit) setMinDecryptVersion(name string, minVersion int) error {
+	kr, err := t.getOrCreateKeyring(name)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if minVersion < 1 || minVersion > kr.latest().Version {
+		return fmt.Errorf("transit: invalid min_decryption_version %d for key %q (valid range 1-%d)", minVersion, name, kr.latest().Version)
+	}
+	kr.MinDecryptVersion = minVersion
+	return t.persist(kr)
+}
+
+func aesGCMSeal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesGCMOpen(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, data := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, data, nil)
+}
@@ -0,0 +1,149 @@
+package secrets
+
+import "testing"
+
+func TestTransitEncryptDecryptRoundTrip(t *testing.T) {
+	svc := newTestService(t)
+
+	ciphertext, err := svc.TransitEncrypt("test-key", []byte("hello world"))
+	if err != nil {
+		t.Fatalf("TransitEncrypt failed: %v", err)
+	}
+
+	plaintext, err := svc.TransitDecrypt("test-key", ciphertext)
+	if err != nil {
+		t.Fatalf("TransitDecrypt failed: %v", err)
+	}
+	if string(plaintext) != "hello world" {
+		t.Fatalf("TransitDecrypt = %q, want %q", plaintext, "hello world")
+	}
+}
+
+func TestTransitRotateKeepsOldCiphertextsDecryptable(t *testing.T) {
+	svc := newTestService(t)
+
+	v1Ciphertext, err := svc.TransitEncrypt("test-key", []byte("v1 secret"))
+	if err != nil {
+		t.Fatalf("TransitEncrypt failed: %v", err)
+	}
+
+	newVersion, err := svc.TransitRotate("test-key")
+	if err != nil {
+		t.Fatalf("TransitRotate failed: %v", err)
+	}
+	if newVersion != 2 {
+		t.Fatalf("TransitRotate = %d, want 2", newVersion)
+	}
+
+	v2Ciphertext, err := svc.TransitEncrypt("test-key", []byte("v2 secret"))
+	if err != nil {
+		t.Fatalf("TransitEncrypt failed: %v", err)
+	}
+
+	plaintext, err := svc.TransitDecrypt("test-key", v1Ciphertext)
+	if err != nil {
+		t.Fatalf("expected v1 ciphertext to remain decryptable after rotation: %v", err)
+	}
+	if string(plaintext) != "v1 secret" {
+		t.Fatalf("TransitDecrypt(v1) = %q, want %q", plaintext, "v1 secret")
+	}
+
+	plaintext, err = svc.TransitDecrypt("test-key", v2Ciphertext)
+	if err != nil {
+		t.Fatalf("TransitDecrypt(v2) failed: %v", err)
+	}
+	if string(plaintext) != "v2 secret" {
+		t.Fatalf("TransitDecrypt(v2) = %q, want %q", plaintext, "v2 secret")
+	}
+}
+
+func TestTransitRewrapMovesCiphertextToLatestVersion(t *testing.T) {
+	svc := newTestService(t)
+
+	v1Ciphertext, err := svc.TransitEncrypt("test-key", []byte("rewrap me"))
+	if err != nil {
+		t.Fatalf("TransitEncrypt failed: %v", err)
+	}
+	if _, err := svc.TransitRotate("test-key"); err != nil {
+		t.Fatalf("TransitRotate failed: %v", err)
+	}
+
+	rewrapped, err := svc.TransitRewrap("test-key", v1Ciphertext)
+	if err != nil {
+		t.Fatalf("TransitRewrap failed: %v", err)
+	}
+
+	// Raising MinDecryptVersion to the latest version must reject the old
+	// ciphertext but still accept the rewrapped one.
+	if err := svc.TransitSetMinDecryptVersion("test-key", 2); err != nil {
+		t.Fatalf("TransitSetMinDecryptVersion failed: %v", err)
+	}
+
+	if _, err := svc.TransitDecrypt("test-key", v1Ciphertext); err == nil {
+		t.Fatal("expected v1 ciphertext to be rejected after raising MinDecryptVersion")
+	}
+
+	plaintext, err := svc.TransitDecrypt("test-key", rewrapped)
+	if err != nil {
+		t.Fatalf("expected rewrapped ciphertext to still decrypt: %v", err)
+	}
+	if string(plaintext) != "rewrap me" {
+		t.Fatalf("TransitDecrypt(rewrapped) = %q, want %q", plaintext, "rewrap me")
+	}
+}
+
+func TestTransitSetMinDecryptVersionRejectsOutOfRange(t *testing.T) {
+	svc := newTestService(t)
+
+	if _, err := svc.TransitEncrypt("test-key", []byte("seed")); err != nil {
+		t.Fatalf("TransitEncrypt failed: %v", err)
+	}
+
+	if err := svc.TransitSetMinDecryptVersion("test-key", 0); err == nil {
+		t.Fatal("expected error for MinDecryptVersion below 1")
+	}
+	if err := svc.TransitSetMinDecryptVersion("test-key", 2); err == nil {
+		t.Fatal("expected error for MinDecryptVersion above the latest key version")
+	}
+}
+
+func TestCredentialsRotateAndRewrap(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentials("prod-s3", map[string]interface{}{"type": "s3", "key": "value"})
+	if err != nil {
+		t.Fatalf("Failed to store credentials: %v", err)
+	}
+
+	if _, err := svc.RotateCredentialsKey(); err != nil {
+		t.Fatalf("RotateCredentialsKey failed: %v", err)
+	}
+
+	// Stored credentials must still decrypt against their original key
+	// version even though the credentials key has since rotated.
+	creds, err := svc.GetCredentials(alias)
+	if err != nil {
+		t.Fatalf("GetCredentials failed after rotation: %v", err)
+	}
+	if creds["type"] != "s3" {
+		t.Fatalf("GetCredentials after rotation = %v", creds)
+	}
+
+	if err := svc.RewrapCredentials(); err != nil {
+		t.Fatalf("RewrapCredentials failed: %v", err)
+	}
+
+	if err := svc.TransitSetMinDecryptVersion(credentialsTransitKeyName, 2); err != nil {
+		t.Fatalf("TransitSetMinDecryptVersion failed: %v", err)
+	}
+
+	// After rewrap, every credential should be re-encrypted under the
+	// latest version, so raising MinDecryptVersion must not break reads.
+	creds, err = svc.GetCredentials(alias)
+	if err != nil {
+		t.Fatalf("expected rewrapped credentials to survive a raised MinDecryptVersion: %v", err)
+	}
+	if creds["key"] != "value" {
+		t.Fatalf("GetCredentials after rewrap = %v", creds)
+	}
+}
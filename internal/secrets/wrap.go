@@ -0,0 +1,130 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// wrapAliasPrefix namespaces wrapping-token records within the same Backend
+// used for credentials and transit keyrings, the same way transitAliasPrefix
+// does for transit.
+const wrapAliasPrefix = "wrap:token:"
+
+// wrappedToken is the persisted state behind one response-wrapping token,
+// modeled on Vault's response wrapping: a single-use handle that resolves
+// to an alias until it expires or is unwrapped, whichever comes first.
+type wrappedToken struct {
+	Alias     string    `json:"alias"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"used"`
+}
+
+// WrapCredentials mints a random single-use token that resolves to alias
+// for ttl. The token itself carries no credential material, so it's safe
+// to log or hand off to another process; UnwrapCredentials is the only way
+// to learn the alias it points to, and it can only succeed once.
+func (s *Service) WrapCredentials(alias string, ttl time.Duration) (string, error) {
+	var buf [20]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf[:])
+
+	wt := wrappedToken{Alias: alias, ExpiresAt: time.Now().Add(ttl)}
+	if err := s.putWrappedToken(token, wt); err != nil {
+		return "", fmt.Errorf("failed to store wrap token: %w", err)
+	}
+	return token, nil
+}
+
+// UnwrapCredentials atomically marks token used and returns the alias it
+// was minted for, rejecting a token that's expired or already been
+// unwrapped. wrapMu serializes this read-check-write sequence so two
+// concurrent unwraps of the same token can't both succeed.
+func (s *Service) UnwrapCredentials(token string) (string, error) {
+	s.wrapMu.Lock()
+	defer s.wrapMu.Unlock()
+
+	wt, err := s.getWrappedToken(token)
+	if err != nil {
+		return "", fmt.Errorf("wrap token not found: %w", err)
+	}
+	if wt.Used {
+		return "", fmt.Errorf("wrap token already used")
+	}
+	if time.Now().After(wt.ExpiresAt) {
+		s.deleteWrappedToken(token)
+		return "", fmt.Errorf("wrap token expired")
+	}
+
+	wt.Used = true
+	if err := s.putWrappedToken(token, wt); err != nil {
+		return "", fmt.Errorf("failed to mark wrap token used: %w", err)
+	}
+	return wt.Alias, nil
+}
+
+// SweepExpiredWrapTokens deletes every wrap token past its expiry. A token
+// that's never unwrapped would otherwise sit in the backend forever, so
+// callers should run this periodically (config.Watcher reloads only on
+// file changes, so it needs a timer of its own) rather than relying on
+// unwrap to clean up after itself.
+func (s *Service) SweepExpiredWrapTokens() error {
+	aliases, err := s.getBackend().List(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to list wrap tokens for sweep: %w", err)
+	}
+
+	now := time.Now()
+	for _, a := range aliases {
+		token, ok := strings.CutPrefix(a, wrapAliasPrefix)
+		if !ok {
+			continue
+		}
+
+		s.wrapMu.Lock()
+		wt, err := s.getWrappedToken(token)
+		if err == nil && now.After(wt.ExpiresAt) {
+			s.deleteWrappedToken(token)
+		}
+		s.wrapMu.Unlock()
+	}
+	return nil
+}
+
+func (s *Service) putWrappedToken(token string, wt wrappedToken) error {
+	plaintext, err := json.Marshal(wt)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := s.encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+	return s.getBackend().Put(context.Background(), wrapAliasPrefix+token, ciphertext)
+}
+
+func (s *Service) getWrappedToken(token string) (wrappedToken, error) {
+	blob, err := s.getBackend().Get(context.Background(), wrapAliasPrefix+token)
+	if err != nil {
+		return wrappedToken{}, err
+	}
+	plaintext, err := s.decrypt(blob)
+	if err != nil {
+		return wrappedToken{}, fmt.Errorf("failed to decrypt wrap token: %w", err)
+	}
+	var wt wrappedToken
+	if err := json.Unmarshal(plaintext, &wt); err != nil {
+		return wrappedToken{}, fmt.Errorf("failed to decode wrap token: %w", err)
+	}
+	return wt, nil
+}
+
+func (s *Service) deleteWrappedToken(token string) error {
+	return s.getBackend().Delete(context.Background(), wrapAliasPrefix+token)
+}
@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	dir := t.TempDir()
+	svc, err := NewService(filepath.Join(dir, "secrets.db"), filepath.Join(dir, "secret.key"))
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	t.Cleanup(func() { svc.Close() })
+	return svc
+}
+
+func TestWrapUnwrapCredentials(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentials("prod-s3", map[string]interface{}{"type": "s3"})
+	if err != nil {
+		t.Fatalf("Failed to store credentials: %v", err)
+	}
+
+	token, err := svc.WrapCredentials(alias, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to wrap credentials: %v", err)
+	}
+	if token == "" {
+		t.Fatal("Expected a non-empty wrap token")
+	}
+
+	got, err := svc.UnwrapCredentials(token)
+	if err != nil {
+		t.Fatalf("Failed to unwrap token: %v", err)
+	}
+	if got != alias {
+		t.Fatalf("UnwrapCredentials = %q, want %q", got, alias)
+	}
+
+	if _, err := svc.UnwrapCredentials(token); err == nil {
+		t.Fatal("Expected error unwrapping an already-used token")
+	}
+}
+
+func TestUnwrapCredentials_Expired(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentials("prod-s3", map[string]interface{}{"type": "s3"})
+	if err != nil {
+		t.Fatalf("Failed to store credentials: %v", err)
+	}
+
+	token, err := svc.WrapCredentials(alias, -time.Second)
+	if err != nil {
+		t.Fatalf("Failed to wrap credentials: %v", err)
+	}
+
+	if _, err := svc.UnwrapCredentials(token); err == nil {
+		t.Fatal("Expected error unwrapping an expired token")
+	}
+}
+
+func TestUnwrapCredentials_Unknown(t *testing.T) {
+	svc := newTestService(t)
+
+	if _, err := svc.UnwrapCredentials("does-not-exist"); err == nil {
+		t.Fatal("Expected error unwrapping an unknown token")
+	}
+}
+
+func TestSweepExpiredWrapTokens(t *testing.T) {
+	svc := newTestService(t)
+
+	alias, err := svc.StoreCredentials("prod-s3", map[string]interface{}{"type": "s3"})
+	if err != nil {
+		t.Fatalf("Failed to store credentials: %v", err)
+	}
+
+	expiredToken, err := svc.WrapCredentials(alias, -time.Second)
+	if err != nil {
+		t.Fatalf("Failed to wrap credentials: %v", err)
+	}
+	liveToken, err := svc.WrapCredentials(alias, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to wrap credentials: %v", err)
+	}
+
+	if err := svc.SweepExpiredWrapTokens(); err != nil {
+		t.Fatalf("SweepExpiredWrapTokens failed: %v", err)
+	}
+
+	if _, err := svc.getWrappedToken(expiredToken); err == nil {
+		t.Fatal("Expected expired token to be swept")
+	}
+	if _, err := svc.getWrappedToken(liveToken); err != nil {
+		t.Fatalf("Expected live token to survive the sweep: %v", err)
+	}
+}
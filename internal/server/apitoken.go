@@ -0,0 +1,334 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"flight2/internal/apitoken"
+	"flight2/internal/dataset_source"
+)
+
+// apiTokenCtxKey stores the authenticated token's scope set on the request
+// context, the same way subjectCtxKey does for OIDC sessions, so a JSON
+// handler could narrow its own response to what the token covers without
+// re-parsing the Authorization header.
+type apiTokenCtxKey struct{}
+
+func withAPIToken(ctx context.Context, info apitoken.TokenInfo) context.Context {
+	return context.WithValue(ctx, apiTokenCtxKey{}, info)
+}
+
+func apiTokenFromContext(ctx context.Context) (apitoken.TokenInfo, bool) {
+	info, ok := ctx.Value(apiTokenCtxKey{}).(apitoken.TokenInfo)
+	return info, ok
+}
+
+// apiTokenScope maps an /api/v1/* or /dav/* request to the (alias,
+// permission) pair it requires. "" for remotes/the DAV root since neither
+// is scoped to a single alias - each lists every alias the token can at
+// least `list`, filtered inside the handler (handleAPIRemotes) or
+// aggregateFS.OpenFile (the DAV root listing) itself.
+func apiTokenScope(r *http.Request) (alias string, perm apitoken.Permission, ok bool) {
+	if rest, isAPI := strings.CutPrefix(r.URL.Path, "/api/v1/"); isAPI {
+		switch {
+		case rest == "remotes":
+			return "", apitoken.PermissionList, true
+		case strings.HasPrefix(rest, "browse/"):
+			alias, _, _ = strings.Cut(strings.TrimPrefix(rest, "browse/"), "/")
+			return alias, apitoken.PermissionList, true
+		case strings.HasPrefix(rest, "view/"):
+			alias, _, _ = strings.Cut(strings.TrimPrefix(rest, "view/"), "/")
+			return alias, apitoken.PermissionRead, true
+		}
+		return "", "", false
+	}
+
+	if rest, isDAV := strings.CutPrefix(r.URL.Path, "/dav"); isDAV {
+		alias, _ = splitDAVPath(rest)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, "PROPFIND", http.MethodOptions:
+			return alias, apitoken.PermissionRead, true
+		default: // PUT, MKCOL, DELETE, MOVE, COPY, PROPPATCH, LOCK, UNLOCK
+			return alias, apitoken.PermissionWrite, true
+		}
+	}
+
+	return "", "", false
+}
+
+// bearerOrBasicToken extracts the caller's API token from either an
+// "Authorization: Bearer <token>" header (the JSON API's native scheme) or
+// HTTP Basic auth with the token as the password (the bridge WebDAV
+// clients need, since Finder/Explorer/rclone mount don't speak bearer
+// tokens - the username is ignored).
+func bearerOrBasicToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+// apiTokenMiddleware guards every /api/v1/* and /dav/* request behind a
+// scoped bearer token, the JSON-API/WebDAV equivalent of oidcAuth.middleware
+// for /app/*: it parses the token, loads its scope set, and rejects any
+// request whose (alias, action) pair isn't covered before the handler ever
+// touches dataManager. Paths outside those two prefixes pass straight
+// through unauthenticated here - they're guarded separately, by
+// oidcAuth.middleware or localOnlyMiddleware wrapping this one in Router.
+func (s *Server) apiTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/") && !strings.HasPrefix(r.URL.Path, "/dav/") && r.URL.Path != "/dav" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.apiTokens == nil {
+			http.Error(w, "API tokens are disabled", http.StatusServiceUnavailable)
+			return
+		}
+
+		token := bearerOrBasicToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="flight2"`)
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		info, err := s.apiTokens.Authenticate(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="flight2"`)
+			http.Error(w, "invalid or revoked token", http.StatusUnauthorized)
+			return
+		}
+
+		alias, perm, ok := apiTokenScope(r)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if alias != "" && !info.Allows(alias, perm) {
+			log.Printf("api token %s: denied %s %s (needs %s on %q)", info.ID, r.Method, r.URL.Path, perm, alias)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		log.Printf("api token %s: %s %s", info.ID, r.Method, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(withAPIToken(r.Context(), info)))
+	})
+}
+
+// apiRemote is one entry in GET /api/v1/remotes' response.
+type apiRemote struct {
+	Alias string `json:"alias"`
+}
+
+// handleAPIRemotes lists every alias the bearer token can at least `list`,
+// so an external tool can discover what it has access to without also
+// being handed the operator's full /app/credentials/manage view.
+func (s *Server) handleAPIRemotes(w http.ResponseWriter, r *http.Request) {
+	info, _ := apiTokenFromContext(r.Context())
+
+	aliases, err := s.secrets.ListAliases()
+	if err != nil {
+		http.Error(w, "Failed to list remotes", http.StatusInternalServerError)
+		return
+	}
+
+	remotes := make([]apiRemote, 0, len(aliases))
+	for _, alias := range aliases {
+		if info.Allows(alias, apitoken.PermissionList) {
+			remotes = append(remotes, apiRemote{Alias: alias})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"remotes": remotes})
+}
+
+// apiEntry is one entry in GET /api/v1/browse/{alias}/{path...}'s response.
+type apiEntry struct {
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Mtime int64  `json:"mtime"`
+	IsDir bool   `json:"is_dir"`
+}
+
+// handleAPIBrowse is the JSON equivalent of handleBrowse: it lists relPath
+// through the same dataset_source.ListEntries the HTML listing uses, but
+// returns {entries:[...]} instead of a page, for a caller that wants the
+// aggregated remotes without scraping HTML.
+func (s *Server) handleAPIBrowse(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+
+	if s.isProtectedPath(relPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	infos, err := dataset_source.ListEntries(r.Context(), relPath, creds)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list entries: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]apiEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = apiEntry{
+			Name:  info.Name(),
+			Size:  info.Size(),
+			Mtime: info.ModTime().Unix(),
+			IsDir: info.IsDir(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"entries": entries})
+}
+
+// handleAPIView streams relPath's bytes exactly like handleRaw - it's the
+// same handler, reached through the bearer-token-guarded /api/v1/ prefix
+// instead of the cookie/session-guarded /app/ one.
+func (s *Server) handleAPIView(w http.ResponseWriter, r *http.Request) {
+	s.handleRaw(w, r)
+}
+
+// handleTokensIndex serves the /app/tokens/manage UI: existing tokens
+// (id, scopes, created_at - never the secret, which isn't recoverable
+// after Issue) plus a form to mint a new one. It mirrors
+// handleIndex/handleCreateCredential's split except both list and create
+// live on the same GET/POST pair, since a token form has no "edit" mode -
+// scopes are fixed at issue time and a change means revoke-and-reissue.
+func (s *Server) handleTokensIndex(w http.ResponseWriter, r *http.Request) {
+	if s.apiTokens == nil {
+		http.Error(w, "API tokens are disabled", http.StatusServiceUnavailable)
+		return
+	}
+
+	var mintedToken, mintedID string
+	if r.Method == http.MethodPost {
+		scopes, err := parseTokenScopesForm(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mintedToken, mintedID, err = s.apiTokens.Issue(scopes)
+		if err != nil {
+			http.Error(w, "Failed to issue token: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tokens, err := s.apiTokens.List()
+	if err != nil {
+		http.Error(w, "Failed to list tokens", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Flight2 API Tokens</title>
+<link rel="stylesheet" href="/cssjs/default.css">
+</head>
+<body>
+<div class="container">
+<h2>🔑 API Tokens</h2>
+`)
+
+	if mintedToken != "" {
+		fmt.Fprintf(w, `<section class="add-remote"><p><strong>Token issued (id %s) - copy it now, it won't be shown again:</strong></p><pre>%s</pre></section><hr class="separator">`,
+			html.EscapeString(mintedID), html.EscapeString(mintedToken))
+	}
+
+	fmt.Fprintf(w, `
+<section class="remotes">
+<table class="premium-table">
+<thead><tr><th>ID</th><th>Scopes</th><th>Created</th><th>Actions</th></tr></thead>
+<tbody>`)
+	if len(tokens) == 0 {
+		fmt.Fprintf(w, "<tr><td colspan='4'>No tokens issued yet.</td></tr>")
+	}
+	for _, t := range tokens {
+		scopeStrs := make([]string, len(t.Scopes))
+		for i, sc := range t.Scopes {
+			scopeStrs[i] = fmt.Sprintf("%s:%s", sc.Alias, sc.Permission)
+		}
+		fmt.Fprintf(w, `<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td>
+<form action="/app/tokens/revoke" method="POST" style="display:inline">
+<input type="hidden" name="id" value="%s">
+<input type="submit" value="🗑️ Revoke" class="btn btn-delete" onclick="return confirm('Revoke this token?')">
+</form>
+</td></tr>`,
+			html.EscapeString(t.ID), html.EscapeString(strings.Join(scopeStrs, ", ")), t.CreatedAt.Format("2006-01-02 15:04:05"), html.EscapeString(t.ID))
+	}
+	fmt.Fprintf(w, `</tbody></table></section>
+<hr class="separator">
+<section class="add-remote">
+<h2>➕ Issue New Token</h2>
+<form action="/app/tokens/manage" method="POST" class="credential-form">
+<div class="form-group">
+<label>Alias</label>
+<input type="text" name="alias" required placeholder="e.g., my-s3-bucket">
+</div>
+<div class="form-group">
+<label>Permission</label>
+<select name="permission" required>
+<option value="list">list</option>
+<option value="read">read</option>
+<option value="write">write</option>
+<option value="admin">admin</option>
+</select>
+</div>
+<button type="submit" class="btn btn-primary">Issue Token</button>
+</form>
+</section>
+</div>
+</body></html>`)
+}
+
+// parseTokenScopesForm builds the single {alias, permission} scope
+// handleTokensIndex's form submits. It's a []Scope (not a single Scope)
+// because apitoken.Store.Issue always takes a set - a future multi-scope
+// form would extend this function, not Store.Issue.
+func parseTokenScopesForm(r *http.Request) ([]apitoken.Scope, error) {
+	alias := r.FormValue("alias")
+	perm := apitoken.Permission(r.FormValue("permission"))
+	if alias == "" {
+		return nil, fmt.Errorf("alias is required")
+	}
+	return []apitoken.Scope{{Alias: alias, Permission: perm}}, nil
+}
+
+// handleTokensRevoke revokes the token named by the "id" form value.
+func (s *Server) handleTokensRevoke(w http.ResponseWriter, r *http.Request) {
+	if s.apiTokens == nil {
+		http.Error(w, "API tokens are disabled", http.StatusServiceUnavailable)
+		return
+	}
+	id := r.FormValue("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.apiTokens.Revoke(id); err != nil {
+		http.Error(w, "Failed to revoke token", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/app/tokens/manage", http.StatusSeeOther)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCConfig configures the optional OIDC login flow that Router wraps the
+// mux with in place of localOnlyMiddleware. It's the caller's job to keep
+// it mutually exclusive with LocalOnly; NewServer refuses to build a
+// Server with both set.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+
+	// AllowDomains restricts login to these email domains. Empty allows
+	// any account the issuer will vouch for.
+	AllowDomains []string
+
+	// CookieSecret is the HMAC key signing the session cookie.
+	CookieSecret string
+}
+
+const (
+	sessionCookieName = "flight2_session"
+	stateCookieName   = "flight2_oidc_state"
+	sessionTTL        = 24 * time.Hour
+)
+
+// subjectCtxKey stores the authenticated OIDC subject on the request
+// context so handlers downstream of oidcAuth.middleware can scope
+// credential access to it.
+type subjectCtxKey struct{}
+
+func withSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectCtxKey{}, subject)
+}
+
+// subjectFromContext returns the authenticated subject, or "" when the
+// request wasn't served behind OIDC (localOnly mode, or an unscoped alias).
+func subjectFromContext(ctx context.Context) string {
+	sub, _ := ctx.Value(subjectCtxKey{}).(string)
+	return sub
+}
+
+// sessionClaims is the payload of the signed session cookie: just enough
+// to scope credential access and show who's logged in, re-derived from the
+// ID token on every login rather than cached longer than sessionTTL.
+type sessionClaims struct {
+	Sub   string `json:"sub"`
+	Email string `json:"email"`
+	Exp   int64  `json:"exp"`
+}
+
+// oidcAuth implements the login/callback/session-cookie dance described by
+// OIDCConfig. A nil *oidcAuth (construction failed) is handled by Router,
+// which fails closed rather than serving /app/* unauthenticated.
+type oidcAuth struct {
+	cfg      OIDCConfig
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth2   oauth2.Config
+}
+
+func newOIDCAuth(ctx context.Context, cfg OIDCConfig) (*oidcAuth, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: failed to discover issuer %q: %w", cfg.Issuer, err)
+	}
+
+	return &oidcAuth{
+		cfg:      cfg,
+		provider: provider,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+		},
+	}, nil
+}
+
+// middleware guards every request behind a session cookie, handling the
+// login/callback endpoints itself - the literal substitute for
+// localOnlyMiddleware, which wraps the entire mux with its own host
+// check, so OIDC mode must cover the same surface (including the
+// catch-all handleBanquet route at "/", which serves ServeFolder/
+// DefaultDB with no alias or credential required at all) rather than
+// just /app/*. /api/v1/* and /dav/* still also require their own bearer
+// token via apiTokenMiddleware, same as they do under localOnlyMiddleware.
+func (a *oidcAuth) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/auth/login":
+			a.handleLogin(w, r)
+			return
+		case "/app/auth/callback":
+			a.handleCallback(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			a.redirectToLogin(w, r)
+			return
+		}
+		claims, err := a.verifySession(cookie.Value)
+		if err != nil {
+			a.redirectToLogin(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(withSubject(r.Context(), claims.Sub)))
+	})
+}
+
+func (a *oidcAuth) redirectToLogin(w http.ResponseWriter, r *http.Request) {
+	returnTo := r.URL.RequestURI()
+	http.Redirect(w, r, "/app/auth/login?return_to="+url.QueryEscape(returnTo), http.StatusFound)
+}
+
+func (a *oidcAuth) handleLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := randomToken(16)
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+
+	returnTo := r.URL.Query().Get("return_to")
+	if returnTo == "" {
+		returnTo = "/app/"
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    state + "|" + returnTo,
+		Path:     "/",
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, a.oauth2.AuthCodeURL(state), http.StatusFound)
+}
+
+func (a *oidcAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	stateCookie, err := r.Cookie(stateCookieName)
+	if err != nil {
+		http.Error(w, "missing oidc state cookie", http.StatusBadRequest)
+		return
+	}
+	wantState, returnTo, ok := strings.Cut(stateCookie.Value, "|")
+	if !ok || r.URL.Query().Get("state") != wantState {
+		http.Error(w, "oidc state mismatch", http.StatusBadRequest)
+		return
+	}
+
+	oauth2Token, err := a.oauth2.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, "failed to exchange authorization code: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "token response did not include an id_token", http.StatusBadGateway)
+		return
+	}
+	idToken, err := a.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(w, "failed to verify id_token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var idClaims struct {
+		Email string `json:"email"`
+	}
+	if err := idToken.Claims(&idClaims); err != nil {
+		http.Error(w, "failed to read id_token claims: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	if !a.emailAllowed(idClaims.Email) {
+		http.Error(w, fmt.Sprintf("account %q is not on an allowed domain", idClaims.Email), http.StatusForbidden)
+		return
+	}
+
+	session, err := a.signSession(sessionClaims{
+		Sub:   idToken.Subject,
+		Email: idClaims.Email,
+		Exp:   time.Now().Add(sessionTTL).Unix(),
+	})
+	if err != nil {
+		http.Error(w, "failed to start session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{Name: stateCookieName, Path: "/", MaxAge: -1})
+
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+func (a *oidcAuth) emailAllowed(email string) bool {
+	if len(a.cfg.AllowDomains) == 0 {
+		return true
+	}
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return false
+	}
+	for _, allowed := range a.cfg.AllowDomains {
+		if strings.EqualFold(domain, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// signSession encodes claims as "<base64url payload>.<base64url HMAC-SHA256
+// signature>", the same shape a JWT uses without needing a JWT library for
+// a cookie only this server ever reads.
+func (a *oidcAuth) signSession(claims sessionClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payloadB64 := base64.RawURLEncoding.EncodeToString(payload)
+	return payloadB64 + "." + a.sign(payloadB64), nil
+}
+
+func (a *oidcAuth) verifySession(token string) (sessionClaims, error) {
+	var claims sessionClaims
+
+	payloadB64, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return claims, fmt.Errorf("malformed session cookie")
+	}
+	if !hmac.Equal([]byte(a.sign(payloadB64)), []byte(sig)) {
+		return claims, fmt.Errorf("invalid session signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadB64)
+	if err != nil {
+		return claims, fmt.Errorf("malformed session cookie: %w", err)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("malformed session cookie: %w", err)
+	}
+	if time.Now().Unix() > claims.Exp {
+		return claims, fmt.Errorf("session expired")
+	}
+	return claims, nil
+}
+
+func (a *oidcAuth) sign(payloadB64 string) string {
+	mac := hmac.New(sha256.New, []byte(a.cfg.CookieSecret))
+	mac.Write([]byte(payloadB64))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+// pageCursor is the opaque "after" cursor queryTable hands back for keyset
+// pagination. OrderCols is carried along for forward-compatibility with a
+// future multi-column keyset predicate; today's predicate is rowid-only
+// (see buildPagedQuery), so it's always empty.
+type pageCursor struct {
+	LastRowID int64    `json:"last_rowid"`
+	OrderCols []string `json:"order_cols"`
+}
+
+const (
+	defaultPageLimit = 500
+	maxPageLimit     = 5000
+)
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.URLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return c, nil
+}
+
+// pageParams is what ?after=, ?limit= and ?count= resolve to for a single
+// queryTable call.
+type pageParams struct {
+	cursor     *pageCursor
+	limit      int
+	countExact bool
+}
+
+func parsePageParams(r *http.Request) (pageParams, error) {
+	p := pageParams{limit: defaultPageLimit}
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			return p, fmt.Errorf("invalid limit %q", l)
+		}
+		if n > maxPageLimit {
+			n = maxPageLimit
+		}
+		p.limit = n
+	}
+
+	if after := r.URL.Query().Get("after"); after != "" {
+		c, err := decodeCursor(after)
+		if err != nil {
+			return p, err
+		}
+		p.cursor = &c
+	}
+
+	p.countExact = r.URL.Query().Get("count") == "exact"
+	return p, nil
+}
+
+// nextPageURL returns the URL r's request would need to fetch the next
+// page: same path and query, with "after" replaced by cursor.
+func nextPageURL(r *http.Request, cursor string) string {
+	u := *r.URL
+	q := u.Query()
+	q.Set("after", cursor)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// outputFormat is the negotiated response representation for queryTable.
+type outputFormat string
+
+const (
+	formatHTML  outputFormat = "html"
+	formatJSON  outputFormat = "json" // NDJSON, one row object per line
+	formatCSV   outputFormat = "csv"
+	formatArrow outputFormat = "arrow"
+)
+
+// negotiateFormat honors an explicit ?format= override first, then the
+// Accept header, defaulting to the historical HTML table view.
+func negotiateFormat(r *http.Request) outputFormat {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return formatJSON
+	case "csv":
+		return formatCSV
+	case "arrow":
+		return formatArrow
+	case "html":
+		return formatHTML
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/vnd.apache.arrow.stream"):
+		return formatArrow
+	case strings.Contains(accept, "application/json"):
+		return formatJSON
+	case strings.Contains(accept, "text/csv"):
+		return formatCSV
+	}
+	return formatHTML
+}
+
+// rowIDAlias is the column injectRowID adds to the inner query so the
+// paging wrapper below has something stable to filter and order on, since
+// common.ConstructSQL's output doesn't otherwise expose rowid.
+const rowIDAlias = "__flight2_rowid"
+
+// injectRowID rewrites "SELECT <cols> FROM ..." into
+// "SELECT rowid AS __flight2_rowid, <cols> FROM ...". ok is false when
+// query isn't a simple top-level SELECT, in which case the caller falls
+// back to a plain LIMIT with no keyset predicate.
+func injectRowID(query string) (rewritten string, ok bool) {
+	trimmed := strings.TrimSpace(query)
+	if !strings.HasPrefix(strings.ToUpper(trimmed), "SELECT ") {
+		return query, false
+	}
+	rest := trimmed[len("SELECT "):]
+	return "SELECT rowid AS " + rowIDAlias + ", " + rest, true
+}
+
+// buildPagedQuery wraps baseQuery in a keyset-paginated outer SELECT so a
+// deep page doesn't cost an OFFSET scan over the whole SQLite file. It
+// over-fetches by one row (LIMIT p.limit+1) purely so the caller can tell
+// whether a next page exists without a separate COUNT(*).
+func buildPagedQuery(baseQuery string, p pageParams) (query string, paged bool) {
+	inner, ok := injectRowID(baseQuery)
+	if !ok {
+		return fmt.Sprintf("%s LIMIT %d", strings.TrimRight(strings.TrimSpace(baseQuery), ";"), p.limit+1), false
+	}
+
+	query = fmt.Sprintf("SELECT * FROM (%s) AS page", strings.TrimRight(inner, ";"))
+	if p.cursor != nil {
+		query += fmt.Sprintf(" WHERE %s > %d", rowIDAlias, p.cursor.LastRowID)
+	}
+	query += fmt.Sprintf(" ORDER BY %s LIMIT %d", rowIDAlias, p.limit+1)
+	return query, true
+}
+
+// buildCountQuery wraps the original (unpaginated) query so X-Total-Rows
+// can be computed on request without the pagination LIMIT/WHERE getting in
+// the way.
+func buildCountQuery(baseQuery string) string {
+	return fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS counted", strings.TrimRight(strings.TrimSpace(baseQuery), ";"))
+}
+
+// writeArrowStream encodes rows as a single Arrow IPC stream record batch.
+// Every column comes in as a string since that's what queryTable has
+// already stringified each value to for the HTML/CSV/JSON writers, so the
+// schema is Utf8 across the board rather than round-tripping SQLite's
+// dynamic typing.
+func writeArrowStream(w io.Writer, columns []string, rows [][]string) error {
+	fields := make([]arrow.Field, len(columns))
+	for i, col := range columns {
+		fields[i] = arrow.Field{Name: col, Type: arrow.BinaryTypes.String}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	pool := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(pool, schema)
+	defer builder.Release()
+
+	for _, row := range rows {
+		for i, v := range row {
+			builder.Field(i).(*array.StringBuilder).Append(v)
+		}
+	}
+
+	rec := builder.NewRecord()
+	defer rec.Release()
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema))
+	defer writer.Close()
+
+	return writer.Write(rec)
+}
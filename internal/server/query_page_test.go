@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Type: Unit Test
+func TestCursorRoundTrip(t *testing.T) {
+	c := pageCursor{LastRowID: 42, OrderCols: []string{}}
+	decoded, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if decoded.LastRowID != c.LastRowID {
+		t.Errorf("LastRowID = %d, want %d", decoded.LastRowID, c.LastRowID)
+	}
+}
+
+// Type: Unit Test
+func TestDecodeCursor_Invalid(t *testing.T) {
+	if _, err := decodeCursor("not-valid-base64!!!"); err == nil {
+		t.Error("expected an error for an invalid cursor, got nil")
+	}
+}
+
+// Type: Unit Test
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		accept string
+		want   outputFormat
+	}{
+		{"explicit csv param", "/x/orders?format=csv", "", formatCSV},
+		{"explicit json param wins over accept", "/x/orders?format=json", "text/csv", formatJSON},
+		{"accept json", "/x/orders", "application/json", formatJSON},
+		{"accept arrow", "/x/orders", "application/vnd.apache.arrow.stream", formatArrow},
+		{"accept csv", "/x/orders", "text/csv", formatCSV},
+		{"default html", "/x/orders", "text/html", formatHTML},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			if got := negotiateFormat(r); got != tt.want {
+				t.Errorf("negotiateFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Type: Unit Test
+func TestParsePageParams(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/x/orders?limit=10&count=exact", nil)
+	p, err := parsePageParams(r)
+	if err != nil {
+		t.Fatalf("parsePageParams: %v", err)
+	}
+	if p.limit != 10 || !p.countExact || p.cursor != nil {
+		t.Errorf("got %+v", p)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/x/orders?limit=999999", nil)
+	p, err = parsePageParams(r)
+	if err != nil {
+		t.Fatalf("parsePageParams: %v", err)
+	}
+	if p.limit != maxPageLimit {
+		t.Errorf("limit = %d, want it clamped to %d", p.limit, maxPageLimit)
+	}
+
+	if _, err := parsePageParams(httptest.NewRequest(http.MethodGet, "/x/orders?limit=nope", nil)); err == nil {
+		t.Error("expected an error for a non-numeric limit")
+	}
+}
+
+// Type: Unit Test
+func TestBuildPagedQuery(t *testing.T) {
+	query, paged := buildPagedQuery("SELECT id, name FROM orders", pageParams{limit: 50})
+	if !paged {
+		t.Fatal("expected a simple SELECT to be paginated")
+	}
+	if query == "" {
+		t.Fatal("expected a non-empty query")
+	}
+
+	cursorQuery, paged := buildPagedQuery("SELECT id, name FROM orders", pageParams{limit: 50, cursor: &pageCursor{LastRowID: 7}})
+	if !paged {
+		t.Fatal("expected a simple SELECT to be paginated")
+	}
+	if cursorQuery == query {
+		t.Error("expected the cursor to change the generated query")
+	}
+
+	_, paged = buildPagedQuery("PRAGMA table_info(orders)", pageParams{limit: 50})
+	if paged {
+		t.Error("expected a non-SELECT query to fall back to an unpaginated LIMIT")
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpRange is one byte range parsed from a Range header, resolved against
+// the resource's actual size (so Start/End are always valid offsets, never
+// the "-500" suffix-length shorthand).
+type httpRange struct {
+	Start, End int64 // inclusive, like Content-Range
+}
+
+// length returns the number of bytes the range covers.
+func (hr httpRange) length() int64 {
+	return hr.End - hr.Start + 1
+}
+
+// contentRange renders hr as a Content-Range header value for a resource of
+// the given total size.
+func (hr httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", hr.Start, hr.End, size)
+}
+
+// parseRangeHeader parses a "Range: bytes=..." header value against a
+// resource of the given size, the way net/http.ServeContent does: each
+// comma-separated spec is "first-last", "first-", or "-suffixLength".
+// Ranges that don't overlap size are dropped; for a non-empty header, an
+// empty (nil) result with a nil error means the header asked for ranges
+// that fit the "bytes=" grammar but none overlapped size, which callers
+// should turn into a 416.
+func parseRangeHeader(header string, size int64) ([]httpRange, error) {
+	if header == "" {
+		return nil, nil
+	}
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return nil, fmt.Errorf("range: invalid header %q", header)
+	}
+
+	var ranges []httpRange
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		dash := strings.IndexByte(spec, '-')
+		if dash < 0 {
+			return nil, fmt.Errorf("range: invalid spec %q", spec)
+		}
+		startStr, endStr := strings.TrimSpace(spec[:dash]), strings.TrimSpace(spec[dash+1:])
+
+		var hr httpRange
+		if startStr == "" {
+			// Suffix length: last N bytes.
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("range: invalid suffix length %q", endStr)
+			}
+			if n == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			hr = httpRange{Start: size - n, End: size - 1}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("range: invalid start %q", startStr)
+			}
+			if start >= size {
+				continue
+			}
+			end := size - 1
+			if endStr != "" {
+				e, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || e < start {
+					return nil, fmt.Errorf("range: invalid end %q", endStr)
+				}
+				if e < end {
+					end = e
+				}
+			}
+			hr = httpRange{Start: start, End: end}
+		}
+		ranges = append(ranges, hr)
+	}
+	return ranges, nil
+}
+
+// weakETag builds a weak validator from a size+mtime pair, matching the
+// format net/http's own static file serving uses. It's "weak" because the
+// backends behind dataset_source (rclone VFS over S3/GCS/SFTP/local) only
+// expose second-granularity mtimes, not a byte-exact content hash in the
+// common case.
+func weakETag(size int64, modTimeUnix int64) string {
+	return fmt.Sprintf(`W/"%x-%x"`, modTimeUnix, size)
+}
+
+// ifNoneMatchSatisfied reports whether any entity-tag in the comma-
+// separated If-None-Match header value matches etag, or the header is "*"
+// (which matches any existing representation), per RFC 7232 §3.2 - used to
+// short-circuit handleRaw with 304 Not Modified instead of re-streaming
+// bytes the client's cache already has.
+func ifNoneMatchSatisfied(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if ifNoneMatch == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimSpace(tag) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// ifRangeSatisfied reports whether an If-Range header value still matches
+// the current representation, identified by etag (the ETag header value
+// handleRaw already computed) and modTime. If-Range may carry either an
+// ETag or an HTTP-date, per RFC 7233 §3.2.
+func ifRangeSatisfied(ifRange, etag string, modTime time.Time) bool {
+	if ifRange == etag {
+		return true
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(t)
+}
+
+// multipartBoundary returns a fresh boundary for a multipart/byteranges
+// response, generated up front so it's known before headers are written.
+func multipartBoundary() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// rangesOverlap reports whether two or more ranges share any bytes, used to
+// decide whether a multi-range response still makes sense to send as
+// multipart/byteranges.
+func rangesOverlap(ranges []httpRange) bool {
+	for i := range ranges {
+		for j := i + 1; j < len(ranges); j++ {
+			if ranges[i].Start <= ranges[j].End && ranges[j].Start <= ranges[i].End {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeMultipartRangesWithBoundary writes each range in ranges as one part
+// of a multipart/byteranges response under the given boundary, reading
+// each part's bytes from src via rangeReader. The boundary is supplied by
+// the caller (rather than left to multipart.Writer to generate) so it can
+// go into the Content-Type header before any body bytes - and therefore
+// before WriteHeader - are written.
+func writeMultipartRangesWithBoundary(w io.Writer, boundary string, ranges []httpRange, size int64, contentType string, rangeReader func(start, length int64) (io.ReadCloser, error)) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, hr := range ranges {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Range", hr.contentRange(size))
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		rc, err := rangeReader(hr.Start, hr.length())
+		if err != nil {
+			return err
+		}
+		_, copyErr := io.Copy(part, rc)
+		rc.Close()
+		if copyErr != nil {
+			return copyErr
+		}
+	}
+	return mw.Close()
+}
+
+// multipartContentType returns the Content-Type value for a
+// multipart/byteranges response using boundary, mirroring how mime.FormatMediaType
+// quotes the boundary parameter.
+func multipartContentType(boundary string) string {
+	return mime.FormatMediaType("multipart/byteranges", map[string]string{"boundary": boundary})
+}
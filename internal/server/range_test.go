@@ -0,0 +1,59 @@
+package server
+
+import "testing"
+
+// Type: Unit Test
+func TestParseRangeHeader(t *testing.T) {
+	const size = 1000
+
+	tests := []struct {
+		name   string
+		header string
+		want   []httpRange
+		hasErr bool
+	}{
+		{"empty header", "", nil, false},
+		{"simple range", "bytes=0-499", []httpRange{{0, 499}}, false},
+		{"open-ended range", "bytes=500-", []httpRange{{500, 999}}, false},
+		{"suffix length", "bytes=-100", []httpRange{{900, 999}}, false},
+		{"suffix longer than size clamps to size", "bytes=-5000", []httpRange{{0, 999}}, false},
+		{"end clamps to size-1", "bytes=900-5000", []httpRange{{900, 999}}, false},
+		{"start beyond size is dropped", "bytes=5000-6000", nil, false},
+		{"multiple ranges", "bytes=0-99,200-299", []httpRange{{0, 99}, {200, 299}}, false},
+		{"missing bytes= prefix", "0-499", nil, true},
+		{"garbage spec", "bytes=abc", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRangeHeader(tt.header, size)
+			if tt.hasErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRangeHeader: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d ranges, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("range %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// Type: Unit Test
+func TestRangesOverlap(t *testing.T) {
+	if rangesOverlap([]httpRange{{0, 99}, {200, 299}}) {
+		t.Error("non-overlapping ranges reported as overlapping")
+	}
+	if !rangesOverlap([]httpRange{{0, 150}, {100, 299}}) {
+		t.Error("overlapping ranges reported as non-overlapping")
+	}
+}
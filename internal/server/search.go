@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight2/internal/index"
+)
+
+// searchResultLimit bounds how many rows handleSearch renders per request -
+// a query against a large, frequently-matching index (e.g. ext=.log) still
+// returns promptly instead of building an unbounded HTML table.
+const searchResultLimit = 500
+
+// handleSearch answers GET /app/search/{alias}?q=...&glob=...&ext=...&min=...&modified=...
+// by querying the alias's crawl index and rendering the matches with the
+// same table layout handleBrowse uses for a directory listing.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	if s.index == nil {
+		http.Error(w, "Search index is not available", http.StatusNotImplemented)
+		return
+	}
+	if _, err := s.secrets.GetCredentials(alias); err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	qs := r.URL.Query()
+	q := index.Query{
+		Q:    qs.Get("q"),
+		Glob: qs.Get("glob"),
+		Ext:  qs.Get("ext"),
+	}
+	if v := qs.Get("min"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			q.MinSize = n
+		}
+	}
+	if v := qs.Get("modified"); v != "" {
+		if t, err := time.Parse("2006-01-02", v); err == nil {
+			q.ModifiedSince = t
+		}
+	}
+
+	rows, err := s.index.Search(alias, q, searchResultLimit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Search failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	status := s.index.Status(alias)
+
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Search - %s</title>
+<link rel="stylesheet" href="/cssjs/default.css">
+</head>
+<body>
+<div class="container">
+<h2>🔎 Search: %s</h2>
+`, alias, alias)
+
+	fmt.Fprintf(w, `
+<form method="get" style="margin-bottom:1rem;">
+<input type="text" name="q" placeholder="Name or path contains..." value="%s">
+<input type="text" name="glob" placeholder="Glob, e.g. *.csv" value="%s">
+<input type="text" name="ext" placeholder="Extension" value="%s">
+<input type="number" name="min" placeholder="Min size (bytes)" value="%s">
+<input type="date" name="modified" value="%s">
+<button type="submit" class="btn btn-primary">Search</button>
+</form>
+<form method="post" action="/app/index/reindex/%s" style="display:inline-block; margin-bottom:1rem;">
+<button type="submit" class="btn">🔄 Reindex</button>
+</form>
+<p style="color:var(--text-muted); font-size:0.9rem;">Index status: %s (%d files indexed%s)</p>
+`, html.EscapeString(q.Q), html.EscapeString(q.Glob), html.EscapeString(q.Ext), html.EscapeString(qs.Get("min")), html.EscapeString(qs.Get("modified")),
+		alias, status.State, status.FilesIndexed, indexErrorSuffix(status))
+
+	cols := []string{"Type", "Name", "Path", "Size", "Modified", "Actions"}
+	s.tableWriter.StartHTMLTable(w, cols, "")
+	for _, row := range rows {
+		icon := "<span class='badge badge-file'>📄</span>"
+		ext := strings.ToLower(path.Ext(row.Name))
+		queryAction := ""
+		if ext == ".db" || ext == ".sqlite" || ext == ".sqlite3" || ext == ".csv" || ext == ".xlsx" || ext == ".json" {
+			queryAction = fmt.Sprintf("<a href='/%s@%s/' class='btn btn-primary'>📊 Query</a>", alias, row.Path)
+		}
+		actions := fmt.Sprintf("%s <a href='/app/view/%s/%s' target='_blank' class='btn btn-view'>👁️ View</a>", queryAction, alias, row.Path)
+		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			icon, row.Name, row.Path, formatSize(row.Size), row.Mtime.Format("2006-01-02 15:04:05"), actions)
+	}
+	s.tableWriter.EndHTMLTable(w)
+
+	if len(rows) == searchResultLimit {
+		fmt.Fprintf(w, `<p style="color:var(--text-muted);">Showing the first %d matches - narrow your query for more.</p>`, searchResultLimit)
+	}
+
+	fmt.Fprint(w, `</div></body></html>`)
+}
+
+// indexErrorSuffix renders status' error, if any, as a trailing
+// " - <message>" clause for the status line in handleSearch.
+func indexErrorSuffix(status index.CrawlStatus) string {
+	if status.Error == "" {
+		return ""
+	}
+	return " - " + html.EscapeString(status.Error)
+}
+
+// handleIndexStatus answers GET /app/index/status/{alias} with the
+// alias's current CrawlStatus as JSON, for the browse UI to poll while a
+// Reindex is in progress.
+func (s *Server) handleIndexStatus(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	if s.index == nil {
+		http.Error(w, "Search index is not available", http.StatusNotImplemented)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.index.Status(alias))
+}
+
+// handleReindex answers POST /app/index/reindex/{alias} by starting a
+// background crawl (if one isn't already running) and redirecting back to
+// the search page, the same request/redirect shape handleMkdir uses for
+// its form.
+func (s *Server) handleReindex(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	if s.index == nil {
+		http.Error(w, "Search index is not available", http.StatusNotImplemented)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	s.index.TriggerCrawl(context.Background(), alias, creds)
+	http.Redirect(w, r, "/app/search/"+alias, http.StatusSeeOther)
+}
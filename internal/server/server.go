@@ -3,28 +3,38 @@ package server
 import (
 	"context"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"flight2/internal/apitoken"
+	"flight2/internal/cors"
 	"flight2/internal/dataset"
 	"flight2/internal/dataset_source"
+	"flight2/internal/index"
+	"flight2/internal/script"
 	"flight2/internal/secrets"
+	"flight2/internal/source"
+	"flight2/internal/thumbs"
 
 	"github.com/darianmavgo/banquet"
 	"github.com/darianmavgo/sqliter/common"
 	"github.com/darianmavgo/sqliter/sqliter"
 	_ "github.com/mattn/go-sqlite3"
+	"golang.org/x/net/webdav"
 
 	// Register all rclone backends
 	_ "github.com/rclone/rclone/backend/all"
@@ -41,6 +51,35 @@ type Server struct {
 	localOnly     bool
 	defaultDB     string
 	history       *RequestHistory
+	scripts       *script.Registry
+	thumbs        *thumbs.Cache
+	index         *index.Index
+	apiTokens     *apitoken.Store
+	sniffCache    *sniffCache
+
+	// oidcCfg is set when the operator configured --auth=oidc. oidcAuth is
+	// the built middleware, or nil if construction failed - Router fails
+	// closed in that case rather than serving /app/* unauthenticated.
+	oidcCfg  *OIDCConfig
+	oidcAuth *oidcAuth
+
+	// protectedPaths holds glob patterns that handleBanquet checks before
+	// ever touching dataManager - see AddProtectedPath.
+	protectedMu    sync.RWMutex
+	protectedPaths []string
+
+	enableDebugEndpoints bool
+	debugUnredacted      bool
+
+	// uploadLimitsMu guards uploadLimits, a per-alias override of
+	// defaultMaxUploadSize - see SetUploadLimit.
+	uploadLimitsMu sync.RWMutex
+	uploadLimits   map[string]int64
+
+	// cors configures cross-origin access to /api/v1/* and /dav/* for
+	// browser SPAs. nil (the default) leaves those endpoints same-origin
+	// only, same as the rest of the app.
+	cors *cors.Config
 }
 
 type RequestHistory struct {
@@ -83,31 +122,228 @@ func (h *RequestHistory) GetRecent() []string {
 	return res
 }
 
-// NewServer creates a new Server.
-func NewServer(dm *dataset.Manager, ss *secrets.Service, serveFolder string, verbose bool, autoSelectTb0 bool, localOnly bool, defaultDB string) *Server {
+// NewServer creates a new Server. oidcCfg is nil unless the operator passed
+// --auth=oidc; it and localOnly are mutually exclusive, since both exist to
+// answer the same question ("who's allowed to hit /app/*") in different
+// ways.
+//
+// secretsDBPath, safeMode, enableDebugEndpoints and debugUnredacted seed the
+// protected-path list and gate /app/debug/env - see AddProtectedPath and
+// handleDebugEnv.
+func NewServer(dm *dataset.Manager, ss *secrets.Service, serveFolder string, verbose bool, autoSelectTb0 bool, localOnly bool, defaultDB string, oidcCfg *OIDCConfig, secretsDBPath string, safeMode bool, enableDebugEndpoints bool, debugUnredacted bool, indexIntervalSeconds int, corsCfg *cors.Config) *Server {
+	if localOnly && oidcCfg != nil {
+		log.Fatal("server: local_only and --auth=oidc are mutually exclusive")
+	}
+
 	t := sqliter.GetDefaultTemplates()
 	sqliterCfg := sqliter.DefaultConfig()
 	sqliterCfg.Verbose = verbose
 	srv := &Server{
-		dataManager:   dm,
-		secrets:       ss,
-		tableWriter:   sqliter.NewTableWriter(t, sqliterCfg),
-		serveFolder:   serveFolder,
-		verbose:       verbose,
-		autoSelectTb0: autoSelectTb0,
-		localOnly:     localOnly,
-		defaultDB:     defaultDB,
-		history:       NewRequestHistory(20),
+		dataManager:          dm,
+		secrets:              ss,
+		tableWriter:          sqliter.NewTableWriter(t, sqliterCfg),
+		serveFolder:          serveFolder,
+		verbose:              verbose,
+		autoSelectTb0:        autoSelectTb0,
+		localOnly:            localOnly,
+		defaultDB:            defaultDB,
+		history:              NewRequestHistory(20),
+		oidcCfg:              oidcCfg,
+		enableDebugEndpoints: enableDebugEndpoints,
+		debugUnredacted:      debugUnredacted,
+		uploadLimits:         make(map[string]int64),
+		cors:                 corsCfg,
+		sniffCache:           newSniffCache(),
+	}
+
+	// Seed the protected-path list with the things a catch-all handler
+	// should never let a request read: the credentials store itself, the
+	// "secrets" table name it's kept under, and (with --safe-mode)
+	// sqlite_master, which would otherwise let a request enumerate table
+	// names it has no business seeing.
+	if secretsDBPath != "" {
+		srv.AddProtectedPath(secretsDBPath)
+		// handleBanquet resolves local paths relative to serveFolder, so a
+		// secretsDBPath like "secrets.db" is actually reachable as
+		// "<serveFolder>/secrets.db" - protect that form too.
+		if serveFolder != "" {
+			srv.AddProtectedPath(filepath.Join(serveFolder, secretsDBPath))
+		}
+	}
+	srv.AddProtectedPath("secrets")
+	if safeMode {
+		srv.AddProtectedPath("sqlite_master")
+	}
+
+	if oidcCfg != nil {
+		auth, err := newOIDCAuth(context.Background(), *oidcCfg)
+		if err != nil {
+			log.Printf("OIDC auth disabled, failing closed on /app/*: %v", err)
+		} else {
+			srv.oidcAuth = auth
+		}
 	}
 	// Log a warning if the configured serveFolder does not exist
 	if serveFolder != "" {
 		if _, err := os.Stat(serveFolder); err != nil {
 			log.Printf("ServeFolder %s does not exist: %v", serveFolder, err)
 		}
+
+		// serveFolder/scripts/*.lua turns Flight2 into a scriptable data
+		// platform: each script becomes a /app/script/{name} endpoint
+		// without a restart or recompile. No scripts directory just means
+		// the feature is unused, not an error.
+		scriptsDir := filepath.Join(serveFolder, "scripts")
+		if info, err := os.Stat(scriptsDir); err == nil && info.IsDir() {
+			reg, err := script.NewRegistry(scriptsDir, script.Deps{Secrets: ss, DataManager: dm}, verbose)
+			if err != nil {
+				log.Printf("Failed to load scripts from %s: %v", scriptsDir, err)
+			} else {
+				srv.scripts = reg
+			}
+		}
+
+		// The scripts directory is loaded into the Lua registry above; it
+		// has no business also being servable as a plain file/table through
+		// handleBanquet.
+		srv.AddProtectedPath(filepath.Join(scriptsDir, "*"))
+	}
+
+	// Thumbnails live under the dataset Manager's cache dir alongside the
+	// memory/disk cache tiers it already manages; a failure here (e.g. a
+	// read-only cache dir) just means listings fall back to the generic
+	// file badge instead of inline previews.
+	if dm != nil {
+		cache, err := thumbs.NewCache(filepath.Join(dm.CacheDir(), "thumbs"), 4, 0)
+		if err != nil {
+			log.Printf("Thumbnails disabled: %v", err)
+		} else {
+			srv.thumbs = cache
+		}
+	}
+
+	// The search index lives under the dataset Manager's cache dir like
+	// thumbnails; a failure to open it just means handleSearch answers
+	// with 501 rather than blocking startup.
+	if dm != nil {
+		idx, err := index.NewIndex(filepath.Join(dm.CacheDir(), "index.db"), 4)
+		if err != nil {
+			log.Printf("Search index disabled: %v", err)
+		} else {
+			srv.index = idx
+			if indexIntervalSeconds > 0 {
+				go srv.runPeriodicReindex(time.Duration(indexIntervalSeconds) * time.Second)
+			}
+		}
+	}
+
+	// The API token store lives under the dataset Manager's cache dir like
+	// thumbnails/index; a failure to open it just means /api/v1/* and
+	// /app/tokens/manage answer with 503 rather than blocking startup.
+	if dm != nil {
+		store, err := apitoken.NewStore(filepath.Join(dm.CacheDir(), "apitokens.db"))
+		if err != nil {
+			log.Printf("API tokens disabled: %v", err)
+		} else {
+			srv.apiTokens = store
+		}
 	}
 	return srv
 }
 
+// runPeriodicReindex triggers a crawl of every configured alias once at
+// startup and again every interval, so the search index stays roughly
+// current even if nobody clicks "Reindex". A crawl already in progress for
+// an alias when the tick fires is left alone - index.Index.TriggerCrawl
+// already treats that as a no-op.
+func (s *Server) runPeriodicReindex(interval time.Duration) {
+	reindexAll := func() {
+		aliases, err := s.secrets.ListAliases()
+		if err != nil {
+			log.Printf("periodic reindex: failed to list aliases: %v", err)
+			return
+		}
+		for _, alias := range aliases {
+			creds, err := s.secrets.GetCredentials(alias)
+			if err != nil {
+				continue
+			}
+			s.index.TriggerCrawl(context.Background(), alias, creds)
+		}
+	}
+
+	reindexAll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reindexAll()
+	}
+}
+
+// AddProtectedPath registers a glob pattern (matched with path.Match) that
+// handleBanquet refuses to serve: any request whose resolved sourcePath or
+// bq.Table matches gets a 403 before dataManager is ever touched. Patterns
+// ending in "/*" also block the directory itself and anything nested
+// beneath it, since path.Match only matches one path segment at a time.
+// The pattern is lowercased before storing - see isProtectedPath.
+func (s *Server) AddProtectedPath(pattern string) {
+	s.protectedMu.Lock()
+	defer s.protectedMu.Unlock()
+	s.protectedPaths = append(s.protectedPaths, strings.ToLower(pattern))
+}
+
+// isProtectedPath reports whether any candidate (sourcePath, table name,
+// ...) matches a registered protected-path pattern. Candidates are
+// lowercased before matching, same as the patterns AddProtectedPath stores:
+// a bq.Table candidate reaches SQLite via an unquoted identifier, which
+// resolves case-insensitively, so "SECRETS"/"Secrets" must be caught the
+// same as "secrets" or the check is trivially bypassed.
+func (s *Server) isProtectedPath(candidates ...string) bool {
+	s.protectedMu.RLock()
+	defer s.protectedMu.RUnlock()
+
+	for _, pattern := range s.protectedPaths {
+		dirPrefix := strings.TrimSuffix(pattern, "*")
+		for _, candidate := range candidates {
+			if candidate == "" {
+				continue
+			}
+			c := strings.ToLower(candidate)
+			// A malformed pattern (path.ErrBadPattern) must still be
+			// treated as blocking on an exact match rather than silently
+			// never matching, or an unusual filename defeats protection
+			// entirely.
+			if ok, err := path.Match(pattern, c); ok || (err != nil && c == pattern) {
+				return true
+			}
+			if strings.HasSuffix(pattern, "/*") && (c == strings.TrimSuffix(dirPrefix, "/") || strings.HasPrefix(c, dirPrefix)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close releases resources Server opened outside of NewServer's callers'
+// control: the search index, the API token store and the script
+// registry's file watcher.
+func (s *Server) Close() error {
+	if s.index != nil {
+		if err := s.index.Close(); err != nil {
+			return err
+		}
+	}
+	if s.apiTokens != nil {
+		if err := s.apiTokens.Close(); err != nil {
+			return err
+		}
+	}
+	if s.scripts != nil {
+		return s.scripts.Close()
+	}
+	return nil
+}
+
 func (s *Server) log(format string, args ...interface{}) {
 	if s.verbose {
 		log.Printf(format, args...)
@@ -116,21 +352,83 @@ func (s *Server) log(format string, args ...interface{}) {
 
 func (s *Server) Router() http.Handler {
 	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
 	mux.HandleFunc("GET /app/debug/env", s.handleDebugEnv)
 	mux.HandleFunc("GET /app/credentials/manage", s.handleIndex)
 	mux.HandleFunc("POST /app/credentials/manage", s.handleCreateCredential)
 	mux.HandleFunc("POST /app/credentials/delete", s.handleDeleteCredential)
 	mux.HandleFunc("GET /app/browse/{alias}/{path...}", s.handleBrowse)
 	mux.HandleFunc("GET /app/view/{alias}/{path...}", s.handleView)
+	mux.HandleFunc("GET /app/raw/{alias}/{path...}", s.handleRaw)
+	mux.HandleFunc("GET /app/thumb/{alias}/{path...}", s.handleThumb)
+	mux.HandleFunc("POST /app/upload/{alias}/{path...}", s.handleUpload)
+	mux.HandleFunc("PUT /app/upload-chunk/{alias}/{path...}", s.handleUploadChunk)
+	mux.HandleFunc("POST /app/mkdir/{alias}/{path...}", s.handleMkdir)
+	mux.HandleFunc("POST /app/delete/{alias}/{path...}", s.handleDelete)
+	mux.HandleFunc("GET /app/archive/{alias}/{path...}", s.handleArchive)
+	mux.HandleFunc("POST /app/archive/{alias}/{path...}", s.handleArchive)
+	mux.HandleFunc("GET /app/search/{alias}", s.handleSearch)
+	mux.HandleFunc("GET /app/index/status/{alias}", s.handleIndexStatus)
+	mux.HandleFunc("POST /app/index/reindex/{alias}", s.handleReindex)
 	mux.HandleFunc("GET /app/test/banquet/{path...}", s.handleBanquetTestDB)
 	mux.HandleFunc("/app/credentials", s.handleCredentials)
+	mux.HandleFunc("GET /app/credentials/unwrap/{token}", s.handleUnwrapCredentials)
+	mux.HandleFunc("GET /app/tokens/manage", s.handleTokensIndex)
+	mux.HandleFunc("POST /app/tokens/manage", s.handleTokensIndex)
+	mux.HandleFunc("POST /app/tokens/revoke", s.handleTokensRevoke)
+	mux.HandleFunc("GET /api/v1/remotes", s.handleAPIRemotes)
+	mux.HandleFunc("GET /api/v1/browse/{alias}/{path...}", s.handleAPIBrowse)
+	mux.HandleFunc("GET /api/v1/view/{alias}/{path...}", s.handleAPIView)
+	mux.HandleFunc("POST /v1/transit/encrypt/{name}", s.handleTransitEncrypt)
+	mux.HandleFunc("POST /v1/transit/decrypt/{name}", s.handleTransitDecrypt)
+	mux.HandleFunc("POST /v1/transit/rewrap/{name}", s.handleTransitRewrap)
+	mux.HandleFunc("POST /v1/transit/keys/{name}/rotate", s.handleTransitRotate)
+	mux.HandleFunc("POST /v1/transit/keys/{name}/config", s.handleTransitConfig)
+	mux.HandleFunc("POST /datasets/jobs", s.handleSubmitJob)
+	mux.HandleFunc("GET /datasets/jobs/{id}", s.handleGetJob)
+	mux.HandleFunc("GET /datasets/jobs/{id}/events", s.handleJobEvents)
+	mux.HandleFunc("GET /datasets/overrides/{alias}", s.handleGetImportOverrides)
+	mux.HandleFunc("POST /datasets/overrides/{alias}", s.handleSetImportOverrides)
+	mux.HandleFunc("/app/script/{name}", s.handleScript)
 	mux.HandleFunc("/app/", s.handleAppIndex)
+	mux.Handle("/dav/", &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: newAggregateFS(s.secrets, s.isProtectedPath),
+		LockSystem: webdav.NewMemLS(),
+	})
 	mux.HandleFunc("/", s.handleBanquet)
 
-	if s.localOnly {
-		return s.localOnlyMiddleware(mux)
+	// apiTokenMiddleware guards /api/v1/* and /dav/* with its own
+	// bearer-token scheme (bridged from HTTP Basic for WebDAV clients) and
+	// passes every other path straight through, so it sits inside whichever
+	// of localOnlyMiddleware/oidcAuth.middleware applies to the rest of the
+	// app rather than replacing them.
+	guarded := s.apiTokenMiddleware(mux)
+
+	var handler http.Handler
+	switch {
+	case s.localOnly:
+		handler = s.localOnlyMiddleware(guarded)
+	case s.oidcCfg != nil:
+		if s.oidcAuth == nil {
+			handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "authentication is misconfigured", http.StatusServiceUnavailable)
+			})
+		} else {
+			handler = s.oidcAuth.middleware(guarded)
+		}
+	default:
+		handler = guarded
+	}
+
+	// cors runs outermost so a preflight OPTIONS request - which carries
+	// no auth - gets answered before it would otherwise be rejected by
+	// localOnlyMiddleware/oidcAuth.middleware/apiTokenMiddleware.
+	if s.cors != nil {
+		return s.cors.Middleware(handler)
 	}
-	return mux
+	return handler
 }
 
 func (s *Server) handleAppIndex(w http.ResponseWriter, r *http.Request) {
@@ -162,15 +460,44 @@ func (s *Server) localOnlyMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// handleDebugEnv shows environment variables sorted.
-// SECURITY WARNING: This endpoint exposes all environment variables, which may contain sensitive secrets.
-// It should only be enabled in trusted environments or for debugging purposes.
+// debugRedactSubstrings are matched case-insensitively against each env
+// var's name; a hit masks its value unless --debug-unredacted was passed.
+var debugRedactSubstrings = []string{"SECRET", "TOKEN", "PASSWORD", "KEY"}
+
+// redactEnvLine masks the value half of a "NAME=value" env entry when NAME
+// contains one of debugRedactSubstrings, unless unredacted is set.
+func redactEnvLine(line string, unredacted bool) string {
+	if unredacted {
+		return line
+	}
+	name, _, ok := strings.Cut(line, "=")
+	if !ok {
+		return line
+	}
+	upper := strings.ToUpper(name)
+	for _, substr := range debugRedactSubstrings {
+		if strings.Contains(upper, substr) {
+			return name + "=***REDACTED***"
+		}
+	}
+	return line
+}
+
+// handleDebugEnv shows environment variables sorted, gated behind
+// --enable-debug-endpoints since it otherwise hands a request SECRETS_KEY,
+// AWS_SECRET_ACCESS_KEY, and anything else the process was started with.
+// Even when enabled, values are redacted by name unless --debug-unredacted
+// is also passed.
 func (s *Server) handleDebugEnv(w http.ResponseWriter, r *http.Request) {
+	if !s.enableDebugEndpoints {
+		http.NotFound(w, r)
+		return
+	}
 	env := os.Environ()
 	sort.Strings(env)
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	for _, e := range env {
-		fmt.Fprintln(w, e)
+		fmt.Fprintln(w, redactEnvLine(e, s.debugUnredacted))
 	}
 }
 
@@ -203,10 +530,408 @@ func (s *Server) handleCredentials(w http.ResponseWriter, r *http.Request) {
 	}
 	s.log("Stored credentials with alias: %s", alias)
 
+	if wrapTTL := r.URL.Query().Get("wrap_ttl"); wrapTTL != "" {
+		ttl, err := time.ParseDuration(wrapTTL)
+		if err != nil {
+			http.Error(w, "Invalid wrap_ttl", http.StatusBadRequest)
+			return
+		}
+
+		token, err := s.secrets.WrapCredentials(alias, ttl)
+		if err != nil {
+			log.Printf("Error wrapping credentials for alias %s: %v", alias, err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.log("Wrapped alias %s behind a single-use token (ttl %s)", alias, wrapTTL)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"wrap_token": token, "ttl": wrapTTL})
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"alias": alias})
 }
 
+// handleUnwrapCredentials exchanges a response-wrapping token for the
+// alias it was minted for. The token is consumed in the process: a second
+// call with the same token is rejected the same way an expired one is.
+func (s *Server) handleUnwrapCredentials(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	s.log("Incoming credentials unwrap request from %s", r.RemoteAddr)
+
+	alias, err := s.secrets.UnwrapCredentials(token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"alias": alias})
+}
+
+// handleTransitEncrypt encrypts the request body's "plaintext" field under
+// the named transit key, generating the key on first use, and returns the
+// Vault-style "vault:v<version>:<base64>" ciphertext.
+func (s *Server) handleTransitEncrypt(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming transit encrypt request for key %q from %s", name, r.RemoteAddr)
+
+	var req struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ciphertext, err := s.secrets.TransitEncrypt(name, []byte(req.Plaintext))
+	if err != nil {
+		log.Printf("Error encrypting with transit key %q: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"ciphertext": ciphertext})
+}
+
+// handleTransitDecrypt decrypts the request body's "ciphertext" field
+// under the named transit key, routing to whichever version it was
+// encrypted under.
+func (s *Server) handleTransitDecrypt(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming transit decrypt request for key %q from %s", name, r.RemoteAddr)
+
+	var req struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	plaintext, err := s.secrets.TransitDecrypt(name, req.Ciphertext)
+	if err != nil {
+		log.Printf("Error decrypting with transit key %q: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"plaintext": string(plaintext)})
+}
+
+// handleTransitRewrap re-encrypts the request body's "ciphertext" field
+// under the named key's current version without exposing the plaintext.
+func (s *Server) handleTransitRewrap(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming transit rewrap request for key %q from %s", name, r.RemoteAddr)
+
+	var req struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	ciphertext, err := s.secrets.TransitRewrap(name, req.Ciphertext)
+	if err != nil {
+		log.Printf("Error rewrapping with transit key %q: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"ciphertext": ciphertext})
+}
+
+// handleTransitRotate adds a new version to the named transit key and
+// returns it. Existing ciphertexts keep decrypting against their
+// original version until rewrapped.
+func (s *Server) handleTransitRotate(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming transit rotate request for key %q from %s", name, r.RemoteAddr)
+
+	version, err := s.secrets.TransitRotate(name)
+	if err != nil {
+		log.Printf("Error rotating transit key %q: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"latest_version": version})
+}
+
+// handleTransitConfig updates the named transit key's configuration. The
+// only supported field today is "min_decryption_version", mirroring
+// Vault transit's key config endpoint.
+func (s *Server) handleTransitConfig(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming transit config request for key %q from %s", name, r.RemoteAddr)
+
+	var req struct {
+		MinDecryptionVersion int `json:"min_decryption_version"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.secrets.TransitSetMinDecryptVersion(name, req.MinDecryptionVersion); err != nil {
+		log.Printf("Error configuring transit key %q: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveJobRef looks up alias's stored credentials (or treats sourcePath
+// as a local path when alias is empty) and registers a source profile for
+// it, the same way handleBanquet does before calling GetSQLiteDB.
+func (s *Server) resolveJobRef(alias, sourcePath string) (source.SourceRef, error) {
+	var creds map[string]interface{}
+	if alias != "" {
+		c, err := s.secrets.GetCredentials(alias)
+		if err != nil {
+			return source.SourceRef{}, fmt.Errorf("error retrieving credentials for alias %s: %w", alias, err)
+		}
+		creds = c
+	} else {
+		creds = map[string]interface{}{"type": "local"}
+	}
+
+	profileID := alias
+	if profileID == "" {
+		profileID = "__local__"
+	}
+	source.RegisterProfile(profileID, creds)
+	backend, _ := creds["type"].(string)
+	return source.SourceRef{Backend: backend, Path: sourcePath, ProfileID: profileID}, nil
+}
+
+// handleSubmitJob enqueues a background dataset conversion and returns its
+// job id immediately. The job can be polled via handleGetJob or streamed
+// via handleJobEvents.
+func (s *Server) handleSubmitJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Alias      string `json:"alias"`
+		SourcePath string `json:"source_path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.SourcePath == "" {
+		http.Error(w, "source_path is required", http.StatusBadRequest)
+		return
+	}
+
+	ref, err := s.resolveJobRef(req.Alias, req.SourcePath)
+	if err != nil {
+		s.log("Error resolving job source: %v", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	id, err := s.dataManager.SubmitJob(r.Context(), ref, req.Alias)
+	if err != nil {
+		log.Printf("Error submitting job: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": id})
+}
+
+// handleGetJob returns the current state of a job submitted via
+// handleSubmitJob.
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	job, err := s.dataManager.GetJob(id)
+	if err != nil {
+		if err == dataset.ErrJobNotFound {
+			http.Error(w, "job not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Error fetching job %q: %v", id, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// handleJobEvents streams a job's progress as Server-Sent Events, polling
+// the persistent job store until it reaches a terminal status. There's no
+// push-based notification path for jobs, so this is a simple poll loop
+// rather than an in-memory broadcaster.
+func (s *Server) handleJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.dataManager.GetJob(id)
+		if err != nil {
+			if err == dataset.ErrJobNotFound {
+				http.Error(w, "job not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("Error fetching job %q for SSE: %v", id, err)
+			return
+		}
+
+		data, err := json.Marshal(job)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: progress\ndata: %s\n\n", data)
+		flusher.Flush()
+
+		if job.Status == dataset.JobSucceeded || job.Status == dataset.JobFailed {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleScript dispatches to a Lua script loaded from serveFolder/scripts,
+// the pluggable endpoint layer that lets an operator add a custom data
+// view or transform without recompiling Flight2.
+func (s *Server) handleScript(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	s.log("Incoming script request for %q from %s", name, r.RemoteAddr)
+
+	if s.scripts == nil {
+		http.Error(w, "No scripts configured", http.StatusNotFound)
+		return
+	}
+
+	req := script.Request{
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Alias:      r.URL.Query().Get("alias"),
+		RemoteAddr: r.RemoteAddr,
+	}
+
+	resp, err := s.scripts.Handle(r.Context(), name, req)
+	if err != nil {
+		log.Printf("Script %q failed: %v", name, err)
+		http.Error(w, fmt.Sprintf("Script error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
+
+// handleGetImportOverrides returns the per-alias ingestion overrides
+// (delimiter, header, column types) set via handleSetImportOverrides, or
+// an empty object if none have been set.
+func (s *Server) handleGetImportOverrides(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+
+	overrides, _, err := s.dataManager.GetImportOverrides(alias)
+	if err != nil {
+		log.Printf("Error fetching import overrides for %q: %v", alias, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overrides)
+}
+
+// handleSetImportOverrides lets a user correct a bad format/schema guess
+// for alias - a wrong delimiter, a headerless CSV, or a column that should
+// have been INTEGER/REAL - without re-uploading the source. Overrides take
+// effect the next time alias is ingested (cached artifacts aren't
+// retroactively retyped).
+func (s *Server) handleSetImportOverrides(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+
+	var overrides dataset.ImportOverrides
+	if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.dataManager.SetImportOverrides(alias, overrides); err != nil {
+		log.Printf("Error setting import overrides for %q: %v", alias, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHealthz reports whether the process is alive. It does no I/O, so a
+// load balancer can use it to detect a hung or deadlocked process
+// independent of downstream dependencies - use /readyz for those.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the server can actually serve traffic: the
+// secrets backend responds, the cache directory accepts writes, and at
+// least one source backend is registered. Any failure returns 503 with the
+// failing check named, so an orchestrator logs why a pod was pulled from
+// rotation instead of just that it was.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{}
+
+	if _, err := s.secrets.ListAliases(); err != nil {
+		checks["secrets"] = err.Error()
+	}
+
+	probe := filepath.Join(s.dataManager.CacheDir(), ".readyz-probe")
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		checks["cache_dir"] = err.Error()
+	} else {
+		os.Remove(probe)
+	}
+
+	if source.ProfileCount() == 0 {
+		checks["backends"] = "no backends registered"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(checks) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "not ready", "checks": checks})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
+
 // handleBanquet handles the banquet URL requests.
 func (s *Server) handleBanquet(w http.ResponseWriter, r *http.Request) {
 	s.log("Incoming request: %s %s from %s", r.Method, r.URL.String(), r.RemoteAddr)
@@ -289,6 +1014,12 @@ func (s *Server) handleBanquet(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if s.isProtectedPath(sourcePath, bq.Table) {
+		s.log("Blocked protected path: sourcePath=%q table=%q", sourcePath, bq.Table)
+		http.Error(w, "Access denied", http.StatusForbidden)
+		return
+	}
+
 	// Extract alias from userinfo
 	alias := ""
 	if bq.User != nil {
@@ -297,6 +1028,15 @@ func (s *Server) handleBanquet(w http.ResponseWriter, r *http.Request) {
 
 	var creds map[string]interface{}
 	if alias != "" {
+		// Accept a response-wrapping token in place of a real alias, so a
+		// link like /{wrap_token}@url/... can hand off access once without
+		// ever carrying the underlying alias. A plain alias isn't a valid
+		// wrap token, so this just falls through to the lookup below.
+		if resolved, err := s.secrets.UnwrapCredentials(alias); err == nil {
+			s.log("Resolved wrap token to alias: %s", resolved)
+			alias = resolved
+		}
+
 		s.log("Looking up credentials for alias: %s", alias)
 		c, err := s.secrets.GetCredentials(alias)
 		if err != nil {
@@ -337,7 +1077,21 @@ func (s *Server) handleBanquet(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	dbPath, err := s.dataManager.GetSQLiteDB(r.Context(), sourcePath, creds, alias)
+	profileID := alias
+	if profileID == "" {
+		profileID = "__local__"
+	}
+	source.RegisterProfile(profileID, creds)
+	backend, _ := creds["type"].(string)
+	ref := source.SourceRef{Backend: backend, Path: sourcePath, ProfileID: profileID}
+
+	var dbPath string
+	var err error
+	if r.URL.Query().Get("refresh") == "1" {
+		dbPath, err = s.dataManager.Refresh(r.Context(), ref, alias)
+	} else {
+		dbPath, err = s.dataManager.GetSQLiteDB(r.Context(), ref, alias)
+	}
 	if err != nil {
 		s.log("Error processing data: %v", err)
 
@@ -453,7 +1207,7 @@ func (s *Server) serveDatabase(w http.ResponseWriter, r *http.Request, bq *banqu
 	if bq.Table == "sqlite_master" || bq.Table == "" {
 		s.listTables(w, r, db, dbUrlPath)
 	} else {
-		s.queryTable(w, db, bq)
+		s.queryTable(w, r, db, bq)
 	}
 }
 
@@ -502,24 +1256,58 @@ func (s *Server) listTables(w http.ResponseWriter, r *http.Request, db *sql.DB,
 	s.tableWriter.EndHTMLTable(w)
 }
 
-func (s *Server) queryTable(w http.ResponseWriter, db *sql.DB, bq *banquet.Banquet) {
-	query := common.ConstructSQL(bq)
-	s.log("Executing query: %s", query)
+// queryTable runs bq's query with keyset pagination (?after=<cursor>,
+// ?limit=N) and writes the page in whichever format the request negotiated
+// - HTML (default), NDJSON, CSV, or Arrow IPC via ?format= or Accept. It
+// reads at most limit+1 rows into a bounded buffer (never the whole result
+// set) so it can decide on a next-page cursor, the Link header and
+// X-Total-Rows before writing a single response byte.
+func (s *Server) queryTable(w http.ResponseWriter, r *http.Request, db *sql.DB, bq *banquet.Banquet) {
+	baseQuery := common.ConstructSQL(bq)
+	s.log("Executing query: %s", baseQuery)
+
+	params, err := parsePageParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	rows, err := db.Query(query)
+	var totalRows int64
+	haveTotal := false
+	if params.countExact {
+		// Counting requires its own scan over baseQuery, so it's opt-in
+		// rather than bundled into every paged query.
+		if err := db.QueryRow(buildCountQuery(baseQuery)).Scan(&totalRows); err != nil {
+			s.log("Error counting rows for %q: %v", baseQuery, err)
+		} else {
+			haveTotal = true
+		}
+	}
+
+	pagedQuery, paged := buildPagedQuery(baseQuery, params)
+	rows, err := db.Query(pagedQuery)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Query error: %v\nQuery: %s", err, query), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Query error: %v\nQuery: %s", err, pagedQuery), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
 	columns, err := rows.Columns()
 	if err != nil {
+		rows.Close()
 		http.Error(w, fmt.Sprintf("Error getting columns: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	s.tableWriter.StartHTMLTable(w, columns, bq.Table)
+	offset := 0
+	displayColumns := columns
+	if paged && len(columns) > 0 && columns[0] == rowIDAlias {
+		offset = 1
+		displayColumns = columns[1:]
+	}
+
+	buffered := make([][]string, 0, params.limit)
+	var lastRowID int64
+	hasNext := false
 
 	values := make([]interface{}, len(columns))
 	valuePtrs := make([]interface{}, len(columns))
@@ -527,33 +1315,102 @@ func (s *Server) queryTable(w http.ResponseWriter, db *sql.DB, bq *banquet.Banqu
 		valuePtrs[i] = &values[i]
 	}
 
-	rowCounter := 0
-
 	for rows.Next() {
+		if len(buffered) == params.limit {
+			hasNext = true
+			break
+		}
 		if err := rows.Scan(valuePtrs...); err != nil {
 			log.Println("Error scanning row:", err)
 			continue
 		}
 
-		strValues := make([]string, len(columns))
-		for i, val := range values {
-			if val == nil {
-				strValues[i] = "NULL"
+		if offset == 1 {
+			switch id := values[0].(type) {
+			case int64:
+				lastRowID = id
+			case int:
+				lastRowID = int64(id)
+			}
+		}
+
+		row := make([]string, len(displayColumns))
+		for i := range displayColumns {
+			v := values[i+offset]
+			if v == nil {
+				row[i] = "NULL"
 			} else {
-				strValues[i] = fmt.Sprintf("%v", val)
+				row[i] = fmt.Sprintf("%v", v)
 			}
 		}
+		buffered = append(buffered, row)
+	}
+	rows.Close()
+
+	var nextLink string
+	if hasNext && offset == 1 {
+		cursor := encodeCursor(pageCursor{LastRowID: lastRowID, OrderCols: []string{}})
+		nextLink = nextPageURL(r, cursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextLink))
+	} else if hasNext {
+		// buildPagedQuery couldn't rewrite this query for keyset pagination
+		// (see injectRowID), so it fell back to a plain LIMIT with no
+		// cursor to offer - tell the client the page was capped rather
+		// than silently dropping the remaining rows.
+		w.Header().Set("Warning", `199 flight2 "result truncated at limit, pagination unavailable for this query"`)
+	}
+	if haveTotal {
+		w.Header().Set("X-Total-Rows", strconv.FormatInt(totalRows, 10))
+	}
 
-		s.tableWriter.WriteHTMLRow(w, rowCounter, strValues)
-		rowCounter++
+	switch negotiateFormat(r) {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write(displayColumns)
+		for _, row := range buffered {
+			cw.Write(row)
+		}
+		cw.Flush()
+	case formatJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, row := range buffered {
+			obj := make(map[string]string, len(displayColumns))
+			for i, col := range displayColumns {
+				obj[col] = row[i]
+			}
+			enc.Encode(obj)
+		}
+	case formatArrow:
+		w.Header().Set("Content-Type", "application/vnd.apache.arrow.stream")
+		if err := writeArrowStream(w, displayColumns, buffered); err != nil {
+			s.log("Error writing arrow stream: %v", err)
+		}
+	default:
+		s.tableWriter.StartHTMLTable(w, displayColumns, bq.Table)
+		for i, row := range buffered {
+			s.tableWriter.WriteHTMLRow(w, i, row)
+		}
+		if nextLink != "" {
+			fmt.Fprintf(w, `<tr><td colspan="%d"><a href="%s">Next page &rarr;</a></td></tr>`, len(displayColumns), nextLink)
+		}
+		s.tableWriter.EndHTMLTable(w)
 	}
 
-	s.tableWriter.EndHTMLTable(w)
-	s.log("Finished response")
+	s.log("Finished response (%d rows, next=%v)", len(buffered), hasNext)
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
-	aliases, err := s.secrets.ListAliases()
+	subject := subjectFromContext(r.Context())
+
+	var aliases []string
+	var err error
+	if subject != "" {
+		aliases, err = s.secrets.ListAliasesForUser(subject)
+	} else {
+		aliases, err = s.secrets.ListAliases()
+	}
 	if err != nil {
 		http.Error(w, "Failed to list credentials", http.StatusInternalServerError)
 		return
@@ -564,7 +1421,13 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	var editType string
 	var editConfig string
 	if editAlias != "" {
-		creds, err := s.secrets.GetCredentials(editAlias)
+		var creds map[string]interface{}
+		var err error
+		if subject != "" {
+			creds, err = s.secrets.GetCredentialsForUser(subject, editAlias)
+		} else {
+			creds, err = s.secrets.GetCredentials(editAlias)
+		}
 		if err == nil {
 			if t, ok := creds["type"].(string); ok {
 				editType = t
@@ -772,7 +1635,13 @@ func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request)
 	}
 	creds["type"] = fsType
 
-	_, err := s.secrets.StoreCredentials(alias, creds)
+	subject := subjectFromContext(r.Context())
+	var err error
+	if subject != "" {
+		_, err = s.secrets.StoreCredentialsForUser(subject, alias, creds)
+	} else {
+		_, err = s.secrets.StoreCredentials(alias, creds)
+	}
 	if err != nil {
 		http.Error(w, "Failed to store credentials", http.StatusInternalServerError)
 		return
@@ -781,8 +1650,14 @@ func (s *Server) handleCreateCredential(w http.ResponseWriter, r *http.Request)
 	// Rename: if originalAlias is set and different, delete the old one
 	if originalAlias != "" && originalAlias != alias {
 		s.log("Renaming credential: %s -> %s", originalAlias, alias)
-		if err := s.secrets.DeleteCredentials(originalAlias); err != nil {
-			s.log("Warning: failed to delete old alias %s during rename: %v", originalAlias, err)
+		var delErr error
+		if subject != "" {
+			delErr = s.secrets.DeleteCredentialsForUser(subject, originalAlias)
+		} else {
+			delErr = s.secrets.DeleteCredentials(originalAlias)
+		}
+		if delErr != nil {
+			s.log("Warning: failed to delete old alias %s during rename: %v", originalAlias, delErr)
 			// Don't fail the request, the new one is saved. just log it.
 		}
 	}
@@ -810,11 +1685,27 @@ func (s *Server) handleDeleteCredential(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := s.secrets.DeleteCredentials(alias); err != nil {
+	subject := subjectFromContext(r.Context())
+	var err error
+	if subject != "" {
+		err = s.secrets.DeleteCredentialsForUser(subject, alias)
+	} else {
+		err = s.secrets.DeleteCredentials(alias)
+	}
+	if err != nil {
 		http.Error(w, "Failed to delete credential", http.StatusInternalServerError)
 		return
 	}
 
+	// Best-effort: cached artifacts fetched under the deleted credential
+	// shouldn't linger, but a purge failure shouldn't block the deletion
+	// the user actually asked for.
+	if s.dataManager != nil {
+		if err := s.dataManager.Purge(alias); err != nil {
+			log.Printf("Purge(%s) after credential delete: %v", alias, err)
+		}
+	}
+
 	http.Redirect(w, r, "/app/credentials/manage", http.StatusSeeOther)
 }
 
@@ -869,6 +1760,50 @@ func (s *Server) listingLogic(w http.ResponseWriter, r *http.Request, alias stri
 	basePath := "/app/browse/" + alias
 	viewPath := "/app/view/" + alias
 
+	fmt.Fprintf(w, `
+<form method="post" action="/app/mkdir/%s/%s" style="display:inline-block; margin-right:1rem;">
+<input type="text" name="name" placeholder="New folder name" required>
+<button type="submit" class="btn">📁+ New Folder</button>
+</form>
+<form id="upload-form" method="post" action="/app/upload/%s/%s" enctype="multipart/form-data" style="display:inline-block;">
+<input type="file" name="file" multiple>
+<button type="submit" class="btn btn-primary">⬆️ Upload</button>
+<progress id="upload-progress" value="0" max="100" style="display:none; vertical-align:middle; margin-left:0.5rem;"></progress>
+</form>
+<a href="/app/archive/%s/%s.tar.gz" class="btn" style="display:inline-block;">🗜️ Download folder as .tar.gz</a>
+<a href="/app/search/%s" class="btn" style="display:inline-block;">🔎 Search</a>
+<form method="post" action="/app/index/reindex/%s" style="display:inline-block;">
+<button type="submit" class="btn">🔄 Reindex</button>
+</form>
+<script>
+(function() {
+	var form = document.getElementById('upload-form');
+	var bar = document.getElementById('upload-progress');
+	if (!form) return;
+	form.addEventListener('submit', function(e) {
+		e.preventDefault();
+		var data = new FormData(form);
+		var xhr = new XMLHttpRequest();
+		xhr.open('POST', form.action, true);
+		bar.style.display = 'inline-block';
+		xhr.upload.onprogress = function(ev) {
+			if (ev.lengthComputable) bar.value = (ev.loaded / ev.total) * 100;
+		};
+		xhr.onload = function() { window.location.reload(); };
+		xhr.send(data);
+	});
+	// Drag-and-drop anywhere on the page adds files to the same form/input.
+	document.body.addEventListener('dragover', function(e) { e.preventDefault(); });
+	document.body.addEventListener('drop', function(e) {
+		e.preventDefault();
+		if (!e.dataTransfer || !e.dataTransfer.files.length) return;
+		form.querySelector('input[type=file]').files = e.dataTransfer.files;
+		form.requestSubmit();
+	});
+})();
+</script>
+`, alias, relPath, alias, relPath, alias, relPath, alias, alias)
+
 	cols := []string{"Type", "Name", "Size", "Modified", "Actions"}
 	s.tableWriter.StartHTMLTable(w, cols, "")
 
@@ -890,9 +1825,16 @@ func (s *Server) listingLogic(w http.ResponseWriter, r *http.Request, alias stri
 			icon = "<span class='badge badge-folder'>📁</span>"
 			sizeStr = "-"
 			modified = entry.ModTime().Format("2006-01-02 15:04:05")
-			actions = fmt.Sprintf("<a href='%s/%s' class='btn btn-browse'>📂 Open</a>", basePath, fullPath)
+			actions = fmt.Sprintf("<a href='%s/%s' class='btn btn-browse'>📂 Open</a> <a href='/app/archive/%s/%s.zip' class='btn btn-view'>🗜️ Download .zip</a> %s", basePath, fullPath, alias, fullPath, deleteForm(alias, fullPath, true))
 		} else {
-			icon = "<span class='badge badge-file'>📄</span>"
+			if s.thumbs != nil {
+				if _, ok := thumbs.SupportedContentType(fullPath); ok {
+					icon = fmt.Sprintf("<img loading=\"lazy\" src=\"/app/thumb/%s/%s?w=80\" width=\"40\" height=\"40\" style=\"object-fit:cover;border-radius:4px;\">", alias, fullPath)
+				}
+			}
+			if icon == "" {
+				icon = "<span class='badge badge-file'>📄</span>"
+			}
 			sizeStr = formatSize(entry.Size())
 			modified = entry.ModTime().Format("2006-01-02 15:04:05")
 			// For files, we offer "View" and if it's a known database type, "Query"
@@ -901,7 +1843,7 @@ func (s *Server) listingLogic(w http.ResponseWriter, r *http.Request, alias stri
 			if ext == ".db" || ext == ".sqlite" || ext == ".sqlite3" || ext == ".csv" || ext == ".xlsx" || ext == ".json" {
 				queryAction = fmt.Sprintf("<a href='/%s@%s/' class='btn btn-primary'>📊 Query</a>", alias, fullPath)
 			}
-			actions = fmt.Sprintf("%s <a href='%s/%s' target='_blank' class='btn btn-view'>👁️ View</a>", queryAction, viewPath, fullPath)
+			actions = fmt.Sprintf("%s <a href='%s/%s' target='_blank' class='btn btn-view'>👁️ View</a> %s", queryAction, viewPath, fullPath, deleteForm(alias, fullPath, false))
 		}
 
 		fmt.Fprintf(w, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>", icon, name, sizeStr, modified, actions)
@@ -912,7 +1854,12 @@ func (s *Server) listingLogic(w http.ResponseWriter, r *http.Request, alias stri
 	fmt.Fprintf(w, "</body></html>")
 }
 
-func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+// handleRaw streams relPath's bytes exactly as the file's backend has
+// them, with Range/If-Range/ETag support - no HTML, no content-type
+// dispatch. It's what every inline viewer's <img>/<video>/<audio> src and
+// handleView's ?raw=1 bypass point at; see view.go for the HTML wrapper
+// mounted at /app/view.
+func (s *Server) handleRaw(w http.ResponseWriter, r *http.Request) {
 	alias := r.PathValue("alias")
 	relPath := r.PathValue("path")
 
@@ -922,15 +1869,222 @@ func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rc, err := dataset_source.GetFileStream(r.Context(), relPath, creds)
+	info, err := dataset_source.Stat(r.Context(), relPath, creds)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
 		return
 	}
-	defer rc.Close()
 
+	etag := info.ETag
+	if etag == "" {
+		etag = weakETag(info.Size, info.ModTime.Unix())
+	} else {
+		etag = fmt.Sprintf(`W/%q`, etag)
+	}
+
+	if ifNoneMatchSatisfied(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		if !info.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(relPath))
+	if contentType == "" {
+		// The extension alone didn't resolve a type (no extension, or one
+		// mime's table doesn't know) - sniff the first 512 bytes the same
+		// way http.ServeContent does for os.File-backed handlers, since a
+		// backend holds arbitrary uploaded files handleUpload never
+		// requires an extension for.
+		contentType = s.sniffContentType(r.Context(), alias, relPath, creds, info)
+	}
+	if contentType == "" || isUnsafeInlineContentType(contentType) {
+		// A backend holds arbitrary uploaded files (handleUpload imposes no
+		// type restriction), and this handler always serves inline. Letting
+		// an uploaded .html/.svg/.js assert its "real" content type would
+		// have the browser render or execute it under this origin -
+		// serve those generically instead, same as an unrecognized
+		// extension.
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", path.Base(relPath)))
-	io.Copy(w, rc)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", etag)
+	if !info.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime.UTC().Format(http.TimeFormat))
+	}
+
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader != "" {
+		if ifRange := r.Header.Get("If-Range"); ifRange != "" && !ifRangeSatisfied(ifRange, etag, info.ModTime) {
+			// The representation changed since the client cached it -
+			// fall through and serve the full body instead of a range.
+			rangeHeader = ""
+		}
+	}
+
+	if rangeHeader == "" {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		rc, err := dataset_source.GetFileStream(r.Context(), relPath, creds)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		io.Copy(w, rc)
+		return
+	}
+
+	ranges, err := parseRangeHeader(rangeHeader, info.Size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if len(ranges) == 0 {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		http.Error(w, "Range Not Satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	rangeReader := func(start, length int64) (io.ReadCloser, error) {
+		return dataset_source.GetFileRange(r.Context(), relPath, start, length, creds)
+	}
+
+	if len(ranges) == 1 {
+		hr := ranges[0]
+		rc, err := rangeReader(hr.Start, hr.length())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to open file: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+		w.Header().Set("Content-Range", hr.contentRange(info.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(hr.length(), 10))
+		w.WriteHeader(http.StatusPartialContent)
+		io.Copy(w, rc)
+		return
+	}
+
+	// Overlapping multi-range requests are a known amplification vector
+	// (a short request can demand the same bytes many times over); reject
+	// them outright rather than silently serving only one of the ranges.
+	if rangesOverlap(ranges) {
+		http.Error(w, "Range Not Satisfiable: overlapping ranges", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	// Multiple, non-overlapping ranges: stream as multipart/byteranges
+	// directly to w. The body length isn't known ahead of the multipart
+	// encoding, so Content-Length is omitted and net/http falls back to
+	// chunked transfer encoding instead of buffering the whole response.
+	boundary := multipartBoundary()
+	w.Header().Set("Content-Type", multipartContentType(boundary))
+	w.WriteHeader(http.StatusPartialContent)
+	if err := writeMultipartRangesWithBoundary(w, boundary, ranges, info.Size, contentType, rangeReader); err != nil {
+		s.log("handleRaw: multipart range write for %q failed: %v", relPath, err)
+	}
+}
+
+// sniffContentType detects relPath's content type by reading its first 512
+// bytes and running them through http.DetectContentType, for files whose
+// extension alone (mime.TypeByExtension) doesn't resolve one. The result is
+// cached by (alias, path, size, mtime) so repeated views of the same
+// unchanged file don't re-read its header bytes on every request; a range
+// read failure is treated as "no sniff" rather than an error, since
+// handleRaw still has the full-stream path to fall back on.
+func (s *Server) sniffContentType(ctx context.Context, alias, relPath string, creds map[string]interface{}, info source.ObjectInfo) string {
+	key := sniffCacheKey(alias, relPath, info.Size, info.ModTime)
+	if ct, ok := s.sniffCache.get(key); ok {
+		return ct
+	}
+
+	rc, err := dataset_source.GetFileRange(ctx, relPath, 0, 512, creds)
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(rc, buf)
+	ct := http.DetectContentType(buf[:n])
+	s.sniffCache.put(key, ct)
+	return ct
+}
+
+// handleThumb serves a lazily-generated, disk-cached thumbnail for an
+// image/video/PDF file, sized to the ?w= query param (default 200px). It
+// 404s for file types thumbs.SupportedContentType doesn't recognize, so
+// listingLogic can use it as a simple "does this row get an <img>?" check.
+func (s *Server) handleThumb(w http.ResponseWriter, r *http.Request) {
+	if s.thumbs == nil {
+		http.Error(w, "Thumbnails not available", http.StatusNotFound)
+		return
+	}
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+
+	if _, ok := thumbs.SupportedContentType(relPath); !ok {
+		http.Error(w, "Unsupported file type", http.StatusNotFound)
+		return
+	}
+
+	width := 200
+	if w0 := r.URL.Query().Get("w"); w0 != "" {
+		if n, err := strconv.Atoi(w0); err == nil && n > 0 && n <= 2000 {
+			width = n
+		}
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	info, err := dataset_source.Stat(r.Context(), relPath, creds)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to stat file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	req := thumbs.Request{Alias: alias, Path: relPath, Size: info.Size, ModTime: info.ModTime, Width: width}
+	result, err := s.thumbs.Get(r.Context(), req, func(ctx context.Context) (io.ReadCloser, error) {
+		return dataset_source.GetFileStream(ctx, relPath, creds)
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to generate thumbnail: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(result.Path)
+	if err != nil {
+		http.Error(w, "Thumbnail unavailable", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, path.Base(relPath)+".jpg", result.ModTime, f)
+}
+
+// isUnsafeInlineContentType reports whether contentType is one a browser
+// would render or execute (HTML, SVG, XML, JavaScript) rather than just
+// display/download - unsafe for handleRaw to assert as-is on a backend
+// that can hold arbitrary uploaded files.
+func isUnsafeInlineContentType(contentType string) bool {
+	base, _, _ := mime.ParseMediaType(contentType)
+	switch base {
+	case "text/html", "application/xhtml+xml", "image/svg+xml",
+		"text/xml", "application/xml",
+		"text/javascript", "application/javascript", "application/ecmascript":
+		return true
+	}
+	return false
 }
 
 func formatSize(b int64) string {
@@ -11,6 +11,25 @@ import (
 	"github.com/darianmavgo/sqliter/sqliter"
 )
 
+// Type: Unit Test
+func TestHandleDebugEnv_DisabledByDefault(t *testing.T) {
+	s := &Server{}
+	router := s.Router()
+
+	req, err := http.NewRequest("GET", "/app/debug/env", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusNotFound)
+	}
+}
+
 // Type: Unit Test
 func TestHandleDebugEnv(t *testing.T) {
 	// Set a custom env var to verify it appears
@@ -19,8 +38,11 @@ func TestHandleDebugEnv(t *testing.T) {
 	os.Setenv(key, val)
 	defer os.Unsetenv(key)
 
-	// Create a server instance with nil dependencies as they are not used by handleDebugEnv
-	s := &Server{}
+	secretKey, secretVal := "FLIGHT2_TEST_SECRET_KEY", "sh-sh-secret"
+	os.Setenv(secretKey, secretVal)
+	defer os.Unsetenv(secretKey)
+
+	s := &Server{enableDebugEndpoints: true}
 	router := s.Router()
 
 	req, err := http.NewRequest("GET", "/app/debug/env", nil)
@@ -36,11 +58,71 @@ func TestHandleDebugEnv(t *testing.T) {
 			status, http.StatusOK)
 	}
 
-	// Check if the output contains our env var
-	expected := key + "=" + val
-	if !strings.Contains(rr.Body.String(), expected) {
-		t.Errorf("handler returned unexpected body: got %v want %v",
-			rr.Body.String(), expected)
+	body := rr.Body.String()
+	if expected := key + "=" + val; !strings.Contains(body, expected) {
+		t.Errorf("handler returned unexpected body: got %v want %v", body, expected)
+	}
+	if strings.Contains(body, secretVal) {
+		t.Errorf("handler leaked unredacted secret value: %v", body)
+	}
+	if expected := secretKey + "=***REDACTED***"; !strings.Contains(body, expected) {
+		t.Errorf("handler did not redact %s: got %v want %v", secretKey, body, expected)
+	}
+}
+
+// Type: Unit Test
+func TestHandleDebugEnv_Unredacted(t *testing.T) {
+	secretKey, secretVal := "FLIGHT2_TEST_SECRET_KEY", "sh-sh-secret"
+	os.Setenv(secretKey, secretVal)
+	defer os.Unsetenv(secretKey)
+
+	s := &Server{enableDebugEndpoints: true, debugUnredacted: true}
+	router := s.Router()
+
+	req, err := http.NewRequest("GET", "/app/debug/env", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if expected := secretKey + "=" + secretVal; !strings.Contains(rr.Body.String(), expected) {
+		t.Errorf("handler should have left value unredacted: got %v want %v", rr.Body.String(), expected)
+	}
+}
+
+// Type: Unit Test
+func TestIsProtectedPath(t *testing.T) {
+	s := &Server{}
+	s.AddProtectedPath("secrets.db")
+	s.AddProtectedPath("secrets")
+	s.AddProtectedPath("sqlite_master")
+	s.AddProtectedPath("data/scripts/*")
+
+	cases := []struct {
+		name       string
+		sourcePath string
+		table      string
+		want       bool
+	}{
+		{"protected db file", "secrets.db", "", true},
+		{"protected table", "", "secrets", true},
+		{"safe mode table", "", "sqlite_master", true},
+		{"scripts dir itself", "data/scripts", "", true},
+		{"nested script", "data/scripts/hello.lua", "", true},
+		{"unrelated path", "datasets/app.sqlite", "orders", false},
+		{"case-variant table", "", "SECRETS", true},
+		{"mixed-case safe mode table", "", "sqlite_Master", true},
+		{"case-variant db file", "SECRETS.DB", "", true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.isProtectedPath(tt.sourcePath, tt.table); got != tt.want {
+				t.Errorf("isProtectedPath(%q, %q) = %v, want %v", tt.sourcePath, tt.table, got, tt.want)
+			}
+		})
 	}
 }
 
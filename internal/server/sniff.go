@@ -0,0 +1,72 @@
+package server
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sniffCacheCapacity bounds sniffCache's size - content-type strings are
+// tiny, so this is a generous cap rather than a tuned one, same spirit as
+// thumbs.Cache's size bound but in-memory instead of on-disk since there's
+// nothing here worth persisting across a restart.
+const sniffCacheCapacity = 4096
+
+// sniffCache is an in-memory LRU of content types handleRaw has already
+// sniffed via http.DetectContentType, keyed by (alias, path, size, mtime)
+// so a changed file never serves a stale sniff result.
+type sniffCache struct {
+	mu    sync.Mutex
+	order *list.List
+	items map[string]*list.Element
+}
+
+type sniffCacheEntry struct {
+	key         string
+	contentType string
+}
+
+func newSniffCache() *sniffCache {
+	return &sniffCache{order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// sniffCacheKey mirrors thumbs.Request.Key()'s shape, hashed so it's safe
+// regardless of what relPath contains.
+func sniffCacheKey(alias, relPath string, size int64, modTime time.Time) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d", alias, relPath, size, modTime.Unix())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *sniffCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*sniffCacheEntry).contentType, true
+}
+
+func (c *sniffCache) put(key, contentType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*sniffCacheEntry).contentType = contentType
+		c.order.MoveToFront(el)
+		return
+	}
+	el := c.order.PushFront(&sniffCacheEntry{key: key, contentType: contentType})
+	c.items[key] = el
+	if c.order.Len() > sniffCacheCapacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*sniffCacheEntry).key)
+		}
+	}
+}
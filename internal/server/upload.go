@@ -0,0 +1,339 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"flight2/internal/dataset_source"
+)
+
+// defaultMaxUploadSize bounds a single upload when the alias has no
+// SetUploadLimit override.
+const defaultMaxUploadSize = 5 * 1024 * 1024 * 1024 // 5GB
+
+// uploadMultipartMemory is how much of a multipart/form-data body
+// ParseMultipartForm buffers in memory before spilling parts to temporary
+// files on disk. The whole body is read this way before any file is handed
+// to PutFileStream, which then copies each file from that buffer.
+const uploadMultipartMemory = 32 * 1024 * 1024
+
+// SetUploadLimit overrides the maximum upload size accepted for alias,
+// replacing defaultMaxUploadSize for that alias only. A limit <= 0 removes
+// the override.
+func (s *Server) SetUploadLimit(alias string, maxBytes int64) {
+	s.uploadLimitsMu.Lock()
+	defer s.uploadLimitsMu.Unlock()
+	if maxBytes <= 0 {
+		delete(s.uploadLimits, alias)
+		return
+	}
+	s.uploadLimits[alias] = maxBytes
+}
+
+func (s *Server) uploadLimit(alias string) int64 {
+	s.uploadLimitsMu.RLock()
+	defer s.uploadLimitsMu.RUnlock()
+	if limit, ok := s.uploadLimits[alias]; ok {
+		return limit
+	}
+	return defaultMaxUploadSize
+}
+
+// deleteForm renders the small inline form listingLogic's row actions use
+// to delete a file or (recursively) a directory. It's a POST form rather
+// than a link so a crawler or prefetcher can't trigger a delete.
+func deleteForm(alias, fullPath string, recursive bool) string {
+	return fmt.Sprintf(
+		`<form method="post" action="/app/delete/%s/%s" style="display:inline;" onsubmit="return confirm('Delete %s?');"><input type="hidden" name="recursive" value="%t"><button type="submit" class="btn" style="background:#7f1d1d;">🗑️ Delete</button></form>`,
+		alias, fullPath, path.Base(fullPath), recursive)
+}
+
+// handleUpload accepts a multipart/form-data POST of one or more files,
+// buffers the body with ParseMultipartForm (see uploadMultipartMemory), then
+// streams each file part to dataset_source.PutFileStream, named after its
+// own part filename under relPath. It redirects back to
+// the listing on success, matching handleCreateCredential's
+// write-then-redirect shape.
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+
+	if s.isProtectedPath(relPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	limit := s.uploadLimit(alias)
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(uploadMultipartMemory); err != nil {
+		http.Error(w, fmt.Sprintf("Upload too large or malformed (limit %s): %v", formatSize(limit), err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		http.Error(w, "No files in upload", http.StatusBadRequest)
+		return
+	}
+
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read upload part %q: %v", fh.Filename, err), http.StatusBadRequest)
+			return
+		}
+		destPath := path.Join(relPath, path.Base(fh.Filename))
+		if s.isProtectedPath(destPath) {
+			f.Close()
+			http.Error(w, fmt.Sprintf("Forbidden: %q", fh.Filename), http.StatusForbidden)
+			return
+		}
+		err = dataset_source.PutFileStream(r.Context(), destPath, creds, f)
+		f.Close()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to store %q: %v", fh.Filename, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.Redirect(w, r, "/app/browse/"+alias+"/"+relPath, http.StatusSeeOther)
+}
+
+// handleUploadChunk resumes a large upload sent as a series of
+// "Content-Range: bytes start-end/total" PUTs, the same convention
+// gohttpserver-style tools use. Chunks are appended to a per-(alias,path)
+// staging file under the dataset Manager's cache dir; once the last byte
+// arrives, the staging file is streamed through PutFileStream and removed.
+func (s *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+
+	if s.isProtectedPath(relPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	start, end, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid Content-Range: %v", err), http.StatusBadRequest)
+		return
+	}
+	if total > s.uploadLimit(alias) {
+		http.Error(w, fmt.Sprintf("Upload exceeds limit (%s)", formatSize(s.uploadLimit(alias))), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	stagingDir := s.uploadStagingDir()
+	if stagingDir == "" {
+		http.Error(w, "Chunked uploads not available", http.StatusServiceUnavailable)
+		return
+	}
+	stagingPath := filepath.Join(stagingDir, stagingKey(alias, relPath))
+
+	// The first chunk (start == 0) truncates any staging file left behind
+	// by a prior aborted upload to the same destination, so its leftover
+	// bytes can't leak into this one.
+	openFlags := os.O_CREATE | os.O_WRONLY
+	if start == 0 {
+		openFlags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(stagingPath, openFlags, 0644)
+	if err != nil {
+		http.Error(w, "Failed to open staging file", http.StatusInternalServerError)
+		return
+	}
+	// A chunk starting past the bytes staged so far would leave a hole of
+	// unwritten bytes that the final size check can't see; reject it.
+	if st, err := f.Stat(); err != nil || start > st.Size() {
+		f.Close()
+		http.Error(w, "Chunk does not continue the staged upload", http.StatusConflict)
+		return
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		f.Close()
+		http.Error(w, "Failed to seek staging file", http.StatusInternalServerError)
+		return
+	}
+	_, copyErr := io.CopyN(f, r.Body, end-start+1)
+	closeErr := f.Close()
+	if copyErr != nil {
+		http.Error(w, fmt.Sprintf("Failed writing chunk: %v", copyErr), http.StatusInternalServerError)
+		return
+	}
+	if closeErr != nil {
+		http.Error(w, "Failed to flush chunk", http.StatusInternalServerError)
+		return
+	}
+
+	if end+1 < total {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	// Last chunk: hand the assembled file off to the backend and clean up
+	// the staging copy regardless of whether PutFileStream succeeds, so a
+	// failed upload can be retried from scratch rather than resuming into
+	// a file that's actually already complete.
+	defer os.Remove(stagingPath)
+
+	// A staging file whose size doesn't match total means at least one
+	// earlier chunk never arrived (out-of-order delivery, a dropped PUT
+	// the client didn't retry, ...); reject it rather than forwarding a
+	// file with a hole of unwritten bytes as if it were complete.
+	info, err := os.Stat(stagingPath)
+	if err != nil {
+		http.Error(w, "Failed to reopen assembled upload", http.StatusInternalServerError)
+		return
+	}
+	if info.Size() != total {
+		http.Error(w, fmt.Sprintf("Incomplete upload: have %d of %d bytes", info.Size(), total), http.StatusBadRequest)
+		return
+	}
+
+	staged, err := os.Open(stagingPath)
+	if err != nil {
+		http.Error(w, "Failed to reopen assembled upload", http.StatusInternalServerError)
+		return
+	}
+	defer staged.Close()
+
+	if err := dataset_source.PutFileStream(r.Context(), relPath, creds, staged); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to store upload: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) uploadStagingDir() string {
+	if s.dataManager == nil {
+		return ""
+	}
+	dir := filepath.Join(s.dataManager.CacheDir(), "uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// stagingKey turns (alias, relPath) into a filesystem-safe staging
+// filename; it doesn't need to be reversible, only unique per destination.
+func stagingKey(alias, relPath string) string {
+	safe := strings.NewReplacer("/", "_", "\\", "_", "..", "_").Replace(alias + "__" + relPath)
+	return safe
+}
+
+// parseContentRange parses a "bytes start-end/total" Content-Range header
+// value as sent by a resumable-upload client.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	const prefix = "bytes "
+	if !strings.HasPrefix(header, prefix) {
+		return 0, 0, 0, fmt.Errorf("missing %q prefix", prefix)
+	}
+	rest := header[len(prefix):]
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return 0, 0, 0, fmt.Errorf("missing total after '/'")
+	}
+	rangePart, totalPart := rest[:slash], rest[slash+1:]
+	dash := strings.IndexByte(rangePart, '-')
+	if dash < 0 {
+		return 0, 0, 0, fmt.Errorf("missing '-' in range")
+	}
+	start, err = strconv.ParseInt(rangePart[:dash], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err = strconv.ParseInt(rangePart[dash+1:], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid end: %w", err)
+	}
+	total, err = strconv.ParseInt(totalPart, 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid total: %w", err)
+	}
+	if start < 0 || end < start || end >= total {
+		return 0, 0, 0, fmt.Errorf("range %d-%d/%d out of bounds", start, end, total)
+	}
+	return start, end, total, nil
+}
+
+// handleMkdir creates a folder under relPath, named by the "name" form
+// value, and redirects back to the listing.
+func (s *Server) handleMkdir(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+	// path.Base strips any "../" the client snuck into the form field, so
+	// the new folder always lands inside relPath rather than escaping it.
+	name := path.Base(r.FormValue("name"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		http.Error(w, "Folder name required", http.StatusBadRequest)
+		return
+	}
+	newPath := path.Join(relPath, name)
+	if s.isProtectedPath(relPath, newPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	if err := dataset_source.Mkdir(r.Context(), newPath, creds); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create folder: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/app/browse/"+alias+"/"+relPath, http.StatusSeeOther)
+}
+
+// handleDelete removes the file or folder at relPath. recursive=true (set
+// by listingLogic's folder row) deletes a non-empty folder and everything
+// in it; otherwise a non-empty folder is rejected by the backend.
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+	recursive, _ := strconv.ParseBool(r.FormValue("recursive"))
+
+	if s.isProtectedPath(relPath) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	if err := dataset_source.Remove(r.Context(), relPath, creds, recursive); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to delete: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	parent := path.Dir(strings.TrimSuffix(relPath, "/"))
+	if parent == "." {
+		parent = ""
+	}
+	http.Redirect(w, r, "/app/browse/"+alias+"/"+parent, http.StatusSeeOther)
+}
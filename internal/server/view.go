@@ -0,0 +1,490 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+
+	"flight2/internal/dataset_source"
+	"flight2/internal/thumbs"
+)
+
+const (
+	// maxInlineRenderSize bounds how much of a file handleView's code,
+	// markdown and table viewers will read into memory - past this they
+	// render only what fit, plus a truncation notice linking to the raw
+	// stream, rather than landing a multi-GB file entirely in a string.
+	maxInlineRenderSize = 8 * 1024 * 1024 // 8MB
+
+	// viewTablePageSize is how many rows renderTableViewer shows per page.
+	viewTablePageSize = 200
+)
+
+var (
+	markdownExts = map[string]bool{".md": true, ".markdown": true}
+	audioExts    = map[string]bool{".mp3": true, ".wav": true, ".ogg": true, ".flac": true, ".m4a": true}
+	tableExts    = map[string]bool{".csv": true, ".tsv": true, ".json": true, ".ndjson": true}
+
+	// markdownSanitizer strips dangerous link/image schemes (javascript:,
+	// data:, etc.) from goldmark's rendered HTML before it's served under
+	// this app's origin - goldmark's own "safe" mode only strips raw HTML
+	// blocks, not scheme-based attacks in ordinary markdown link syntax.
+	markdownSanitizer = bluemonday.UGCPolicy()
+
+	// codeStyle and codeFormatter are shared across all renderCodeViewer
+	// calls - chroma's formatter is stateless beyond its options, and the
+	// style is a fixed process-wide choice, so there's no reason to
+	// reconstruct either per request.
+	codeStyle     = styles.Get("github")
+	codeFormatter = chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(true))
+
+	// codeViewerCSS is the Chroma stylesheet for codeStyle, computed once at
+	// package init instead of on every code-file view - it's the same bytes
+	// for every request, so recomputing and rewriting it per request would
+	// be pure repeated work.
+	codeViewerCSS = renderCodeViewerCSS()
+)
+
+func renderCodeViewerCSS() string {
+	var buf bytes.Buffer
+	codeFormatter.WriteCSS(&buf, codeStyle)
+	return buf.String()
+}
+
+// urlEncodePath percent-encodes each "/"-separated segment of p so it's
+// safe to splice into a URL path (filenames can contain '#', '?', '%',
+// which would otherwise truncate the URL or change its meaning), then
+// HTML-escapes the joined result so it's also safe inside an href/src
+// attribute.
+func urlEncodePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return html.EscapeString(strings.Join(segments, "/"))
+}
+
+// viewKind classifies relPath for handleView's dispatch. "image" and
+// "video" reuse thumbs' own extension map, so a file gets the same
+// treatment in the browse thumbnail and the inline viewer; "audio",
+// "markdown" and "table" (CSV/TSV/JSON/NDJSON) are classified here
+// directly; "code" is anything chroma's lexers package recognizes by
+// filename. Anything else returns "", which handleView serves as a raw
+// stream, same as the explicit ?raw=1 bypass.
+func viewKind(relPath string) string {
+	ext := strings.ToLower(path.Ext(relPath))
+	switch {
+	case markdownExts[ext]:
+		return "markdown"
+	case audioExts[ext]:
+		return "audio"
+	case tableExts[ext]:
+		return "table"
+	}
+	if kind, ok := thumbs.SupportedContentType(relPath); ok && (kind == "image" || kind == "video") {
+		return kind
+	}
+	if lexers.Match(relPath) != nil {
+		return "code"
+	}
+	return ""
+}
+
+// handleView serves relPath as an HTML page wrapping the inline viewer for
+// its content type - source rendered with syntax highlighting, Markdown
+// rendered to HTML, images in a lightbox with prev/next through sibling
+// entries, audio/video in <audio>/<video> tags, and CSV/JSON as a
+// paginated table via s.tableWriter. A type viewKind doesn't recognize, or
+// an explicit ?raw=1, falls through to handleRaw's plain byte stream -
+// what every viewer's markup itself points at for the underlying bytes.
+func (s *Server) handleView(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("raw") == "1" {
+		s.handleRaw(w, r)
+		return
+	}
+
+	alias := r.PathValue("alias")
+	relPath := r.PathValue("path")
+
+	kind := viewKind(relPath)
+	if kind == "" {
+		s.handleRaw(w, r)
+		return
+	}
+
+	creds, err := s.secrets.GetCredentials(alias)
+	if err != nil {
+		http.Error(w, "Remote not found", http.StatusNotFound)
+		return
+	}
+
+	rawHref := fmt.Sprintf("/app/raw/%s/%s", urlEncodePath(alias), urlEncodePath(relPath))
+
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>View - %s</title>
+<link rel="stylesheet" href="/cssjs/default.css">
+</head>
+<body>
+<div class="container">
+<h2>%s <span style="color:var(--text-muted); font-size: 0.9rem; margin-left: 0.5rem;">/%s</span></h2>
+<p><a href="%s" class="btn btn-view">⬇️ Raw</a></p>
+`, html.EscapeString(path.Base(relPath)), html.EscapeString(path.Base(relPath)), html.EscapeString(relPath), rawHref)
+
+	switch kind {
+	case "image":
+		s.renderImageViewer(w, r, alias, relPath, creds)
+	case "video":
+		fmt.Fprintf(w, `<video controls style="max-width:100%%;" src="%s"></video>`, rawHref)
+	case "audio":
+		fmt.Fprintf(w, `<audio controls style="width:100%%;" src="%s"></audio>`, rawHref)
+	case "markdown":
+		s.renderMarkdownViewer(w, r, relPath, creds)
+	case "code":
+		s.renderCodeViewer(w, r, relPath, creds)
+	case "table":
+		s.renderTableViewer(w, r, relPath, creds)
+	}
+
+	fmt.Fprint(w, `</div></body></html>`)
+}
+
+// renderImageViewer shows relPath full-size and, by listing its parent
+// directory through dataset_source.ListEntries, links to the previous and
+// next sibling image (by name) so a user can step through a folder of
+// photos without going back to the listing each time.
+func (s *Server) renderImageViewer(w http.ResponseWriter, r *http.Request, alias, relPath string, creds map[string]interface{}) {
+	rawHref := fmt.Sprintf("/app/raw/%s/%s", urlEncodePath(alias), urlEncodePath(relPath))
+	fmt.Fprintf(w, `<div style="text-align:center;"><img src="%s" style="max-width:100%%; max-height:80vh;"></div>`, rawHref)
+
+	dir := path.Dir(relPath)
+	if dir == "." {
+		dir = ""
+	}
+	entries, err := dataset_source.ListEntries(r.Context(), dir, creds)
+	if err != nil {
+		return
+	}
+	var siblings []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if kind, ok := thumbs.SupportedContentType(e.Name()); ok && kind == "image" {
+			siblings = append(siblings, e.Name())
+		}
+	}
+	sort.Strings(siblings)
+
+	name := path.Base(relPath)
+	idx := sort.SearchStrings(siblings, name)
+	if idx >= len(siblings) || siblings[idx] != name {
+		return
+	}
+
+	fmt.Fprint(w, `<p style="text-align:center;">`)
+	if idx > 0 {
+		fmt.Fprintf(w, `<a href="/app/view/%s/%s" class="btn btn-browse">&larr; Previous</a> `, urlEncodePath(alias), urlEncodePath(path.Join(dir, siblings[idx-1])))
+	}
+	if idx < len(siblings)-1 {
+		fmt.Fprintf(w, `<a href="/app/view/%s/%s" class="btn btn-browse">Next &rarr;</a>`, urlEncodePath(alias), urlEncodePath(path.Join(dir, siblings[idx+1])))
+	}
+	fmt.Fprint(w, `</p>`)
+}
+
+// renderMarkdownViewer reads relPath (bounded by maxInlineRenderSize) and
+// renders it to HTML with goldmark.
+func (s *Server) renderMarkdownViewer(w http.ResponseWriter, r *http.Request, relPath string, creds map[string]interface{}) {
+	content, truncated, err := readBounded(r, relPath, creds, maxInlineRenderSize)
+	if err != nil {
+		fmt.Fprintf(w, "<p>Failed to read file: %s</p>", html.EscapeString(err.Error()))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := goldmark.Convert(content, &buf); err != nil {
+		fmt.Fprintf(w, "<p>Failed to render markdown: %s</p>", html.EscapeString(err.Error()))
+		return
+	}
+	// goldmark's default (non-WithUnsafe) mode only strips raw HTML - it
+	// still emits javascript:/data: hrefs from ordinary markdown link
+	// syntax verbatim, and relPath can be arbitrary uploaded content (see
+	// handleUpload). Run the output through bluemonday's UGC policy the
+	// same way any other untrusted-markdown renderer would, so a crafted
+	// [text](javascript:...) link can't execute under this origin.
+	w.Write(markdownSanitizer.SanitizeBytes(buf.Bytes()))
+	if truncated {
+		fmt.Fprintf(w, `<p style="color:var(--text-muted);">Truncated at %s - <a href="?raw=1">view raw</a> for the rest.</p>`, formatSize(maxInlineRenderSize))
+	}
+}
+
+// renderCodeViewer reads relPath (bounded by maxInlineRenderSize) and
+// highlights it with chroma, picking a lexer by filename and falling back
+// to plain text when chroma doesn't recognize the extension.
+func (s *Server) renderCodeViewer(w http.ResponseWriter, r *http.Request, relPath string, creds map[string]interface{}) {
+	content, truncated, err := readBounded(r, relPath, creds, maxInlineRenderSize)
+	if err != nil {
+		fmt.Fprintf(w, "<p>Failed to read file: %s</p>", html.EscapeString(err.Error()))
+		return
+	}
+
+	lexer := lexers.Match(relPath)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := lexer.Tokenise(nil, string(content))
+	if err != nil {
+		fmt.Fprintf(w, "<pre>%s</pre>", html.EscapeString(string(content)))
+		return
+	}
+
+	fmt.Fprint(w, codeViewerCSS)
+	if err := codeFormatter.Format(w, codeStyle, iterator); err != nil {
+		fmt.Fprintf(w, "<pre>%s</pre>", html.EscapeString(string(content)))
+		return
+	}
+	if truncated {
+		fmt.Fprintf(w, `<p style="color:var(--text-muted);">Truncated at %s - <a href="?raw=1">view raw</a> for the rest.</p>`, formatSize(maxInlineRenderSize))
+	}
+}
+
+// renderTableViewer parses relPath as CSV/TSV or (ND)JSON and renders a
+// page of it with s.tableWriter, the same HTML table helper listingLogic
+// and the query handlers use. Paging is a plain ?page= offset into the
+// parsed rows - simpler than query_page.go's keyset cursor, since this is
+// pulling rows out of one already-read file rather than driving a SQL
+// query, so there's no per-page backend round-trip to economize.
+func (s *Server) renderTableViewer(w http.ResponseWriter, r *http.Request, relPath string, creds map[string]interface{}) {
+	rc, err := dataset_source.GetFileStream(r.Context(), relPath, creds)
+	if err != nil {
+		fmt.Fprintf(w, "<p>Failed to read file: %s</p>", html.EscapeString(err.Error()))
+		return
+	}
+	defer rc.Close()
+
+	header, rows, truncated, err := parseTableFile(relPath, rc, maxInlineRenderSize)
+	if err != nil {
+		fmt.Fprintf(w, "<p>Failed to parse %s: %s</p>", html.EscapeString(path.Ext(relPath)), html.EscapeString(err.Error()))
+		return
+	}
+
+	maxPage := len(rows) / viewTablePageSize
+	page := 0
+	if p := r.URL.Query().Get("page"); p != "" {
+		if n, err := strconv.Atoi(p); err == nil && n > 0 && n <= maxPage {
+			page = n
+		}
+	}
+	start := page * viewTablePageSize
+	if start > len(rows) {
+		start = len(rows)
+	}
+	end := start + viewTablePageSize
+	if end > len(rows) {
+		end = len(rows)
+	}
+
+	s.tableWriter.StartHTMLTable(w, header, "")
+	for i, row := range rows[start:end] {
+		s.tableWriter.WriteHTMLRow(w, start+i, row)
+	}
+	s.tableWriter.EndHTMLTable(w)
+
+	fmt.Fprintf(w, `<p>Rows %d-%d of %d`, start+1, end, len(rows))
+	if end < len(rows) {
+		fmt.Fprintf(w, ` &middot; <a href="?page=%d">Next page &rarr;</a>`, page+1)
+	}
+	if page > 0 {
+		fmt.Fprintf(w, ` &middot; <a href="?page=%d">&larr; Previous page</a>`, page-1)
+	}
+	fmt.Fprint(w, `</p>`)
+	if truncated {
+		fmt.Fprintf(w, `<p style="color:var(--text-muted);">File truncated at %s before parsing - rows past that point are missing.</p>`, formatSize(maxInlineRenderSize))
+	}
+}
+
+// parseTableFile parses relPath's content (by extension) as CSV/TSV, a top-
+// level JSON array of objects, or NDJSON (one object per line), returning
+// a header row that's the union of every object's keys (see
+// objectsToTable for its order) and every row stringified to match it.
+//
+// CSV/TSV and JSON/NDJSON are both read through a capReader that caps the
+// parser's total input at limit bytes, surfacing io.EOF once hit rather
+// than an unbounded read - a single record/element can't balloon past
+// limit bytes in memory even if it contains no newline (CSV) or is one
+// huge value (JSON). A parse failure only counts as truncation (not a
+// hard error) when the cap, not malformed input, is what stopped it -
+// checked for CSV by the sentinel io.EOF after header/rows so far are
+// kept, and for JSON/NDJSON by decodeTableObjects checking cr.hitLimit()
+// on a Decode error.
+func parseTableFile(relPath string, r io.Reader, limit int64) (header []string, rows [][]string, truncated bool, err error) {
+	ext := strings.ToLower(path.Ext(relPath))
+	switch ext {
+	case ".csv", ".tsv":
+		cr := &capReader{r: r, limit: limit}
+		reader := csv.NewReader(cr)
+		if ext == ".tsv" {
+			reader.Comma = '\t'
+		}
+		reader.FieldsPerRecord = -1
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if cr.hitLimit() {
+					break
+				}
+				return nil, nil, false, err
+			}
+			row := append([]string(nil), record...)
+			if header == nil {
+				header = row
+				continue
+			}
+			rows = append(rows, row)
+		}
+		return header, rows, cr.hitLimit(), nil
+	case ".json":
+		cr := &capReader{r: r, limit: limit}
+		dec := json.NewDecoder(cr)
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if d, ok := tok.(json.Delim); !ok || d != '[' {
+			return nil, nil, false, fmt.Errorf("expected a top-level JSON array")
+		}
+		objs, truncated, err := decodeTableObjects(dec, cr)
+		if err != nil {
+			return nil, nil, truncated, err
+		}
+		header = objectsToTable(objs)
+		return header, tableRowsFromObjects(objs, header), truncated, nil
+	case ".ndjson":
+		cr := &capReader{r: r, limit: limit}
+		dec := json.NewDecoder(cr)
+		objs, truncated, err := decodeTableObjects(dec, cr)
+		if err != nil {
+			return nil, nil, truncated, err
+		}
+		header = objectsToTable(objs)
+		return header, tableRowsFromObjects(objs, header), truncated, nil
+	}
+	return nil, nil, false, fmt.Errorf("unsupported table extension %q", ext)
+}
+
+// capReader refuses to read past limit bytes total, surfacing io.EOF
+// instead once the cap is hit - so a csv.Reader or json.Decoder reading
+// from one can never buffer more than ~limit bytes for a single
+// oversized record/element.
+type capReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.n >= c.limit {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.limit-c.n {
+		p = p[:c.limit-c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *capReader) hitLimit() bool { return c.n >= c.limit }
+
+// decodeTableObjects decodes dec's remaining top-level elements one at a
+// time. A Decode error is treated as truncation (not failure) exactly
+// when cr has hit its cap - i.e. the cap cut the stream off mid-element -
+// so genuinely malformed JSON within the limit still surfaces as an
+// error.
+func decodeTableObjects(dec *json.Decoder, cr *capReader) (objs []map[string]interface{}, truncated bool, err error) {
+	for dec.More() {
+		var obj map[string]interface{}
+		if decErr := dec.Decode(&obj); decErr != nil {
+			if cr.hitLimit() {
+				break
+			}
+			return objs, false, decErr
+		}
+		objs = append(objs, obj)
+	}
+	return objs, cr.hitLimit(), nil
+}
+
+// objectsToTable collects the union of keys across objs as the header row
+// for tableRowsFromObjects. Keys are added object by object in objs order,
+// but the keys one object adds come in Go's map iteration order, so their
+// relative order is not defined and can differ between calls.
+func objectsToTable(objs []map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var header []string
+	for _, obj := range objs {
+		for k := range obj {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+	return header
+}
+
+// tableRowsFromObjects stringifies each object's values under header,
+// leaving a blank cell for any key the object doesn't have.
+func tableRowsFromObjects(objs []map[string]interface{}, header []string) [][]string {
+	rows := make([][]string, len(objs))
+	for i, obj := range objs {
+		row := make([]string, len(header))
+		for j, k := range header {
+			if v, ok := obj[k]; ok {
+				row[j] = fmt.Sprint(v)
+			}
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+// readBounded reads up to limit+1 bytes of relPath, reporting whether the
+// file was truncated to fit.
+func readBounded(r *http.Request, relPath string, creds map[string]interface{}, limit int64) (content []byte, truncated bool, err error) {
+	rc, err := dataset_source.GetFileStream(r.Context(), relPath, creds)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+
+	content, err = io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(content)) > limit {
+		return content[:limit], true, nil
+	}
+	return content, false, nil
+}
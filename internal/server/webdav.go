@@ -0,0 +1,399 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"flight2/internal/apitoken"
+	"flight2/internal/dataset_source"
+	"flight2/internal/secrets"
+)
+
+// aggregateFS implements webdav.FileSystem over every alias registered in
+// secrets, presenting "/alias/relPath" as one unified namespace - the same
+// split handleBrowse's /app/browse/{alias}/{path...} makes, just rooted at
+// /dav/ instead. The DAV root lists aliases as directories; everything
+// below an alias delegates to dataset_source exactly like the HTML
+// browse/view/upload handlers do.
+type aggregateFS struct {
+	secrets *secrets.Service
+
+	// isProtectedPath is Server.isProtectedPath, threaded through rather
+	// than holding a *Server so aggregateFS only depends on what it
+	// actually uses. Every write/read path below checks it before
+	// touching dataset_source, same as archive.go/upload.go/server.go's
+	// handleBanquet - DAV resolves the same alias->path namespace they
+	// do, so it must be locked down the same way.
+	isProtectedPath func(candidates ...string) bool
+}
+
+func newAggregateFS(ss *secrets.Service, isProtectedPath func(candidates ...string) bool) *aggregateFS {
+	return &aggregateFS{secrets: ss, isProtectedPath: isProtectedPath}
+}
+
+// splitDAVPath separates name's leading alias segment from the rest of the
+// path. webdav.Handler always hands FileSystem a "/"-rooted, already
+// Prefix-stripped name.
+func splitDAVPath(name string) (alias, relPath string) {
+	trimmed := strings.Trim(path.Clean("/"+name), "/")
+	if trimmed == "" || trimmed == "." {
+		return "", ""
+	}
+	alias, rest, _ := strings.Cut(trimmed, "/")
+	return alias, rest
+}
+
+// translateErr maps a missing alias or a not-found rclone/VFS error onto
+// os.ErrNotExist, which webdav.Handler checks for (via os.IsNotExist) to
+// answer 404 instead of 500 - the same translation handleBrowse/handleRaw
+// get for free from http.Error's explicit status codes, needed here
+// because webdav.Handler decides the status itself.
+func translateErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if err == secrets.ErrNotFound {
+		return os.ErrNotExist
+	}
+	msg := err.Error()
+	if strings.Contains(msg, "object not found") ||
+		strings.Contains(msg, "directory not found") ||
+		strings.Contains(msg, "no such file or directory") {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+func (afs *aggregateFS) credsFor(alias string) (map[string]interface{}, error) {
+	if alias == "" {
+		return nil, os.ErrNotExist
+	}
+	return afs.secrets.GetCredentials(alias)
+}
+
+// dirInfo is the os.FileInfo aggregateFS hands back for every directory
+// level it serves (the DAV root, an alias root, or a backend directory),
+// none of which has real rclone metadata of its own. entries is only
+// populated when dirInfo is also being used to seed a davFile's Readdir.
+type dirInfo struct {
+	name    string
+	entries []os.FileInfo
+}
+
+func (d dirInfo) Name() string       { return d.name }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
+
+// fileInfo adapts a dataset_source.Stat probe into an os.FileInfo.
+type fileInfo struct {
+	name string
+	size int64
+	mod  time.Time
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) Mode() os.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return f.mod }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+// statEntry resolves relPath to an os.FileInfo, probing whether it's a
+// directory by trying dataset_source.ListEntries first - dataset_source's
+// Stat (backed by rclone's VFS) only answers for files, the same
+// asymmetry walkArchiveEntries and listingLogic work around by listing a
+// parent directory instead.
+func statEntry(ctx context.Context, relPath string, creds map[string]interface{}) (os.FileInfo, error) {
+	if entries, err := dataset_source.ListEntries(ctx, relPath, creds); err == nil {
+		return dirInfo{name: path.Base(relPath), entries: entries}, nil
+	}
+	info, err := dataset_source.Stat(ctx, relPath, creds)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{name: path.Base(relPath), size: info.Size, mod: info.ModTime}, nil
+}
+
+func (afs *aggregateFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	alias, relPath := splitDAVPath(name)
+	if alias == "" || relPath == "" {
+		// Either no alias segment at all, or "mkdir" on an alias root,
+		// which already exists as a directory by definition.
+		return os.ErrInvalid
+	}
+	if afs.isProtectedPath(relPath) {
+		return os.ErrPermission
+	}
+	creds, err := afs.credsFor(alias)
+	if err != nil {
+		return translateErr(err)
+	}
+	return translateErr(dataset_source.Mkdir(ctx, relPath, creds))
+}
+
+func (afs *aggregateFS) RemoveAll(ctx context.Context, name string) error {
+	alias, relPath := splitDAVPath(name)
+	if alias == "" || relPath == "" {
+		return os.ErrInvalid
+	}
+	if afs.isProtectedPath(relPath) {
+		return os.ErrPermission
+	}
+	creds, err := afs.credsFor(alias)
+	if err != nil {
+		return translateErr(err)
+	}
+	return translateErr(dataset_source.Remove(ctx, relPath, creds, true))
+}
+
+// Rename backs WebDAV's MOVE method. It only supports moves within the
+// same alias - the underlying rclone VFS rename is a same-backend
+// operation, so moving between aliases (or between two different backend
+// types mounted under the same aggregate tree) would need a copy-then-
+// delete this method deliberately doesn't attempt silently.
+func (afs *aggregateFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldAlias, oldRel := splitDAVPath(oldName)
+	newAlias, newRel := splitDAVPath(newName)
+	if oldAlias == "" || newAlias == "" || oldRel == "" {
+		return os.ErrInvalid
+	}
+	if oldAlias != newAlias {
+		return fmt.Errorf("webdav: moving between remotes (%q -> %q) is not supported", oldAlias, newAlias)
+	}
+	if afs.isProtectedPath(oldRel, newRel) {
+		return os.ErrPermission
+	}
+	creds, err := afs.credsFor(oldAlias)
+	if err != nil {
+		return translateErr(err)
+	}
+	return translateErr(dataset_source.Move(ctx, oldRel, newRel, creds))
+}
+
+func (afs *aggregateFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	alias, relPath := splitDAVPath(name)
+	if alias == "" {
+		return dirInfo{name: "/"}, nil
+	}
+	creds, err := afs.credsFor(alias)
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	if relPath == "" {
+		return dirInfo{name: alias}, nil
+	}
+	if afs.isProtectedPath(relPath) {
+		return nil, os.ErrPermission
+	}
+	info, err := statEntry(ctx, relPath, creds)
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	return info, nil
+}
+
+func (afs *aggregateFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	alias, relPath := splitDAVPath(name)
+
+	if alias == "" {
+		aliases, err := afs.secrets.ListAliases()
+		if err != nil {
+			return nil, err
+		}
+		// A scoped token only sees the aliases it can at least `list`; an
+		// unauthenticated mount (local-only/oidc protecting /dav instead of
+		// the API-token layer) sees every alias.
+		if info, ok := apiTokenFromContext(ctx); ok {
+			filtered := aliases[:0]
+			for _, a := range aliases {
+				if info.Allows(a, apitoken.PermissionList) {
+					filtered = append(filtered, a)
+				}
+			}
+			aliases = filtered
+		}
+		entries := make([]os.FileInfo, len(aliases))
+		for i, a := range aliases {
+			entries[i] = dirInfo{name: a}
+		}
+		return &davFile{isDir: true, entries: entries, info: dirInfo{name: "/"}}, nil
+	}
+
+	if afs.isProtectedPath(relPath) {
+		return nil, os.ErrPermission
+	}
+
+	creds, err := afs.credsFor(alias)
+	if err != nil {
+		return nil, translateErr(err)
+	}
+
+	if relPath == "" {
+		entries, err := dataset_source.ListEntries(ctx, "", creds)
+		if err != nil {
+			return nil, translateErr(err)
+		}
+		return &davFile{fs: afs, alias: alias, isDir: true, entries: entries, info: dirInfo{name: alias}}, nil
+	}
+
+	info, statErr := statEntry(ctx, relPath, creds)
+	if statErr != nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, translateErr(statErr)
+		}
+		// A new upload: defer the destination's existence to Close, which
+		// flushes the buffered write through dataset_source.PutFileStream -
+		// rclone's Put wants one io.Reader for the whole object rather
+		// than a series of partial writes.
+		return &davFile{fs: afs, alias: alias, relPath: relPath, writable: true, writeBuf: &bytes.Buffer{}}, nil
+	}
+
+	if info.IsDir() {
+		dirEntry := info.(dirInfo)
+		return &davFile{fs: afs, alias: alias, relPath: relPath, isDir: true, entries: dirEntry.entries, info: info}, nil
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return &davFile{fs: afs, alias: alias, relPath: relPath, writable: true, writeBuf: &bytes.Buffer{}, info: info}, nil
+	}
+
+	rc, err := dataset_source.GetFileStream(ctx, relPath, creds)
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	return &davFile{fs: afs, alias: alias, relPath: relPath, rc: rc, info: info}, nil
+}
+
+// davFile implements webdav.File (http.File plus io.Writer) for one opened
+// name. A directory carries a pre-listed entries slice for Readdir; a file
+// opened for read wraps a dataset_source stream and re-opens it through
+// GetFileRange on Seek, since the underlying backend stream isn't
+// seekable itself; a file opened for write buffers in memory until Close.
+type davFile struct {
+	fs      *aggregateFS
+	alias   string
+	relPath string
+	info    os.FileInfo
+
+	isDir   bool
+	entries []os.FileInfo
+	dirPos  int
+
+	rc  io.ReadCloser
+	pos int64
+
+	writable bool
+	writeBuf *bytes.Buffer
+}
+
+func (f *davFile) Close() error {
+	if f.writable {
+		creds, err := f.fs.credsFor(f.alias)
+		if err != nil {
+			return translateErr(err)
+		}
+		if err := dataset_source.PutFileStream(context.Background(), f.relPath, creds, bytes.NewReader(f.writeBuf.Bytes())); err != nil {
+			return translateErr(err)
+		}
+	}
+	if f.rc != nil {
+		return f.rc.Close()
+	}
+	return nil
+}
+
+func (f *davFile) Read(p []byte) (int, error) {
+	if f.isDir {
+		return 0, fmt.Errorf("webdav: cannot read a directory")
+	}
+	if f.rc == nil {
+		return 0, io.EOF
+	}
+	n, err := f.rc.Read(p)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *davFile) Write(p []byte) (int, error) {
+	if !f.writable {
+		return 0, os.ErrPermission
+	}
+	return f.writeBuf.Write(p)
+}
+
+// Seek supports webdav.Handler's range-GET path, which seeks before
+// reading rather than reading and discarding. Anything but a no-op seek
+// re-opens the backend stream at the new offset via GetFileRange.
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	if f.isDir || f.info == nil {
+		return 0, os.ErrInvalid
+	}
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = f.pos + offset
+	case io.SeekEnd:
+		newPos = f.info.Size() + offset
+	default:
+		return 0, fmt.Errorf("webdav: invalid whence %d", whence)
+	}
+	if newPos == f.pos {
+		return f.pos, nil
+	}
+
+	creds, err := f.fs.credsFor(f.alias)
+	if err != nil {
+		return 0, translateErr(err)
+	}
+	if f.rc != nil {
+		f.rc.Close()
+	}
+	rc, err := dataset_source.GetFileRange(context.Background(), f.relPath, newPos, -1, creds)
+	if err != nil {
+		return 0, translateErr(err)
+	}
+	f.rc = rc
+	f.pos = newPos
+	return f.pos, nil
+}
+
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.isDir {
+		return nil, fmt.Errorf("webdav: not a directory")
+	}
+	if count <= 0 {
+		rest := f.entries[f.dirPos:]
+		f.dirPos = len(f.entries)
+		return rest, nil
+	}
+	if f.dirPos >= len(f.entries) {
+		return nil, io.EOF
+	}
+	end := f.dirPos + count
+	if end > len(f.entries) {
+		end = len(f.entries)
+	}
+	rest := f.entries[f.dirPos:end]
+	f.dirPos = end
+	return rest, nil
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+	return nil, fmt.Errorf("webdav: no info for %s/%s", f.alias, f.relPath)
+}
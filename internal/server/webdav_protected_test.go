@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flight2/internal/secrets"
+)
+
+// newProtectedTestFS builds an aggregateFS backed by a real secrets.Service
+// with alias "dav-test" registered, and isProtectedPath wired to block
+// relPath against blockedPattern - enough to exercise the permission check
+// every aggregateFS write/read method must apply before it ever reaches
+// dataset_source, without needing a working backend behind the alias.
+func newProtectedTestFS(t *testing.T, blockedPattern string) *aggregateFS {
+	t.Helper()
+
+	dir := t.TempDir()
+	ss, err := secrets.NewService(filepath.Join(dir, "secrets.db"), filepath.Join(dir, ".secret.key"))
+	if err != nil {
+		t.Fatalf("secrets.NewService: %v", err)
+	}
+	t.Cleanup(func() { ss.Close() })
+
+	if _, err := ss.StoreCredentials("dav-test", map[string]interface{}{"type": "local"}); err != nil {
+		t.Fatalf("StoreCredentials: %v", err)
+	}
+
+	isProtectedPath := func(candidates ...string) bool {
+		for _, c := range candidates {
+			if ok, _ := filepath.Match(blockedPattern, c); ok {
+				return true
+			}
+		}
+		return false
+	}
+	return newAggregateFS(ss, isProtectedPath)
+}
+
+func TestAggregateFS_Mkdir_BlocksProtectedPath(t *testing.T) {
+	afs := newProtectedTestFS(t, "secrets.db")
+
+	if err := afs.Mkdir(context.Background(), "/dav-test/secrets.db", 0755); err != os.ErrPermission {
+		t.Fatalf("Mkdir on protected path = %v, want os.ErrPermission", err)
+	}
+}
+
+func TestAggregateFS_RemoveAll_BlocksProtectedPath(t *testing.T) {
+	afs := newProtectedTestFS(t, "secrets.db")
+
+	if err := afs.RemoveAll(context.Background(), "/dav-test/secrets.db"); err != os.ErrPermission {
+		t.Fatalf("RemoveAll on protected path = %v, want os.ErrPermission", err)
+	}
+}
+
+func TestAggregateFS_Rename_BlocksProtectedPath(t *testing.T) {
+	afs := newProtectedTestFS(t, "secrets.db")
+
+	if err := afs.Rename(context.Background(), "/dav-test/secrets.db", "/dav-test/renamed.db"); err != os.ErrPermission {
+		t.Fatalf("Rename from protected path = %v, want os.ErrPermission", err)
+	}
+	if err := afs.Rename(context.Background(), "/dav-test/public.txt", "/dav-test/secrets.db"); err != os.ErrPermission {
+		t.Fatalf("Rename onto protected path = %v, want os.ErrPermission", err)
+	}
+}
+
+func TestAggregateFS_OpenFile_BlocksProtectedPath(t *testing.T) {
+	afs := newProtectedTestFS(t, "secrets.db")
+
+	if _, err := afs.OpenFile(context.Background(), "/dav-test/secrets.db", os.O_RDONLY, 0); err != os.ErrPermission {
+		t.Fatalf("OpenFile(GET) on protected path = %v, want os.ErrPermission", err)
+	}
+	if _, err := afs.OpenFile(context.Background(), "/dav-test/secrets.db", os.O_WRONLY|os.O_CREATE, 0644); err != os.ErrPermission {
+		t.Fatalf("OpenFile(PUT) on protected path = %v, want os.ErrPermission", err)
+	}
+}
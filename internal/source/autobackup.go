@@ -0,0 +1,417 @@
+package source
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/klauspost/compress/zstd"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Compression selects how AutoBackup compresses a snapshot before upload.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// backupTimestampFormat names each snapshot so lexical and chronological
+// order coincide, the same trick dataset's cache uses for content-addressed
+// file names: Retention can then just sort the remote directory listing and
+// drop everything but the last N entries.
+const backupTimestampFormat = "20060102T150405"
+
+// AutoBackupConfig configures one AutoBackup's periodic snapshots of a
+// local SQLite file to a remote via PutFileStream.
+type AutoBackupConfig struct {
+	// DBPath is the local SQLite database file to snapshot.
+	DBPath string
+
+	// Dest names where snapshots land: Dest.Path is treated as a
+	// directory prefix, not a file - each run appends its own
+	// timestamped filename before resolving through the registry.
+	Dest SourceRef
+
+	// Interval is how often a snapshot attempt runs.
+	Interval time.Duration
+
+	// MinChangeBytes, when > 0, enables skip-if-unchanged: a run whose
+	// snapshot sha256 matches the previous run's is dropped without an
+	// upload. Named for the rqlite option it mirrors, though the check
+	// itself is a digest comparison rather than a byte-count threshold.
+	MinChangeBytes int64
+
+	// Retention keeps the most recent N snapshots in Dest's directory,
+	// deleting older ones after a successful upload. <= 0 keeps all of
+	// them.
+	Retention int
+
+	// VacuumInto snapshots via "VACUUM INTO" instead of a raw file copy,
+	// so a writer holding the database open doesn't block the backup (or
+	// get blocked by it) and the snapshot is always transaction-consistent.
+	VacuumInto bool
+
+	// Compression picks how the snapshot is compressed before upload.
+	// Empty behaves like CompressionNone.
+	Compression Compression
+
+	// AgeRecipients, when non-empty, age-encrypts the (possibly
+	// compressed) snapshot to these recipients before upload, appending
+	// ".age" to the uploaded file name. Empty disables encryption.
+	AgeRecipients []age.Recipient
+}
+
+// BackupStatus reports an AutoBackup's most recent run, for surfacing
+// through the server the way index.CrawlStatus reports a crawl's progress.
+type BackupStatus struct {
+	LastRun       time.Time `json:"last_run,omitempty"`
+	BytesUploaded int64     `json:"bytes_uploaded"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// AutoBackup periodically snapshots a local SQLite database and uploads it
+// to a remote through the default registry, started with StartAutoBackup.
+type AutoBackup struct {
+	cfg AutoBackupConfig
+
+	statusMu sync.Mutex
+	status   BackupStatus
+	lastHash string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartAutoBackup validates cfg and starts a background goroutine that
+// snapshots cfg.DBPath every cfg.Interval until ctx is done or Stop is
+// called. The first snapshot attempt runs after the first tick, not
+// immediately, mirroring dataset.Manager's disk sweeper.
+func StartAutoBackup(ctx context.Context, cfg AutoBackupConfig) (*AutoBackup, error) {
+	if cfg.DBPath == "" {
+		return nil, fmt.Errorf("source: autobackup: DBPath is required")
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("source: autobackup: Interval must be > 0")
+	}
+	if cfg.Compression == "" {
+		cfg.Compression = CompressionNone
+	}
+
+	ab := &AutoBackup{
+		cfg:  cfg,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go ab.loop(ctx)
+	return ab, nil
+}
+
+// Stop signals the background loop to exit and waits for it to finish the
+// run it may be in the middle of. Safe to call once.
+func (ab *AutoBackup) Stop() {
+	close(ab.stop)
+	<-ab.done
+}
+
+// BackupStatus returns the most recently completed run's result.
+func (ab *AutoBackup) BackupStatus() BackupStatus {
+	ab.statusMu.Lock()
+	defer ab.statusMu.Unlock()
+	return ab.status
+}
+
+func (ab *AutoBackup) loop(ctx context.Context) {
+	defer close(ab.done)
+
+	ticker := time.NewTicker(ab.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ab.runOnce(ctx)
+		case <-ab.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runOnce takes one snapshot, uploads it unless MinChangeBytes says to skip,
+// applies Retention, and records the result in ab.status regardless of
+// outcome - a failed run is still a run, so BackupStatus can surface it.
+func (ab *AutoBackup) runOnce(ctx context.Context) {
+	status := BackupStatus{LastRun: time.Now()}
+	defer func() {
+		ab.statusMu.Lock()
+		ab.status = status
+		ab.statusMu.Unlock()
+	}()
+
+	snapshotPath, err := ab.snapshot(ctx)
+	if err != nil {
+		status.Error = fmt.Sprintf("snapshot: %v", err)
+		return
+	}
+	defer os.Remove(snapshotPath)
+
+	sum, err := sha256File(snapshotPath)
+	if err != nil {
+		status.Error = fmt.Sprintf("hash snapshot: %v", err)
+		return
+	}
+	if ab.cfg.MinChangeBytes > 0 && sum == ab.lastHash {
+		return
+	}
+
+	packedPath, name, err := ab.pack(snapshotPath)
+	if err != nil {
+		status.Error = fmt.Sprintf("compress snapshot: %v", err)
+		return
+	}
+	defer os.Remove(packedPath)
+
+	if len(ab.cfg.AgeRecipients) > 0 {
+		encPath, err := ab.encrypt(packedPath)
+		if err != nil {
+			status.Error = fmt.Sprintf("encrypt snapshot: %v", err)
+			return
+		}
+		os.Remove(packedPath)
+		packedPath, name = encPath, name+".age"
+		defer os.Remove(packedPath)
+	}
+
+	f, err := os.Open(packedPath)
+	if err != nil {
+		status.Error = fmt.Sprintf("open packed snapshot: %v", err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		status.Error = fmt.Sprintf("stat packed snapshot: %v", err)
+		return
+	}
+
+	ref := ab.cfg.Dest
+	ref.Path = path.Join(ref.Path, name)
+	if err := PutFileStream(ctx, ref, f); err != nil {
+		status.Error = fmt.Sprintf("upload: %v", err)
+		return
+	}
+
+	status.BytesUploaded = info.Size()
+	ab.lastHash = sum
+
+	if ab.cfg.Retention > 0 {
+		if err := ab.applyRetention(ctx); err != nil {
+			log.Printf("autobackup: retention cleanup failed: %v", err)
+		}
+	}
+}
+
+// snapshot writes a point-in-time copy of cfg.DBPath to a fresh temp file,
+// via "VACUUM INTO" when VacuumInto is set (so concurrent writers aren't
+// blocked and the copy is transaction-consistent) or a raw file copy
+// otherwise. The caller owns the returned path and must remove it.
+func (ab *AutoBackup) snapshot(ctx context.Context) (string, error) {
+	tmp, err := os.CreateTemp("", "flight2-backup-*.db")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	if !ab.cfg.VacuumInto {
+		if err := copyFile(ab.cfg.DBPath, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return "", err
+		}
+		return tmpPath, nil
+	}
+
+	// VACUUM INTO refuses to write over an existing file.
+	if err := os.Remove(tmpPath); err != nil {
+		return "", err
+	}
+
+	db, err := sql.Open("sqlite3", ab.cfg.DBPath)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("VACUUM INTO '%s'", tmpPath)); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// pack compresses snapshotPath per cfg.Compression into a new temp file and
+// returns its path plus the remote file name it should be uploaded as.
+func (ab *AutoBackup) pack(snapshotPath string) (packedPath, name string, err error) {
+	stamp := time.Now().UTC().Format(backupTimestampFormat)
+	base := fmt.Sprintf("flight2-%s.db", stamp)
+
+	switch ab.cfg.Compression {
+	case CompressionGzip:
+		return compressWith(snapshotPath, base+".gz", func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		})
+	case CompressionZstd:
+		return compressWith(snapshotPath, base+".zst", func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		})
+	default:
+		return snapshotPath, base, nil
+	}
+}
+
+// compressWith streams snapshotPath through newEncoder into a fresh temp
+// file named name, returning the temp file's path.
+func compressWith(snapshotPath, name string, newEncoder func(io.Writer) (io.WriteCloser, error)) (string, string, error) {
+	src, err := os.Open(snapshotPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "flight2-backup-packed-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer dst.Close()
+
+	enc, err := newEncoder(dst)
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", "", err
+	}
+	if _, err := io.Copy(enc, src); err != nil {
+		enc.Close()
+		os.Remove(dst.Name())
+		return "", "", err
+	}
+	if err := enc.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", "", err
+	}
+	return dst.Name(), name, nil
+}
+
+// encrypt age-encrypts packedPath to cfg.AgeRecipients, returning the path
+// of a fresh temp file holding the ciphertext.
+func (ab *AutoBackup) encrypt(packedPath string) (string, error) {
+	src, err := os.Open(packedPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp("", "flight2-backup-enc-*")
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	w, err := age.Encrypt(dst, ab.cfg.AgeRecipients...)
+	if err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(dst.Name())
+		return "", err
+	}
+	return dst.Name(), nil
+}
+
+// applyRetention lists Dest's directory and removes the oldest snapshots
+// past cfg.Retention, relying on backupTimestampFormat sorting lexically in
+// chronological order.
+func (ab *AutoBackup) applyRetention(ctx context.Context) error {
+	dirRef := ab.cfg.Dest
+	entries, err := ListEntries(ctx, dirRef)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) <= ab.cfg.Retention {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range names[:len(names)-ab.cfg.Retention] {
+		ref := ab.cfg.Dest
+		ref.Path = path.Join(dirRef.Path, name)
+		if err := Remove(ctx, ref, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func sha256File(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
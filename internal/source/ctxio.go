@@ -0,0 +1,55 @@
+package source
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// ctxReadCloser wraps an io.ReadCloser so ctx cancellation aborts an
+// in-flight read instead of blocking until the backend itself gives up (or
+// never does, for a stalled network remote). A background goroutine closes
+// the underlying reader as soon as ctx is done, which unblocks whatever
+// Read call is in progress; Read itself also short-circuits on ctx.Err()
+// so a caller that races a cancellation against a completed read still
+// sees the cancellation.
+type ctxReadCloser struct {
+	ctx context.Context
+	rc  io.ReadCloser
+
+	closeOnce sync.Once
+	stop      chan struct{}
+}
+
+// newCtxReadCloser returns rc wrapped so ctx.Done() aborts reads still in
+// flight. If ctx is context.Background() (or otherwise never cancels),
+// this is a harmless passthrough.
+func newCtxReadCloser(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	c := &ctxReadCloser{ctx: ctx, rc: rc, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.rc.Close()
+		case <-c.stop:
+		}
+	}()
+	return c
+}
+
+func (c *ctxReadCloser) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.rc.Read(p)
+	if err == nil {
+		if cerr := c.ctx.Err(); cerr != nil {
+			return n, cerr
+		}
+	}
+	return n, err
+}
+
+func (c *ctxReadCloser) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.rc.Close()
+}
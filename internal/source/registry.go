@@ -0,0 +1,570 @@
+package source
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// ErrReadOnlyBackend is wrapped into whatever a write-path method
+// (PutFileStream, Mkdir, Move, Remove) returns when the underlying fs.Fs
+// rejected the call as unsupported, so callers can detect a read-only
+// backend (e.g. a plain HTTP remote) and degrade gracefully instead of
+// surfacing a raw rclone error.
+var ErrReadOnlyBackend = errors.New("source: backend does not support this write operation")
+
+// wrapWriteErr folds err into ErrReadOnlyBackend when it's rclone's way of
+// saying the backend doesn't implement the attempted mutation.
+func wrapWriteErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, fs.ErrorNotImplemented) || errors.Is(err, fs.ErrorPermissionDenied) {
+		return fmt.Errorf("%w: %v", ErrReadOnlyBackend, err)
+	}
+	return err
+}
+
+// putCopyBufferSize sizes the buffer PutFileStream copies through;
+// overridable via SetPutCopyBufferSize for callers streaming very large or
+// very small files over a high-latency backend.
+var putCopyBufferSize = 1 << 20 // 1MB
+
+// SetPutCopyBufferSize overrides the buffer size PutFileStream uses to
+// copy into the destination VFS file. n <= 0 is ignored.
+func SetPutCopyBufferSize(n int) {
+	if n > 0 {
+		putCopyBufferSize = n
+	}
+}
+
+// vfsWriteOptions holds the operator-configurable subset of
+// vfscommon.Options that controls how aggressively the VFS cache writes
+// dirty files back to their backend, set via SetVFSWriteOptions. Zero
+// values leave rclone's own vfscommon defaults in place.
+var vfsWriteOptions struct {
+	mu        sync.Mutex
+	writeBack time.Duration
+	writeWait time.Duration
+}
+
+// SetVFSWriteOptions overrides how VFS write-back behaves for every VFS
+// resolve() creates from this point on (already-cached VFS instances are
+// unaffected - call before the first request touches a given profile, or
+// accept that existing cache entries keep the old behavior until evicted).
+// writeBack delays writing a dirty file back until it's been untouched for
+// that long, batching rapid appends into a single upload; vfsWriteWait
+// bounds how long closing a file waits for that write-back to finish.
+func SetVFSWriteOptions(writeBack, vfsWriteWait time.Duration) {
+	vfsWriteOptions.mu.Lock()
+	defer vfsWriteOptions.mu.Unlock()
+	vfsWriteOptions.writeBack = writeBack
+	vfsWriteOptions.writeWait = vfsWriteWait
+}
+
+// SourceRef names one piece of remote content to resolve through a
+// BackendRegistry. Backend and Remote are optional overrides: when empty,
+// Backend falls back to the registered profile's "type" field and Remote is
+// derived from Path the same way the original single-VFS implementation
+// derived fsRoot (domain for http(s), "/" for local, backend root for
+// everything else).
+type SourceRef struct {
+	Backend   string
+	Remote    string
+	Path      string
+	ProfileID string
+}
+
+// profileEntry is one registered credential profile: the raw creds map
+// (handed to the backend's NewFs as a configmap) plus a hash of it so
+// RegisterProfile can tell a no-op re-registration from an actual
+// rotation without comparing maps field-by-field.
+type profileEntry struct {
+	creds map[string]interface{}
+	hash  string
+}
+
+// BackendRegistry holds per-profile credentials and the fs.Fs/VFS instances
+// built from them, so two callers (e.g. two users' R2 tokens) never share a
+// VFS cache entry just because they happened to hash the same sourcePath.
+// Instances are keyed by ProfileID, not by credential content, which is
+// what makes a rotated secret take effect: RegisterProfile evicts a
+// profile's cached instances as soon as its credential hash changes.
+type BackendRegistry struct {
+	mu       sync.Mutex
+	profiles map[string]profileEntry
+	vfsCache map[string]*vfsCacheEntry
+
+	janitorStop chan struct{}
+	janitorDone chan struct{}
+}
+
+// vfsCacheEntry tracks a cached VFS instance alongside when it was last
+// resolved, so the janitor can tell an idle backend (no requests in
+// idleTTL) from one that's just quiet between bursts.
+type vfsCacheEntry struct {
+	vfs      *vfs.VFS
+	lastUsed time.Time
+}
+
+// NewBackendRegistry returns an empty registry ready for RegisterProfile
+// calls.
+func NewBackendRegistry() *BackendRegistry {
+	return &BackendRegistry{
+		profiles: make(map[string]profileEntry),
+		vfsCache: make(map[string]*vfsCacheEntry),
+	}
+}
+
+func credsHash(creds map[string]interface{}) string {
+	keys := make([]string, 0, len(creds))
+	for k := range creds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := md5.New()
+	for _, k := range keys {
+		io.WriteString(h, k)
+		io.WriteString(h, fmt.Sprint(creds[k]))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RegisterProfile associates profileID with creds (the same "type" +
+// backend-specific fields map secrets.Service.GetCredentials returns).
+// Callers are expected to call this on every request with whatever
+// credentials they just loaded; it's a no-op unless creds actually changed
+// since the last call, in which case it evicts profileID's cached fs.Fs/VFS
+// instances so the next resolve rebuilds them - the mechanism that makes a
+// secrets.Service credential rotation visible without a separate hook.
+func (r *BackendRegistry) RegisterProfile(profileID string, creds map[string]interface{}) {
+	hash := credsHash(creds)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.profiles[profileID]; ok && existing.hash == hash {
+		return
+	}
+	r.profiles[profileID] = profileEntry{creds: creds, hash: hash}
+	r.evictLocked(profileID)
+}
+
+// InvalidateProfile forgets profileID's credentials and evicts its cached
+// fs.Fs/VFS instances. Safe to call even if profileID was never registered.
+func (r *BackendRegistry) InvalidateProfile(profileID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.profiles, profileID)
+	r.evictLocked(profileID)
+}
+
+// evictLocked shuts down and drops every cached VFS instance belonging to
+// profileID, so a credential rotation doesn't just orphan the old
+// instance's background writeback/cache-expiry goroutines. Callers must
+// hold r.mu.
+func (r *BackendRegistry) evictLocked(profileID string) {
+	prefix := profileID + "|"
+	for key, entry := range r.vfsCache {
+		if strings.HasPrefix(key, prefix) {
+			entry.vfs.Shutdown()
+			delete(r.vfsCache, key)
+		}
+	}
+}
+
+// StartJanitor launches a background goroutine that evicts (Shutdown +
+// CleanUp) any cached VFS instance untouched for longer than idleTTL,
+// checking every interval, until ctx is done or Close is called. A no-op
+// if interval or idleTTL is <= 0, or if a janitor is already running.
+func (r *BackendRegistry) StartJanitor(ctx context.Context, interval, idleTTL time.Duration) {
+	if interval <= 0 || idleTTL <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	if r.janitorStop != nil {
+		r.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	r.janitorStop, r.janitorDone = stop, done
+	r.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.evictIdle(idleTTL)
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// evictIdle drops (Shutdown + CleanUp) every cached VFS instance whose
+// lastUsed is older than idleTTL.
+func (r *BackendRegistry) evictIdle(idleTTL time.Duration) {
+	cutoff := time.Now().Add(-idleTTL)
+
+	r.mu.Lock()
+	var stale []*vfs.VFS
+	for key, entry := range r.vfsCache {
+		if entry.lastUsed.Before(cutoff) {
+			stale = append(stale, entry.vfs)
+			delete(r.vfsCache, key)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, v := range stale {
+		v.Shutdown()
+		v.CleanUp()
+	}
+}
+
+// stopJanitor signals a running janitor to exit and waits for it.
+// Callers must NOT hold r.mu (the janitor's own evictIdle needs it).
+func (r *BackendRegistry) stopJanitor() {
+	r.mu.Lock()
+	stop, done := r.janitorStop, r.janitorDone
+	r.janitorStop, r.janitorDone = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Close stops the janitor (if running) and shuts down every cached VFS
+// instance so their background writeback/cache-expiry goroutines stop,
+// then empties the VFS cache; registered profiles are kept. Intended for
+// process shutdown; a registry is
+// not usable after Close.
+func (r *BackendRegistry) Close() {
+	r.stopJanitor()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, entry := range r.vfsCache {
+		entry.vfs.Shutdown()
+		delete(r.vfsCache, key)
+	}
+}
+
+// ProfileCount returns the number of credential profiles currently
+// registered, for readiness checks that want to confirm at least one
+// backend has been configured.
+func (r *BackendRegistry) ProfileCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.profiles)
+}
+
+// resolve returns the VFS instance and in-VFS relative path for ref,
+// creating and caching a fresh fs.Fs-backed VFS the first time ref's
+// profile+root combination is seen.
+func (r *BackendRegistry) resolve(ctx context.Context, ref SourceRef) (*vfs.VFS, string, error) {
+	r.mu.Lock()
+	entry, ok := r.profiles[ref.ProfileID]
+	r.mu.Unlock()
+	if !ok {
+		return nil, "", fmt.Errorf("source: profile %q not registered; call RegisterProfile first", ref.ProfileID)
+	}
+	creds := entry.creds
+
+	fsType := ref.Backend
+	if fsType == "" {
+		fsType, _ = creds["type"].(string)
+	}
+	if fsType == "union" {
+		upstreams, err := parseUnionUpstreams(creds["upstreams"])
+		if err != nil {
+			return nil, "", fmt.Errorf("source: union profile %q: %w", ref.ProfileID, err)
+		}
+		childRef, err := resolveUnionRef(ref, upstreams)
+		if err != nil {
+			return nil, "", fmt.Errorf("source: union profile %q: %w", ref.ProfileID, err)
+		}
+		return r.resolve(ctx, childRef)
+	}
+	if fsType == "" {
+		if strings.HasPrefix(ref.Path, "http:") || strings.HasPrefix(ref.Path, "https:") {
+			fsType = "http"
+		} else {
+			return nil, "", fmt.Errorf("source: profile %q has no backend type and %q isn't an http(s) URL", ref.ProfileID, ref.Path)
+		}
+	}
+
+	// Determine FS Root and Relative Path based on type
+	var fsRoot string
+	var relPath string
+
+	switch fsType {
+	case "local":
+		// For local, we map the VFS to the system root /
+		fsRoot = "/"
+		if abs, err := filepath.Abs(ref.Path); err == nil {
+			relPath = abs
+		} else {
+			relPath = ref.Path
+		}
+		// Provide cleaner relative path for VFS: remove leading slash
+		relPath = strings.TrimPrefix(relPath, "/")
+
+	case "http", "https":
+		// For HTTP, we try to root at the domain
+		u, err := url.Parse(ref.Path)
+		if err == nil {
+			fsRoot = u.Scheme + "://" + u.Host
+			relPath = strings.TrimPrefix(u.Path, "/")
+		} else {
+			// Fallback
+			fsRoot = path.Dir(ref.Path)
+			relPath = path.Base(ref.Path)
+		}
+	default:
+		// Cloud providers (S3, Drive, etc): root at the caller-supplied
+		// remote, or the backend root ("") if they didn't give one.
+		fsRoot = ref.Remote
+		relPath = strings.TrimPrefix(ref.Path, "/")
+	}
+
+	cacheKey := ref.ProfileID + "|" + fsType + "|" + fsRoot
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if entry, ok := r.vfsCache[cacheKey]; ok {
+		entry.lastUsed = time.Now()
+		return entry.vfs, relPath, nil
+	}
+
+	conf := make(configmap.Simple)
+	for k, v := range creds {
+		if k != "type" {
+			conf[k] = fmt.Sprint(v)
+		}
+	}
+
+	regInfo, err := fs.Find(fsType)
+	if err != nil {
+		return nil, "", fmt.Errorf("backend type '%s' not found: %w", fsType, err)
+	}
+
+	remoteNameSum := md5.Sum([]byte(cacheKey))
+	remoteName := fmt.Sprintf("flight2_%s", hex.EncodeToString(remoteNameSum[:]))
+	fsrc, err := regInfo.NewFs(ctx, remoteName, fsRoot, conf)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create fs: %w", err)
+	}
+
+	opt := &vfscommon.Options{
+		CacheMode:         vfscommon.CacheModeFull,
+		DirCacheTime:      fs.Duration(10 * time.Minute),
+		CacheMaxAge:       fs.Duration(24 * time.Hour),
+		CachePollInterval: fs.Duration(1 * time.Minute),
+		ChunkSize:         fs.SizeSuffix(128 * 1024 * 1024),
+	}
+
+	vfsWriteOptions.mu.Lock()
+	if vfsWriteOptions.writeBack > 0 {
+		opt.WriteBack = vfsWriteOptions.writeBack
+	}
+	if vfsWriteOptions.writeWait > 0 {
+		opt.WriteWait = vfsWriteOptions.writeWait
+	}
+	vfsWriteOptions.mu.Unlock()
+
+	v := vfs.New(fsrc, opt)
+	r.vfsCache[cacheKey] = &vfsCacheEntry{vfs: v, lastUsed: time.Now()}
+
+	return v, relPath, nil
+}
+
+// GetFileStream opens ref for reading through its profile's VFS.
+func (r *BackendRegistry) GetFileStream(ctx context.Context, ref SourceRef) (io.ReadCloser, error) {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := v.OpenFile(relPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", relPath, err)
+	}
+	return newCtxReadCloser(ctx, f), nil
+}
+
+// GetFileRange opens ref through its profile's VFS, seeks to offset, and
+// returns a ReadCloser limited to length bytes (or to EOF if length < 0).
+// Backends with a native ranged GET (S3, GCS, SFTP, ...) serve this
+// directly at the seeked offset through rclone's VFS rather than
+// downloading the whole object first - the same property GetFileStream
+// already relies on for large files.
+func (r *BackendRegistry) GetFileRange(ctx context.Context, ref SourceRef, offset, length int64) (io.ReadCloser, error) {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := v.OpenFile(relPath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file '%s': %w", relPath, err)
+	}
+
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to seek file '%s' to offset %d: %w", relPath, offset, err)
+		}
+	}
+
+	if length < 0 {
+		return newCtxReadCloser(ctx, f), nil
+	}
+	limited := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}
+	return newCtxReadCloser(ctx, limited), nil
+}
+
+// PutFileStream writes r to ref through its profile's VFS, creating or
+// truncating the destination. Callers are responsible for closing r if it
+// needs closing; PutFileStream only closes the destination handle it opens.
+func (r *BackendRegistry) PutFileStream(ctx context.Context, ref SourceRef, src io.Reader) error {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	f, err := v.OpenFile(relPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return wrapWriteErr(fmt.Errorf("failed to open file '%s' for write: %w", relPath, err))
+	}
+	buf := make([]byte, putCopyBufferSize)
+	if _, err := io.CopyBuffer(f, src, buf); err != nil {
+		f.Close()
+		return wrapWriteErr(fmt.Errorf("failed to write file '%s': %w", relPath, err))
+	}
+	return wrapWriteErr(f.Close())
+}
+
+// Mkdir creates ref's path as a directory through its profile's VFS,
+// including any missing parents.
+func (r *BackendRegistry) Mkdir(ctx context.Context, ref SourceRef) error {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := v.Mkdir(relPath); err != nil {
+		return wrapWriteErr(fmt.Errorf("failed to create directory '%s': %w", relPath, err))
+	}
+	return nil
+}
+
+// Move renames ref's path to newPath within the same profile's VFS. A
+// cross-profile move isn't possible through a single vfs.VFS.Rename call,
+// so callers that want to move content between aliases (or between
+// backends of the same alias) need to copy via GetFileStream/PutFileStream
+// and then Remove the source themselves.
+func (r *BackendRegistry) Move(ctx context.Context, ref SourceRef, newPath string) error {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := v.Rename(relPath, newPath); err != nil {
+		return wrapWriteErr(fmt.Errorf("failed to move '%s' to '%s': %w", relPath, newPath, err))
+	}
+	return nil
+}
+
+// Remove deletes ref through its profile's VFS. recursive controls whether
+// a non-empty directory is removed along with its contents (RemoveAll) or
+// rejected (Remove) - listingLogic's "delete folder" action sets it
+// explicitly rather than defaulting to the destructive form.
+func (r *BackendRegistry) Remove(ctx context.Context, ref SourceRef, recursive bool) error {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if recursive {
+		if err := v.RemoveAll(relPath); err != nil {
+			return wrapWriteErr(fmt.Errorf("failed to remove '%s': %w", relPath, err))
+		}
+		return nil
+	}
+	if err := v.Remove(relPath); err != nil {
+		return wrapWriteErr(fmt.Errorf("failed to remove '%s': %w", relPath, err))
+	}
+	return nil
+}
+
+// ListEntries lists ref's directory through its profile's VFS. For a
+// "union" profile whose relPath sits at or above one or more upstream
+// mount points, it merges and deduplicates each matching upstream's
+// listing instead of resolving to a single backend - see listUnion.
+func (r *BackendRegistry) ListEntries(ctx context.Context, ref SourceRef) ([]os.FileInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	entry, hasProfile := r.profiles[ref.ProfileID]
+	r.mu.Unlock()
+	if hasProfile {
+		if fsType, _ := entry.creds["type"].(string); fsType == "union" {
+			upstreams, err := parseUnionUpstreams(entry.creds["upstreams"])
+			if err != nil {
+				return nil, fmt.Errorf("source: union profile %q: %w", ref.ProfileID, err)
+			}
+			if infos, handled, err := r.listUnion(ctx, ref, upstreams); handled {
+				return infos, err
+			}
+		}
+	}
+
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if relPath == "" {
+		relPath = "."
+	}
+
+	infos, err := v.ReadDir(relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory '%s': %w", relPath, err)
+	}
+
+	return infos, nil
+}
@@ -0,0 +1,86 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+// Type: Unit Test
+func TestBackendRegistry_WriteRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+	}{
+		{name: "local backend", backend: "local"},
+		{name: "memory backend", backend: "memory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			reg := NewBackendRegistry()
+			t.Cleanup(reg.Close)
+
+			profileID := "test-" + tt.backend
+			reg.RegisterProfile(profileID, map[string]interface{}{"type": tt.backend})
+
+			dirPath := "roundtrip"
+			filePath := "roundtrip/hello.txt"
+			if tt.backend == "local" {
+				dir := t.TempDir()
+				dirPath = filepath.Join(dir, "roundtrip")
+				filePath = filepath.Join(dirPath, "hello.txt")
+			}
+
+			if err := reg.Mkdir(ctx, SourceRef{ProfileID: profileID, Path: dirPath}); err != nil {
+				t.Fatalf("Mkdir: %v", err)
+			}
+
+			const content = "hello, world"
+			fileRef := SourceRef{ProfileID: profileID, Path: filePath}
+			if err := reg.PutFileStream(ctx, fileRef, bytes.NewReader([]byte(content))); err != nil {
+				t.Fatalf("PutFileStream: %v", err)
+			}
+
+			info, err := reg.Stat(ctx, fileRef)
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if info.Size != int64(len(content)) {
+				t.Errorf("Size = %d, want %d", info.Size, len(content))
+			}
+
+			rc, err := reg.GetFileStream(ctx, fileRef)
+			if err != nil {
+				t.Fatalf("GetFileStream: %v", err)
+			}
+			got, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				t.Fatalf("ReadAll: %v", err)
+			}
+			if string(got) != content {
+				t.Errorf("content = %q, want %q", got, content)
+			}
+
+			movedPath := filePath + ".moved"
+			if err := reg.Move(ctx, fileRef, movedPath); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+			movedRef := SourceRef{ProfileID: profileID, Path: movedPath}
+			if _, err := reg.Stat(ctx, movedRef); err != nil {
+				t.Fatalf("Stat after Move: %v", err)
+			}
+
+			if err := reg.Remove(ctx, movedRef, false); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			if _, err := reg.Stat(ctx, movedRef); err == nil {
+				t.Error("Stat after Remove: expected an error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// remoteRegistry holds named remotes registered via RegisterRemote or
+// LoadConfig, persisted as JSON under cacheDir so they survive a process
+// restart without the operator re-running whatever bootstrapped them.
+// Unlike secrets.Service (which encrypts credentials at rest for
+// user-facing aliases), this registry exists purely so source callers can
+// name a remote once and then pass around a "name:path" string instead of
+// threading a creds map through every layer - it's meant for operator-
+// configured, not end-user, remotes.
+type remoteRegistry struct {
+	mu      sync.Mutex
+	remotes map[string]map[string]interface{}
+}
+
+var remoteReg = &remoteRegistry{remotes: make(map[string]map[string]interface{})}
+
+// remoteProfileID namespaces a named remote's BackendRegistry profile so it
+// can't collide with a ProfileID some other caller (e.g. dataset_source's
+// anonProfileID) derives independently.
+func remoteProfileID(name string) string {
+	return "remote:" + name
+}
+
+// remotesFilePath is where the registry persists itself, re-evaluated on
+// every save/load since cacheDir can change via Init after process start.
+func remotesFilePath() string {
+	return filepath.Join(cacheDir, "remotes.json")
+}
+
+// RegisterRemote names creds as remote, so later calls can refer to it as
+// "remote:sub/path" instead of passing creds around directly, and persists
+// the registry to disk. Calling it again with the same name replaces that
+// remote's credentials and evicts its cached VFS instances, the same
+// rotation behavior BackendRegistry.RegisterProfile already has.
+func RegisterRemote(name string, creds map[string]interface{}) error {
+	if name == "" {
+		return fmt.Errorf("source: remote name must not be empty")
+	}
+	if strings.Contains(name, ":") {
+		return fmt.Errorf("source: remote name %q must not contain ':'", name)
+	}
+
+	remoteReg.mu.Lock()
+	remoteReg.remotes[name] = creds
+	err := remoteReg.persistLocked()
+	remoteReg.mu.Unlock()
+
+	RegisterProfile(remoteProfileID(name), creds)
+	return err
+}
+
+// UnregisterRemote forgets name and evicts its cached VFS instances. Safe
+// to call even if name was never registered.
+func UnregisterRemote(name string) {
+	remoteReg.mu.Lock()
+	delete(remoteReg.remotes, name)
+	remoteReg.persistLocked()
+	remoteReg.mu.Unlock()
+
+	InvalidateProfile(remoteProfileID(name))
+}
+
+// LoadConfig registers every section of an rclone-compatible INI file
+// (the same format `rclone config file` writes) as a named remote, so an
+// operator can bootstrap a fleet of remotes without touching code:
+//
+//	[myS3]
+//	type = s3
+//	provider = AWS
+//	access_key_id = ...
+func LoadConfig(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var name string
+	var creds map[string]interface{}
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		return RegisterRemote(name, creds)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if err := flush(); err != nil {
+				return fmt.Errorf("source: registering remote %q: %w", name, err)
+			}
+			name = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			creds = make(map[string]interface{})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		creds[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("source: registering remote %q: %w", name, err)
+	}
+	return nil
+}
+
+// ResolveRemoteSpec parses a "remoteName:sub/path" string (rclone's own
+// "name:root" notation) against the registered-remote registry and returns
+// the equivalent SourceRef, re-registering the remote's credentials with
+// the default BackendRegistry so resolve() can find them.
+func ResolveRemoteSpec(spec string) (SourceRef, error) {
+	name, subPath, ok := strings.Cut(spec, ":")
+	if !ok {
+		return SourceRef{}, fmt.Errorf("source: %q is not a \"name:path\" remote spec", spec)
+	}
+
+	remoteReg.mu.Lock()
+	creds, ok := remoteReg.remotes[name]
+	remoteReg.mu.Unlock()
+	if !ok {
+		return SourceRef{}, fmt.Errorf("source: remote %q is not registered", name)
+	}
+
+	RegisterProfile(remoteProfileID(name), creds)
+	return SourceRef{ProfileID: remoteProfileID(name), Path: subPath}, nil
+}
+
+func (r *remoteRegistry) persistLocked() error {
+	data, err := json.MarshalIndent(r.remotes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(remotesFilePath(), data, 0600)
+}
+
+// load reads the persisted registry from disk, if present, and
+// re-registers every remote it finds with the default BackendRegistry.
+func (r *remoteRegistry) load() error {
+	data, err := os.ReadFile(remotesFilePath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var remotes map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &remotes); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.remotes = remotes
+	r.mu.Unlock()
+
+	for name, creds := range remotes {
+		RegisterProfile(remoteProfileID(name), creds)
+	}
+	return nil
+}
+
+// GetFileStreamNamed opens "remoteName:sub/path" for reading, resolving
+// the remote through the registry instead of requiring the caller to
+// build a SourceRef by hand. See GetFileStream.
+func GetFileStreamNamed(ctx context.Context, spec string) (io.ReadCloser, error) {
+	ref, err := ResolveRemoteSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return GetFileStream(ctx, ref)
+}
+
+// ListEntriesNamed lists "remoteName:sub/path"'s directory. See ListEntries.
+func ListEntriesNamed(ctx context.Context, spec string) ([]os.FileInfo, error) {
+	ref, err := ResolveRemoteSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	return ListEntries(ctx, ref)
+}
+
+// PutFileStreamNamed writes src to "remoteName:sub/path". See PutFileStream.
+func PutFileStreamNamed(ctx context.Context, spec string, src io.Reader) error {
+	ref, err := ResolveRemoteSpec(spec)
+	if err != nil {
+		return err
+	}
+	return PutFileStream(ctx, ref, src)
+}
+
+// MkdirNamed creates "remoteName:sub/path" as a directory. See Mkdir.
+func MkdirNamed(ctx context.Context, spec string) error {
+	ref, err := ResolveRemoteSpec(spec)
+	if err != nil {
+		return err
+	}
+	return Mkdir(ctx, ref)
+}
+
+// RemoveNamed deletes "remoteName:sub/path". See Remove.
+func RemoveNamed(ctx context.Context, spec string, recursive bool) error {
+	ref, err := ResolveRemoteSpec(spec)
+	if err != nil {
+		return err
+	}
+	return Remove(ctx, ref, recursive)
+}
@@ -2,174 +2,178 @@ package source
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/hex"
-	"fmt"
 	"io"
-	"net/url"
+	"log"
 	"os"
-	"path"
 	"path/filepath"
-	"sort"
-	"strings"
-	"sync"
 	"time"
 
 	_ "github.com/rclone/rclone/backend/all"
-	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config"
-	"github.com/rclone/rclone/fs/config/configmap"
-	"github.com/rclone/rclone/vfs"
-	"github.com/rclone/rclone/vfs/vfscommon"
 )
 
 var (
-	vfsCache = make(map[string]*vfs.VFS)
-	vfsMu    sync.Mutex
-	cacheDir = filepath.Join(os.TempDir(), "flight2-vfs-cache")
+	cacheDir        = filepath.Join(os.TempDir(), "flight2-vfs-cache")
+	defaultRegistry = NewBackendRegistry()
+
+	removeCacheOnExit bool
+	janitorCancel     context.CancelFunc
 )
 
-// Init sets the cache directory for rclone VFS.
-func Init(cd string) {
+const (
+	// defaultIdleTTL is how long a cached VFS instance can sit unused
+	// before the janitor shuts it down, absent a WithIdleTTL override.
+	defaultIdleTTL = 30 * time.Minute
+
+	// defaultJanitorInterval is how often the janitor checks for idle
+	// VFS instances, absent a WithJanitorInterval override.
+	defaultJanitorInterval = 5 * time.Minute
+)
+
+// initOptions collects Init's optional settings; zero value is every
+// default below.
+type initOptions struct {
+	idleTTL           time.Duration
+	janitorInterval   time.Duration
+	removeCacheOnExit bool
+}
+
+// Option customizes Init's lifecycle behavior.
+type Option func(*initOptions)
+
+// WithIdleTTL overrides how long a cached VFS instance can sit unused
+// before the janitor evicts it. <= 0 disables the janitor entirely.
+func WithIdleTTL(d time.Duration) Option {
+	return func(o *initOptions) { o.idleTTL = d }
+}
+
+// WithJanitorInterval overrides how often the janitor checks for idle VFS
+// instances. <= 0 disables the janitor entirely.
+func WithJanitorInterval(d time.Duration) Option {
+	return func(o *initOptions) { o.janitorInterval = d }
+}
+
+// WithRemoveCacheOnExit makes Shutdown delete cacheDir after flushing every
+// cached VFS instance, for short-lived processes (tests, one-shot CLI
+// tools) that shouldn't leave a cache directory behind.
+func WithRemoveCacheOnExit(remove bool) Option {
+	return func(o *initOptions) { o.removeCacheOnExit = remove }
+}
+
+// Init sets the cache directory for rclone VFS and starts the background
+// janitor that evicts idle VFS instances, tied to ctx so it stops when ctx
+// is done (in addition to an explicit Shutdown call).
+func Init(ctx context.Context, cd string, opts ...Option) {
 	if cd != "" {
 		cacheDir = cd
 		config.SetCacheDir(cd) // Set global rclone cache dir
 		os.MkdirAll(cacheDir, 0755)
 	}
-}
-
-// getVFS returns a cached or new VFS instance.
-func getVFS(ctx context.Context, sourcePath string, creds map[string]interface{}) (*vfs.VFS, string, error) {
-	fsType, ok := creds["type"].(string)
-	if !ok {
-		if strings.HasPrefix(sourcePath, "http:") || strings.HasPrefix(sourcePath, "https:") {
-			fsType = "http"
-		} else {
-			return nil, "", fmt.Errorf("credentials missing 'type' field")
-		}
-	}
-
-	// Determine FS Root and Relative Path based on type
-	var fsRoot string
-	var relPath string
-
-	switch fsType {
-	case "local":
-		// For local, we map the VFS to the system root /
-		fsRoot = "/"
-		if abs, err := filepath.Abs(sourcePath); err == nil {
-			relPath = abs
-		} else {
-			relPath = sourcePath
-		}
-		// Provide cleaner relative path for VFS: remove leading slash
-		relPath = strings.TrimPrefix(relPath, "/")
-
-	case "http", "https":
-		// For HTTP, we try to root at the domain
-		u, err := url.Parse(sourcePath)
-		if err == nil {
-			fsRoot = u.Scheme + "://" + u.Host
-			relPath = strings.TrimPrefix(u.Path, "/")
-		} else {
-			// Fallback
-			fsRoot = path.Dir(sourcePath)
-			relPath = path.Base(sourcePath)
-		}
-	default:
-		// Cloud providers (S3, Drive, etc)
-		// We root at "" (backend root)
-		fsRoot = ""
-		relPath = sourcePath
-		// Fix S3 path: if it starts with /, trim it
-		relPath = strings.TrimPrefix(relPath, "/")
+	if err := remoteReg.load(); err != nil {
+		log.Printf("source: loading persisted remotes: %v", err)
 	}
 
-	// Generate Hash Key depending on Creds + FsRoot
-	// Sort keys
-	keys := make([]string, 0, len(creds))
-	for k := range creds {
-		keys = append(keys, k)
+	o := initOptions{idleTTL: defaultIdleTTL, janitorInterval: defaultJanitorInterval}
+	for _, opt := range opts {
+		opt(&o)
 	}
-	sort.Strings(keys)
+	removeCacheOnExit = o.removeCacheOnExit
 
-	h := md5.New()
-	io.WriteString(h, fsRoot) // Include root in hash
-	for _, k := range keys {
-		io.WriteString(h, k)
-		io.WriteString(h, fmt.Sprint(creds[k]))
-	}
-	hash := hex.EncodeToString(h.Sum(nil))
+	janitorCtx, cancel := context.WithCancel(ctx)
+	janitorCancel = cancel
+	defaultRegistry.StartJanitor(janitorCtx, o.janitorInterval, o.idleTTL)
+}
 
-	vfsMu.Lock()
-	defer vfsMu.Unlock()
+// Close shuts down every cached VFS instance in the default registry. Call
+// once during process shutdown, after any in-flight requests have drained.
+// Prefer Shutdown when the caller has a context to bound the wait with, or
+// wants cacheDir removed afterward.
+func Close() {
+	defaultRegistry.Close()
+}
 
-	if v, ok := vfsCache[hash]; ok {
-		return v, relPath, nil
+// Shutdown cancels the janitor, flushes and shuts down every cached VFS
+// instance, and - if Init was called with WithRemoveCacheOnExit(true) -
+// removes cacheDir. It returns ctx.Err() if ctx is done before the flush
+// finishes, leaving the registry in whatever state it reached.
+func Shutdown(ctx context.Context) error {
+	if janitorCancel != nil {
+		janitorCancel()
 	}
 
-	// Create New
-	conf := make(configmap.Simple)
-	for k, v := range creds {
-		if k != "type" {
-			conf[k] = fmt.Sprint(v)
-		}
-	}
+	done := make(chan struct{})
+	go func() {
+		defaultRegistry.Close()
+		close(done)
+	}()
 
-	regInfo, err := fs.Find(fsType)
-	if err != nil {
-		return nil, "", fmt.Errorf("backend type '%s' not found: %w", fsType, err)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 
-	remoteName := fmt.Sprintf("flight2_%s", hash)
-	fsrc, err := regInfo.NewFs(ctx, remoteName, fsRoot, conf)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create fs: %w", err)
+	if removeCacheOnExit && cacheDir != "" {
+		return os.RemoveAll(cacheDir)
 	}
+	return nil
+}
 
-	opt := &vfscommon.Options{
-		CacheMode:         vfscommon.CacheModeFull,
-		DirCacheTime:      fs.Duration(10 * time.Minute),
-		CacheMaxAge:       fs.Duration(24 * time.Hour),
-		CachePollInterval: fs.Duration(1 * time.Minute),
-		ChunkSize:         fs.SizeSuffix(128 * 1024 * 1024),
-	}
+// ProfileCount returns the number of credential profiles registered with
+// the default registry, for readiness checks.
+func ProfileCount() int {
+	return defaultRegistry.ProfileCount()
+}
 
-	v := vfs.New(fsrc, opt)
-	vfsCache[hash] = v
+// RegisterProfile registers profileID's credentials with the default
+// registry. See BackendRegistry.RegisterProfile.
+func RegisterProfile(profileID string, creds map[string]interface{}) {
+	defaultRegistry.RegisterProfile(profileID, creds)
+}
 
-	return v, relPath, nil
+// InvalidateProfile evicts profileID's cached fs.Fs/VFS instances from the
+// default registry. See BackendRegistry.InvalidateProfile.
+func InvalidateProfile(profileID string) {
+	defaultRegistry.InvalidateProfile(profileID)
 }
 
-// GetFileStream returns a stream using VFS.
-func GetFileStream(ctx context.Context, sourcePath string, creds map[string]interface{}) (io.ReadCloser, error) {
-	v, relPath, err := getVFS(ctx, sourcePath, creds)
-	if err != nil {
-		return nil, err
-	}
+// GetFileStream returns a stream for ref using the default registry.
+func GetFileStream(ctx context.Context, ref SourceRef) (io.ReadCloser, error) {
+	return defaultRegistry.GetFileStream(ctx, ref)
+}
 
-	f, err := v.OpenFile(relPath, os.O_RDONLY, 0)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file '%s': %w", relPath, err)
-	}
-	return f, nil
+// GetFileRange returns a stream for ref starting at offset and limited to
+// length bytes (or to EOF if length < 0), using the default registry. See
+// BackendRegistry.GetFileRange.
+func GetFileRange(ctx context.Context, ref SourceRef, offset, length int64) (io.ReadCloser, error) {
+	return defaultRegistry.GetFileRange(ctx, ref, offset, length)
 }
 
-// ListEntries returns a list of files as []os.FileInfo.
-func ListEntries(ctx context.Context, sourcePath string, creds map[string]interface{}) ([]os.FileInfo, error) {
-	v, relPath, err := getVFS(ctx, sourcePath, creds)
-	if err != nil {
-		return nil, err
-	}
+// ListEntries returns a list of files as []os.FileInfo using the default
+// registry.
+func ListEntries(ctx context.Context, ref SourceRef) ([]os.FileInfo, error) {
+	return defaultRegistry.ListEntries(ctx, ref)
+}
 
-	if relPath == "" {
-		relPath = "."
-	}
+// PutFileStream writes src to ref using the default registry. See
+// BackendRegistry.PutFileStream.
+func PutFileStream(ctx context.Context, ref SourceRef, src io.Reader) error {
+	return defaultRegistry.PutFileStream(ctx, ref, src)
+}
 
-	infos, err := v.ReadDir(relPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list directory '%s': %w", relPath, err)
-	}
+// Mkdir creates ref as a directory using the default registry. See
+// BackendRegistry.Mkdir.
+func Mkdir(ctx context.Context, ref SourceRef) error {
+	return defaultRegistry.Mkdir(ctx, ref)
+}
+
+// Move renames ref's path to newPath using the default registry. See
+// BackendRegistry.Move.
+func Move(ctx context.Context, ref SourceRef, newPath string) error {
+	return defaultRegistry.Move(ctx, ref, newPath)
+}
 
-	return infos, nil
+// Remove deletes ref using the default registry. See BackendRegistry.Remove.
+func Remove(ctx context.Context, ref SourceRef, recursive bool) error {
+	return defaultRegistry.Remove(ctx, ref, recursive)
 }
@@ -0,0 +1,58 @@
+package source
+
+import (
+	"context"
+	"time"
+
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// ObjectInfo is a lightweight metadata probe for a source object, cheap
+// enough to call before deciding whether a cached conversion is still
+// fresh.
+type ObjectInfo struct {
+	Size    int64
+	ModTime time.Time
+	// ETag is the backend's content identifier when it has one (S3 ETag,
+	// HTTP ETag/Last-Modified derived hash, etc). Empty when unavailable.
+	ETag string
+}
+
+// Stat probes ref without downloading its body, so callers can decide
+// whether a previously cached conversion is still valid. It returns an error
+// if the underlying VFS can't resolve the path; callers should fall back to
+// a full fetch+convert in that case.
+func Stat(ctx context.Context, ref SourceRef) (ObjectInfo, error) {
+	return defaultRegistry.Stat(ctx, ref)
+}
+
+// Stat is the BackendRegistry form of the package-level Stat.
+func (r *BackendRegistry) Stat(ctx context.Context, ref SourceRef) (ObjectInfo, error) {
+	v, relPath, err := r.resolve(ctx, ref)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := v.Stat(relPath)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	out := ObjectInfo{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+
+	// When the VFS node wraps an rclone fs.Object (anything but a plain
+	// local file), prefer a content hash as the ETag so an overwrite with
+	// the same size/mtime is still detected.
+	if hasher, ok := info.Sys().(interface {
+		Hash(ctx context.Context, ty hash.Type) (string, error)
+	}); ok {
+		if sum, err := hasher.Hash(ctx, hash.MD5); err == nil && sum != "" {
+			out.ETag = sum
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,154 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// unionUpstream is one subtree mapping in a "union" profile's
+// creds["upstreams"] list: everything under Prefix resolves to Root within
+// the named remote Remote (registered via RegisterRemote/LoadConfig).
+type unionUpstream struct {
+	Prefix string `json:"prefix"`
+	Remote string `json:"remote"`
+	Root   string `json:"root"`
+}
+
+// parseUnionUpstreams decodes creds["upstreams"] (already a generic
+// []interface{} of map[string]interface{}, the shape encoding/json gives
+// any JSON-sourced credential blob) into []unionUpstream by round-tripping
+// it through json, the same trick the rest of source uses for anything
+// that enters as untyped JSON.
+func parseUnionUpstreams(raw interface{}) ([]unionUpstream, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("union: invalid upstreams: %w", err)
+	}
+	var upstreams []unionUpstream
+	if err := json.Unmarshal(data, &upstreams); err != nil {
+		return nil, fmt.Errorf("union: invalid upstreams: %w", err)
+	}
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("union: upstreams must not be empty")
+	}
+	return upstreams, nil
+}
+
+// matchUnionUpstream returns the upstream whose Prefix is the longest match
+// for relPath, along with relPath relative to that prefix. An empty Prefix
+// acts as a catch-all, matching anything no more specific prefix claims.
+func matchUnionUpstream(upstreams []unionUpstream, relPath string) (unionUpstream, string, bool) {
+	bestLen := -1
+	var best unionUpstream
+	var bestRest string
+	for _, up := range upstreams {
+		prefix := strings.Trim(up.Prefix, "/")
+		var rest string
+		switch {
+		case prefix == "":
+			rest = relPath
+		case relPath == prefix:
+			rest = ""
+		case strings.HasPrefix(relPath, prefix+"/"):
+			rest = strings.TrimPrefix(relPath, prefix+"/")
+		default:
+			continue
+		}
+		if len(prefix) > bestLen {
+			bestLen = len(prefix)
+			best = up
+			bestRest = rest
+		}
+	}
+	if bestLen < 0 {
+		return unionUpstream{}, "", false
+	}
+	return best, bestRest, true
+}
+
+// resolveUnionRef resolves ref (whose profile's creds have type "union")
+// to its matching upstream's own SourceRef, by dispatching relPath to the
+// longest-prefix-matching entry in upstreams and rewriting its path to
+// that upstream's Root.
+func resolveUnionRef(ref SourceRef, upstreams []unionUpstream) (SourceRef, error) {
+	relPath := strings.TrimPrefix(ref.Path, "/")
+	up, rest, ok := matchUnionUpstream(upstreams, relPath)
+	if !ok {
+		return SourceRef{}, fmt.Errorf("union: no upstream matches %q", relPath)
+	}
+	return ResolveRemoteSpec(up.Remote + ":" + path.Join(up.Root, rest))
+}
+
+// unionDirInfo synthesizes a directory entry for a union subtree mount
+// point that hasn't actually been listed yet - e.g. "datasets" when
+// browsing the union root, before descending into the s3 upstream it maps
+// to. Mirrors server.dirInfo's shape for the same reason: an os.FileInfo
+// with no real backing file.
+type unionDirInfo struct{ name string }
+
+func (d unionDirInfo) Name() string       { return d.name }
+func (d unionDirInfo) Size() int64        { return 0 }
+func (d unionDirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d unionDirInfo) ModTime() time.Time { return time.Time{} }
+func (d unionDirInfo) IsDir() bool        { return true }
+func (d unionDirInfo) Sys() interface{}   { return nil }
+
+// listUnion lists ref against a union profile's upstreams when relPath sits
+// at or above one or more mount points, merging and deduplicating by name
+// (earlier upstreams in the list win collisions). It reports handled=false
+// when relPath descends past every upstream's prefix, meaning the caller
+// should fall back to resolving and listing a single upstream directly.
+func (r *BackendRegistry) listUnion(ctx context.Context, ref SourceRef, upstreams []unionUpstream) (infos []os.FileInfo, handled bool, err error) {
+	relPath := strings.Trim(ref.Path, "/")
+
+	seen := make(map[string]bool)
+	add := func(info os.FileInfo) {
+		if seen[info.Name()] {
+			return
+		}
+		seen[info.Name()] = true
+		infos = append(infos, info)
+	}
+
+	atMountPoint := false
+	for _, up := range upstreams {
+		prefix := strings.Trim(up.Prefix, "/")
+
+		switch {
+		case prefix == relPath:
+			atMountPoint = true
+			childRef, rerr := ResolveRemoteSpec(up.Remote + ":" + up.Root)
+			if rerr != nil {
+				return nil, true, fmt.Errorf("union: resolving upstream %q: %w", up.Remote, rerr)
+			}
+			entries, lerr := r.ListEntries(ctx, childRef)
+			if lerr != nil {
+				return nil, true, fmt.Errorf("union: listing upstream %q: %w", up.Remote, lerr)
+			}
+			for _, e := range entries {
+				add(e)
+			}
+
+		case relPath == "" && prefix != "":
+			atMountPoint = true
+			name, _, _ := strings.Cut(prefix, "/")
+			add(unionDirInfo{name: name})
+
+		case strings.HasPrefix(prefix, relPath+"/"):
+			atMountPoint = true
+			rest := strings.TrimPrefix(prefix, relPath+"/")
+			name, _, _ := strings.Cut(rest, "/")
+			add(unionDirInfo{name: name})
+		}
+	}
+
+	if !atMountPoint {
+		return nil, false, nil
+	}
+	return infos, true, nil
+}
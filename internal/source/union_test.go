@@ -0,0 +1,49 @@
+package source
+
+import "testing"
+
+// Type: Unit Test
+func TestMatchUnionUpstream(t *testing.T) {
+	upstreams := []unionUpstream{
+		{Prefix: "datasets", Remote: "s3", Root: "bucket"},
+		{Prefix: "logs", Remote: "drive", Root: "root"},
+		{Prefix: "", Remote: "local", Root: "/mnt/raw"},
+	}
+
+	tests := []struct {
+		relPath    string
+		wantRemote string
+		wantRest   string
+		wantOK     bool
+	}{
+		{relPath: "datasets/train/part-00000", wantRemote: "s3", wantRest: "train/part-00000", wantOK: true},
+		{relPath: "datasets", wantRemote: "s3", wantRest: "", wantOK: true},
+		{relPath: "logs/2024/app.log", wantRemote: "drive", wantRest: "2024/app.log", wantOK: true},
+		{relPath: "raw/frame.bin", wantRemote: "local", wantRest: "raw/frame.bin", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.relPath, func(t *testing.T) {
+			up, rest, ok := matchUnionUpstream(upstreams, tt.relPath)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if up.Remote != tt.wantRemote {
+				t.Errorf("Remote = %q, want %q", up.Remote, tt.wantRemote)
+			}
+			if rest != tt.wantRest {
+				t.Errorf("rest = %q, want %q", rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+// Type: Unit Test
+func TestMatchUnionUpstream_NoMatch(t *testing.T) {
+	upstreams := []unionUpstream{
+		{Prefix: "datasets", Remote: "s3", Root: "bucket"},
+	}
+	if _, _, ok := matchUnionUpstream(upstreams, "logs/app.log"); ok {
+		t.Error("expected no match without a catch-all upstream")
+	}
+}
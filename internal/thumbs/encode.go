@@ -0,0 +1,142 @@
+package thumbs
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	// Registers JPEG/PNG/GIF decoders with image.Decode.
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/nfnt/resize"
+)
+
+// encoder turns a source file's bytes into a width-bounded JPEG thumbnail
+// written to dst.
+type encoder interface {
+	Encode(ctx context.Context, src io.Reader, dst io.Writer, width int) error
+}
+
+var contentTypeByExt = map[string]string{
+	".jpg":  "image",
+	".jpeg": "image",
+	".png":  "image",
+	".gif":  "image",
+	".mp4":  "video",
+	".mov":  "video",
+	".webm": "video",
+	".mkv":  "video",
+	".pdf":  "pdf",
+}
+
+// SupportedContentType reports whether filePath is a type the thumbnail
+// generator knows how to handle, and which encoder family it routes to.
+func SupportedContentType(filePath string) (string, bool) {
+	kind, ok := contentTypeByExt[strings.ToLower(path.Ext(filePath))]
+	return kind, ok
+}
+
+func encoderFor(contentType string) (encoder, bool) {
+	switch contentType {
+	case "image":
+		return imageEncoder{}, true
+	case "video":
+		return ffmpegEncoder{}, true
+	case "pdf":
+		return pdfEncoder{}, true
+	}
+	return nil, false
+}
+
+// imageEncoder decodes a still image and resizes it with resize.Thumbnail,
+// which preserves aspect ratio and never upscales past width.
+type imageEncoder struct{}
+
+func (imageEncoder) Encode(ctx context.Context, src io.Reader, dst io.Writer, width int) error {
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return fmt.Errorf("decode image: %w", err)
+	}
+	thumb := resize.Thumbnail(uint(width), uint(width), img, resize.Lanczos3)
+	return jpeg.Encode(dst, thumb, &jpeg.Options{Quality: 85})
+}
+
+// ffmpegEncoder shells out to ffmpeg to grab the first frame of a video and
+// scale it down, the same way backend_docker.go shells out to the
+// docker-credential helpers rather than reimplementing their protocol.
+type ffmpegEncoder struct{}
+
+func (ffmpegEncoder) Encode(ctx context.Context, src io.Reader, dst io.Writer, width int) error {
+	tmp, err := os.CreateTemp("", "thumbs-video-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-y", "-loglevel", "error",
+		"-i", tmp.Name(),
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-1", width),
+		"-f", "image2pipe", "-vcodec", "mjpeg", "-")
+	cmd.Stdout = dst
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg first-frame extract: %w", err)
+	}
+	return nil
+}
+
+// pdfEncoder shells out to pdftoppm (poppler-utils) to rasterize the first
+// page as a JPEG scaled to width, and copies that file to dst. pdftoppm writes
+// "<prefix>-1.jpg" rather than to stdout, so it needs its own temp dir.
+type pdfEncoder struct{}
+
+func (pdfEncoder) Encode(ctx context.Context, src io.Reader, dst io.Writer, width int) error {
+	dir, err := os.MkdirTemp("", "thumbs-pdf-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	srcPath := dir + "/src.pdf"
+	f, err := os.Create(srcPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	prefix := dir + "/page"
+	cmd := exec.CommandContext(ctx, "pdftoppm",
+		"-jpeg", "-f", "1", "-l", "1",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1",
+		srcPath, prefix)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pdftoppm first-page render: %w", err)
+	}
+
+	page, err := os.Open(prefix + "-1.jpg")
+	if err != nil {
+		return fmt.Errorf("pdftoppm: no output page: %w", err)
+	}
+	defer page.Close()
+
+	_, err = io.Copy(dst, page)
+	return err
+}
@@ -0,0 +1,68 @@
+package thumbs
+
+import (
+	"context"
+	"sync"
+)
+
+// workerPool bounds the number of concurrent encode jobs and coalesces
+// concurrent requests for the same key onto a single in-flight job, the
+// same way dataset.Manager's commit pool bounds disk-tier writes.
+type workerPool struct {
+	sem chan struct{}
+
+	mu       sync.Mutex
+	inFlight map[string]*inFlightJob
+}
+
+type inFlightJob struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+func newWorkerPool(workers int) *workerPool {
+	return &workerPool{
+		sem:      make(chan struct{}, workers),
+		inFlight: make(map[string]*inFlightJob),
+	}
+}
+
+// generate runs fn to produce key's thumbnail, deduplicating concurrent
+// callers for the same key onto one execution.
+func (p *workerPool) generate(ctx context.Context, key string, fn func() (string, error)) (string, error) {
+	p.mu.Lock()
+	if job, ok := p.inFlight[key]; ok {
+		p.mu.Unlock()
+		select {
+		case <-job.done:
+			return job.path, job.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	job := &inFlightJob{done: make(chan struct{})}
+	p.inFlight[key] = job
+	p.mu.Unlock()
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		job.err = ctx.Err()
+		close(job.done)
+		p.mu.Lock()
+		delete(p.inFlight, key)
+		p.mu.Unlock()
+		return "", ctx.Err()
+	}
+	defer func() { <-p.sem }()
+
+	job.path, job.err = fn()
+	close(job.done)
+
+	p.mu.Lock()
+	delete(p.inFlight, key)
+	p.mu.Unlock()
+
+	return job.path, job.err
+}
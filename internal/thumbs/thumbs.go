@@ -0,0 +1,252 @@
+// Package thumbs generates and caches small preview images for files
+// surfaced by the browse listing, so a directory of images/videos/PDFs
+// renders inline thumbnails instead of a generic file badge.
+//
+// Generation is lazy and on-disk cached: the first request for a given
+// (alias, path, size, mtime, width) pays the encode cost, everything after
+// that is served straight off disk with conditional-GET support. The cache
+// is a size-bounded LRU, same shape as dataset's disk tier, so long-lived
+// servers don't grow cacheDir without bound.
+package thumbs
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxCacheSize bounds the thumbnail cache directory; once exceeded,
+// the least-recently-used thumbnails are evicted to make room.
+const defaultMaxCacheSize = 512 * 1024 * 1024 // 512MB
+
+// defaultWidth is used when a request doesn't specify ?w=.
+const defaultWidth = 200
+
+// Request identifies the thumbnail a caller wants: a specific file at a
+// specific size. Size and ModTime come from a Stat call on the source file
+// so a stale cache entry (the file changed underneath it) is never served.
+type Request struct {
+	Alias   string
+	Path    string
+	Size    int64
+	ModTime time.Time
+	Width   int
+}
+
+// Key returns the cache key Request resolves to: (alias, path, size, mtime,
+// width), hashed so it's safe to use as a filename regardless of what Path
+// contains.
+func (req Request) Key() string {
+	if req.Width <= 0 {
+		req.Width = defaultWidth
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d\x00%d", req.Alias, req.Path, req.Size, req.ModTime.Unix(), req.Width)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Result is a generated thumbnail ready to be served.
+type Result struct {
+	Path        string // on-disk location of the encoded thumbnail
+	ContentType string
+	ModTime     time.Time
+	Size        int64
+}
+
+// FetchFunc opens the source file a thumbnail is generated from. Callers
+// pass dataset_source.GetFileStream bound to their own alias/path/creds -
+// thumbs has no knowledge of backends or credentials.
+type FetchFunc func(ctx context.Context) (io.ReadCloser, error)
+
+// Cache is an on-disk, size-bounded LRU of generated thumbnails, fronted by
+// a worker pool so concurrent requests for the same or different files
+// don't pile up unbounded encode goroutines.
+type Cache struct {
+	dir     string
+	pool    *workerPool
+	maxSize int64
+
+	mu          sync.Mutex
+	order       *list.List
+	items       map[string]*list.Element
+	currentSize int64
+}
+
+type cacheEntry struct {
+	key     string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewCache creates a Cache rooted at dir (created if missing) with workers
+// concurrent encode workers. maxSize <= 0 falls back to
+// defaultMaxCacheSize.
+func NewCache(dir string, workers int, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("thumbs: create cache dir: %w", err)
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxCacheSize
+	}
+	if workers <= 0 {
+		workers = 4
+	}
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		order:   list.New(),
+		items:   make(map[string]*list.Element),
+	}
+	c.pool = newWorkerPool(workers)
+	if err := c.loadExisting(); err != nil {
+		return nil, fmt.Errorf("thumbs: scan cache dir: %w", err)
+	}
+	return c, nil
+}
+
+// loadExisting seeds the LRU index from thumbnails already on disk from a
+// prior process, ordered oldest-mtime-first, so maxSize is enforced across
+// restarts instead of only counting files re-requested since startup.
+func (c *Cache) loadExisting() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ii, _ := entries[i].Info()
+		jj, _ := entries[j].Info()
+		if ii == nil || jj == nil {
+			return false
+		}
+		return ii.ModTime().Before(jj.ModTime())
+	})
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".tmp-") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		c.touch(e.Name(), filepath.Join(c.dir, e.Name()), info.Size(), info.ModTime())
+	}
+	return nil
+}
+
+// Get returns the cached thumbnail for req, generating it via fetch+encode
+// on a miss. Concurrent Gets for the same key coalesce onto one encode by
+// way of the worker pool's per-key dedup.
+func (c *Cache) Get(ctx context.Context, req Request, fetch FetchFunc) (Result, error) {
+	if req.Width <= 0 {
+		req.Width = defaultWidth
+	}
+	key := req.Key()
+	contentType, ok := SupportedContentType(req.Path)
+	if !ok {
+		return Result{}, fmt.Errorf("thumbs: unsupported file type %q", req.Path)
+	}
+
+	if res, ok := c.lookup(key, contentType); ok {
+		return res, nil
+	}
+
+	path, err := c.pool.generate(ctx, key, func() (string, error) {
+		return c.encode(ctx, req, contentType, fetch)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return Result{}, err
+	}
+	c.touch(key, path, info.Size(), info.ModTime())
+	return Result{Path: path, ContentType: contentType, ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+// lookup returns the cached thumbnail for key without generating, bumping
+// its LRU position on a hit.
+func (c *Cache) lookup(key, contentType string) (Result, bool) {
+	path := filepath.Join(c.dir, key)
+	info, err := os.Stat(path)
+	if err != nil {
+		return Result{}, false
+	}
+	c.touch(key, path, info.Size(), info.ModTime())
+	return Result{Path: path, ContentType: contentType, ModTime: info.ModTime(), Size: info.Size()}, true
+}
+
+func (c *Cache) encode(ctx context.Context, req Request, contentType string, fetch FetchFunc) (string, error) {
+	rc, err := fetch(ctx)
+	if err != nil {
+		return "", fmt.Errorf("thumbs: fetch %q: %w", req.Path, err)
+	}
+	defer rc.Close()
+
+	enc, ok := encoderFor(contentType)
+	if !ok {
+		return "", fmt.Errorf("thumbs: no encoder for %q", contentType)
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once the rename below succeeds
+
+	if err := enc.Encode(ctx, rc, tmp, req.Width); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("thumbs: encode %q: %w", req.Path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(c.dir, req.Key())
+	if err := os.Rename(tmpPath, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// touch records key as most-recently-used, evicting older entries until the
+// cache fits within maxSize.
+func (c *Cache) touch(key, path string, size int64, modTime time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.currentSize -= el.Value.(*cacheEntry).size
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+
+	el := c.order.PushFront(&cacheEntry{key: key, path: path, size: size, modTime: modTime})
+	c.items[key] = el
+	c.currentSize += size
+
+	for c.currentSize > c.maxSize {
+		oldest := c.order.Back()
+		if oldest == nil {
+			break
+		}
+		entry := oldest.Value.(*cacheEntry)
+		c.order.Remove(oldest)
+		delete(c.items, entry.key)
+		c.currentSize -= entry.size
+		// Best-effort: a failed removal just leaves the file until the
+		// next eviction pass or process restart reclaims it.
+		os.Remove(entry.path)
+	}
+}
@@ -14,8 +14,9 @@ import (
 )
 
 // TestRcloneListing verifies that source.ListEntries works correctly
-// using the Cloudflare R2 bucket.
-// It lists the contents of the 'test-mksqlite/sample_data/' directory.
+// against the "local" rclone backend. It lists a fixture directory created
+// under t.TempDir() instead of hitting a real cloud bucket, so the test
+// needs no credentials and no network.
 func TestRcloneListing(t *testing.T) {
 	// 1. Setup Config & Secrets
 	cfg, cleanup := getTestConfig(t)
@@ -27,29 +28,27 @@ func TestRcloneListing(t *testing.T) {
 	}
 	defer secretsService.Close()
 
-	// 2. Setup Credentials
+	// 2. Fixture: a local directory standing in for the remote bucket.
+	fixtureDir := path.Join(t.TempDir(), "sample_data")
+	os.MkdirAll(fixtureDir, 0755)
+	if err := os.WriteFile(path.Join(fixtureDir, "21mb.csv"), []byte("id,value\n1,a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write fixture file: %v", err)
+	}
+
 	creds := map[string]interface{}{
-		"provider":          "Cloudflare",
-		"access_key_id":     "0d5aacd854377d79f3c83caa688effbe",
-		"secret_access_key": "986a762b395b7b9ebc6c08a62a64cbd8a872654ce7c927270e46cab19c9b0af5",
-		"endpoint":          "https://d8dc30936fb37cbd74552d31a709f6cf.r2.cloudflarestorage.com",
-		"region":            "auto",
-		"chunk_size":        "5Mi",
-		"copy_cutoff":       "5Mi",
-		"type":              "s3",
+		"type": "local",
 	}
 
 	// 3. Init Rclone VFS in correct cache dir
-	source.Init(cfg.CacheDir)
+	source.Init(context.Background(), cfg.CacheDir)
+	source.RegisterProfile("local-test", creds)
 
 	// 4. Test Listing
-	// The bucket path we want to list is 'test-mksqlite/sample_data'
-	// Note: For S3, the "bucket" is usually part of the root.
-	// In source.go logic for cloud providers, we use "" as fsRoot, and path is absolute from there.
-	targetPath := "test-mksqlite/sample_data"
+	targetPath := fixtureDir
 
 	t.Logf("Listing entries in: %s", targetPath)
-	entries, err := source.ListEntries(context.Background(), targetPath, creds)
+	ref := source.SourceRef{Backend: "local", Path: targetPath, ProfileID: "local-test"}
+	entries, err := source.ListEntries(context.Background(), ref)
 	if err != nil {
 		t.Fatalf("Failed to list entries: %v", err)
 	}
@@ -99,7 +98,7 @@ func TestAppEndpoint(t *testing.T) {
 
 	// 3. Initialize Server
 	// We pass nil for DataManager as /app index doesn't use it.
-	srv := server.NewServer(nil, ss, tmpDir, cfg.ServeFolder, true, true, false, cfg.DefaultDB)
+	srv := server.NewServer(nil, ss, cfg.ServeFolder, true, true, false, cfg.DefaultDB, nil, cfg.UserSecretsDB, false, false, false, 0, nil)
 
 	// 4. Test /app request
 	req, err := http.NewRequest("GET", "/app/", nil)